@@ -0,0 +1,40 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/service/gorm"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/zlog"
+	"net/http"
+)
+
+// GetCallHistory 获取通话记录
+func GetCallHistory(c *gin.Context) {
+	var req request.GetCallHistoryRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.CallService.GetCallHistory(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// GetIceServers 获取通话使用的STUN/TURN服务器和临时凭证
+func GetIceServers(c *gin.Context) {
+	var req request.GetIceServersRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.CallService.GetIceServers(req, c.ClientIP())
+	JsonBack(c, message, ret, rsp)
+}
@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/service/gorm"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/zlog"
+	"net/http"
+)
+
+// CreateContactList 创建联系人分组
+func CreateContactList(c *gin.Context) {
+	var req request.CreateContactListRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, listId, ret := gorm.ContactListService.CreateContactList(req)
+	JsonBack(c, message, ret, listId)
+}
+
+// GetContactLists 获取我的联系人分组
+func GetContactLists(c *gin.Context) {
+	var req request.OwnlistRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, contactLists, ret := gorm.ContactListService.GetContactLists(req.OwnerId)
+	JsonBack(c, message, ret, contactLists)
+}
+
+// UpdateContactList 修改联系人分组
+func UpdateContactList(c *gin.Context) {
+	var req request.UpdateContactListRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.ContactListService.UpdateContactList(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// DeleteContactList 删除联系人分组
+func DeleteContactList(c *gin.Context) {
+	var req request.DeleteContactListRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.ContactListService.DeleteContactList(req.OwnerId, req.ListId)
+	JsonBack(c, message, ret, nil)
+}
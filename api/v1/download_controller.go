@@ -0,0 +1,57 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/service/gorm"
+	"kama_chat_server/pkg/constants"
+	"mime"
+	"net/http"
+)
+
+// GetDownloadUrl 获取消息或收藏中文件的签名下载地址
+func GetDownloadUrl(c *gin.Context) {
+	var req request.GetDownloadUrlRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.DownloadService.GetDownloadUrl(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// DownloadFile 通过签名地址下载聊天文件，支持Range断点续传
+func DownloadFile(c *gin.Context) {
+	file, info, message, ret := gorm.DownloadService.OpenDownload(c.Param("filepath"), c.Request.URL.Query())
+	if ret != 0 {
+		if ret == -2 {
+			c.String(http.StatusNotFound, message)
+		} else {
+			c.String(http.StatusInternalServerError, message)
+		}
+		return
+	}
+	defer file.Close()
+	name := c.Query("name")
+	disposition := "attachment"
+	if c.Query("inline") == "1" {
+		disposition = "inline"
+	}
+	// 中文文件名按RFC 2231编码为filename*
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": name}); value != "" {
+		c.Header("Content-Disposition", value)
+	} else {
+		c.Header("Content-Disposition", disposition)
+	}
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", constants.DOWNLOAD_URL_EXPIRE))
+	// 按返回的类型处理，浏览器不再自行猜测类型
+	c.Header("X-Content-Type-Options", "nosniff")
+	if info.ContentType != "" {
+		c.Header("Content-Type", info.ContentType)
+	}
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime, file)
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/service/gorm"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/zlog"
+	"net/http"
+)
+
+// AddMessageFavorite 收藏消息
+func AddMessageFavorite(c *gin.Context) {
+	var req request.AddMessageFavoriteRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, favoriteId, ret := gorm.FavoriteService.AddMessageFavorite(req)
+	JsonBack(c, message, ret, favoriteId)
+}
+
+// AddNoteFavorite 添加笔记到收藏
+func AddNoteFavorite(c *gin.Context) {
+	var req request.AddNoteFavoriteRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, favoriteId, ret := gorm.FavoriteService.AddNoteFavorite(req)
+	JsonBack(c, message, ret, favoriteId)
+}
+
+// GetFavoriteList 获取收藏列表
+func GetFavoriteList(c *gin.Context) {
+	var req request.GetFavoriteListRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, favoriteList, ret := gorm.FavoriteService.GetFavoriteList(req)
+	JsonBack(c, message, ret, favoriteList)
+}
+
+// UpdateFavoriteTags 修改收藏标签
+func UpdateFavoriteTags(c *gin.Context) {
+	var req request.UpdateFavoriteTagsRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.FavoriteService.UpdateFavoriteTags(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// DeleteFavorite 取消收藏
+func DeleteFavorite(c *gin.Context) {
+	var req request.DeleteFavoriteRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.FavoriteService.DeleteFavorite(req.OwnerId, req.FavoriteId)
+	JsonBack(c, message, ret, nil)
+}
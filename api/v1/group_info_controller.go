@@ -194,3 +194,18 @@ func RemoveGroupMembers(c *gin.Context) {
 	message, ret := gorm.GroupInfoService.RemoveGroupMembers(req)
 	JsonBack(c, message, ret, nil)
 }
+
+// TransferGroupOwner 转让群主
+func TransferGroupOwner(c *gin.Context) {
+	var req request.TransferGroupOwnerRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.GroupInfoService.TransferGroupOwner(req)
+	JsonBack(c, message, ret, nil)
+}
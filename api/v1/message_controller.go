@@ -6,6 +6,7 @@ import (
 	"kama_chat_server/internal/service/gorm"
 	"kama_chat_server/pkg/constants"
 	"net/http"
+	"path"
 )
 
 // GetMessageList 获取聊天记录
@@ -38,12 +39,43 @@ func GetGroupMessageList(c *gin.Context) {
 
 // UploadAvatar 上传头像
 func UploadAvatar(c *gin.Context) {
-	message, ret := gorm.MessageService.UploadAvatar(c)
-	JsonBack(c, message, ret, nil)
+	message, rsp, ret := gorm.MessageService.UploadAvatar(c)
+	JsonBack(c, message, ret, rsp)
 }
 
 // UploadFile 上传头像
 func UploadFile(c *gin.Context) {
-	message, ret := gorm.MessageService.UploadFile(c)
-	JsonBack(c, message, ret, nil)
+	message, rsp, ret := gorm.MessageService.UploadFile(c)
+	JsonBack(c, message, ret, rsp)
+}
+
+// UploadVoice 上传语音
+func UploadVoice(c *gin.Context) {
+	message, rsp, ret := gorm.MessageService.UploadVoice(c)
+	JsonBack(c, message, ret, rsp)
+}
+
+// UploadImage 上传图片
+func UploadImage(c *gin.Context) {
+	message, rsp, ret := gorm.MessageService.UploadImage(c)
+	JsonBack(c, message, ret, rsp)
+}
+
+// DownloadStaticFile 从存储后端下载头像，支持Range
+func DownloadStaticFile(c *gin.Context) {
+	file, info, message, ret := gorm.MessageService.OpenStaticFile(c.Param("filepath"))
+	if ret != 0 {
+		if ret == -2 {
+			c.String(http.StatusNotFound, message)
+		} else {
+			c.String(http.StatusInternalServerError, message)
+		}
+		return
+	}
+	defer file.Close()
+	c.Header("X-Content-Type-Options", "nosniff")
+	if info.ContentType != "" {
+		c.Header("Content-Type", info.ContentType)
+	}
+	http.ServeContent(c.Writer, c.Request, path.Base(c.Param("filepath")), info.ModTime, file)
 }
@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/service/gorm"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/zlog"
+	"net/http"
+)
+
+// ScheduleMessage 创建定时消息
+func ScheduleMessage(c *gin.Context) {
+	var req request.ScheduleMessageRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, scheduledMessageId, ret := gorm.ScheduledMessageService.ScheduleMessage(req)
+	JsonBack(c, message, ret, scheduledMessageId)
+}
+
+// GetScheduledMessageList 获取等待发送的定时消息
+func GetScheduledMessageList(c *gin.Context) {
+	var req request.OwnlistRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, scheduledMessageList, ret := gorm.ScheduledMessageService.GetScheduledMessageList(req.OwnerId)
+	JsonBack(c, message, ret, scheduledMessageList)
+}
+
+// UpdateScheduledMessage 修改定时消息
+func UpdateScheduledMessage(c *gin.Context) {
+	var req request.UpdateScheduledMessageRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.ScheduledMessageService.UpdateScheduledMessage(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// CancelScheduledMessage 取消定时消息
+func CancelScheduledMessage(c *gin.Context) {
+	var req request.CancelScheduledMessageRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.ScheduledMessageService.CancelScheduledMessage(req.OwnerId, req.ScheduledMessageId)
+	JsonBack(c, message, ret, nil)
+}
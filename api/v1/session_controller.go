@@ -26,7 +26,7 @@ func OpenSession(c *gin.Context) {
 
 // GetUserSessionList 获取用户会话列表
 func GetUserSessionList(c *gin.Context) {
-	var getUserSessionListReq request.OwnlistRequest
+	var getUserSessionListReq request.OwnlistFilterRequest
 	if err := c.BindJSON(&getUserSessionListReq); err != nil {
 		zlog.Error(err.Error())
 		c.JSON(http.StatusOK, gin.H{
@@ -35,13 +35,13 @@ func GetUserSessionList(c *gin.Context) {
 		})
 		return
 	}
-	message, sessionList, ret := gorm.SessionService.GetUserSessionList(getUserSessionListReq.OwnerId)
+	message, sessionList, ret := gorm.SessionService.GetUserSessionList(getUserSessionListReq.OwnerId, getUserSessionListReq.ListId)
 	JsonBack(c, message, ret, sessionList)
 }
 
 // GetGroupSessionList 获取群聊会话列表
 func GetGroupSessionList(c *gin.Context) {
-	var getGroupListReq request.OwnlistRequest
+	var getGroupListReq request.OwnlistFilterRequest
 	if err := c.BindJSON(&getGroupListReq); err != nil {
 		zlog.Error(err.Error())
 		c.JSON(http.StatusOK, gin.H{
@@ -50,7 +50,7 @@ func GetGroupSessionList(c *gin.Context) {
 		})
 		return
 	}
-	message, groupList, ret := gorm.SessionService.GetGroupSessionList(getGroupListReq.OwnerId)
+	message, groupList, ret := gorm.SessionService.GetGroupSessionList(getGroupListReq.OwnerId, getGroupListReq.ListId)
 	JsonBack(c, message, ret, groupList)
 }
 
@@ -83,3 +83,78 @@ func CheckOpenSessionAllowed(c *gin.Context) {
 	message, res, ret := gorm.SessionService.CheckOpenSessionAllowed(req.SendId, req.ReceiveId)
 	JsonBack(c, message, ret, res)
 }
+
+// GetArchivedSessionList 获取已归档的会话列表
+func GetArchivedSessionList(c *gin.Context) {
+	var req request.OwnlistRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, sessionList, ret := gorm.SessionService.GetArchivedSessionList(req.OwnerId)
+	JsonBack(c, message, ret, sessionList)
+}
+
+// PinSession 置顶/取消置顶会话
+func PinSession(c *gin.Context) {
+	var req request.SetSessionFlagRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.SessionService.SetSessionPinned(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// MuteSession 开启/关闭会话免打扰
+func MuteSession(c *gin.Context) {
+	var req request.SetSessionFlagRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.SessionService.SetSessionMuted(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// ArchiveSession 归档/取消归档会话
+func ArchiveSession(c *gin.Context) {
+	var req request.SetSessionFlagRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.SessionService.SetSessionArchived(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// SaveDraft 保存会话草稿
+func SaveDraft(c *gin.Context) {
+	var req request.SaveDraftRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.SessionService.SaveDraft(req)
+	JsonBack(c, message, ret, nil)
+}
@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/service/gorm"
+	"kama_chat_server/pkg/constants"
+	"net/http"
+)
+
+// GetStorageUsage 查看自己或群聊的存储占用
+func GetStorageUsage(c *gin.Context) {
+	var req request.GetStorageUsageRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.StorageQuotaService.GetStorageUsage(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// SetStorageQuota 管理员设置存储配额
+func SetStorageQuota(c *gin.Context) {
+	var req request.SetStorageQuotaRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.StorageQuotaService.SetStorageQuota(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// GetTopStorageConsumers 管理员查看存储占用排行
+func GetTopStorageConsumers(c *gin.Context) {
+	var req request.GetTopStorageConsumersRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.StorageQuotaService.GetTopStorageConsumers(req)
+	JsonBack(c, message, ret, rsp)
+}
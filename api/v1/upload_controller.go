@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/service/gorm"
+	"kama_chat_server/pkg/constants"
+	"net/http"
+)
+
+// InitUpload 创建分片上传任务
+func InitUpload(c *gin.Context) {
+	var req request.InitUploadRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.UploadService.InitUpload(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// UploadPart 上传分片
+func UploadPart(c *gin.Context) {
+	message, ret := gorm.UploadService.UploadPart(c)
+	JsonBack(c, message, ret, nil)
+}
+
+// GetUploadStatus 查询已上传的分片
+func GetUploadStatus(c *gin.Context) {
+	var req request.UploadIdRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.UploadService.GetUploadStatus(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// CompleteUpload 合并分片
+func CompleteUpload(c *gin.Context) {
+	var req request.UploadIdRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.UploadService.CompleteUpload(req)
+	JsonBack(c, message, ret, rsp)
+}
+
+// AbortUpload 取消分片上传
+func AbortUpload(c *gin.Context) {
+	var req request.UploadIdRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.UploadService.AbortUpload(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// QuickUpload 秒传，文件已存在时直接返回地址
+func QuickUpload(c *gin.Context) {
+	var req request.QuickUploadRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, rsp, ret := gorm.UploadService.QuickUpload(req)
+	JsonBack(c, message, ret, rsp)
+}
@@ -12,7 +12,7 @@ import (
 
 // GetUserList 获取联系人列表
 func GetUserList(c *gin.Context) {
-	var myUserListReq request.OwnlistRequest
+	var myUserListReq request.OwnlistFilterRequest
 	if err := c.BindJSON(&myUserListReq); err != nil {
 		zlog.Error(err.Error())
 		c.JSON(http.StatusOK, gin.H{
@@ -20,7 +20,7 @@ func GetUserList(c *gin.Context) {
 			"message": constants.SYSTEM_ERROR,
 		})
 	}
-	message, userList, ret := gorm.UserContactService.GetUserList(myUserListReq.OwnerId)
+	message, userList, ret := gorm.UserContactService.GetUserList(myUserListReq.OwnerId, myUserListReq.ListId)
 	JsonBack(c, message, ret, userList)
 }
 
@@ -51,7 +51,7 @@ func GetContactInfo(c *gin.Context) {
 		return
 	}
 	log.Println(getContactInfoReq)
-	message, contactInfo, ret := gorm.UserContactService.GetContactInfo(getContactInfoReq.ContactId)
+	message, contactInfo, ret := gorm.UserContactService.GetContactInfo(getContactInfoReq.OwnerId, getContactInfoReq.ContactId)
 	JsonBack(c, message, ret, contactInfo)
 }
 
@@ -189,3 +189,48 @@ func BlackApply(c *gin.Context) {
 	message, ret := gorm.UserContactService.BlackApply(req.OwnerId, req.ContactId)
 	JsonBack(c, message, ret, nil)
 }
+
+// SetContactRemark 设置联系人备注
+func SetContactRemark(c *gin.Context) {
+	var req request.SetContactRemarkRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.UserContactService.SetContactRemark(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// GetRecommendList 获取可能认识的人
+func GetRecommendList(c *gin.Context) {
+	var req request.OwnlistRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, recommendList, ret := gorm.UserContactService.GetRecommendList(req.OwnerId)
+	JsonBack(c, message, ret, recommendList)
+}
+
+// SetMessageTtl 设置单聊或群聊的消息过期时长
+func SetMessageTtl(c *gin.Context) {
+	var req request.SetMessageTtlRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.MessageExpireService.SetMessageTtl(req)
+	JsonBack(c, message, ret, nil)
+}
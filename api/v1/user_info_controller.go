@@ -127,7 +127,7 @@ func GetUserInfo(c *gin.Context) {
 		})
 		return
 	}
-	message, userInfo, ret := gorm.UserInfoService.GetUserInfo(req.Uuid)
+	message, userInfo, ret := gorm.UserInfoService.GetUserInfo(req.OwnerId, req.Uuid)
 	JsonBack(c, message, ret, userInfo)
 }
 
@@ -175,3 +175,48 @@ func SendSmsCode(c *gin.Context) {
 	message, ret := gorm.UserInfoService.SendSmsCode(req.Telephone)
 	JsonBack(c, message, ret, nil)
 }
+
+// GetPrivacySettings 获取隐私设置
+func GetPrivacySettings(c *gin.Context) {
+	var req request.OwnlistRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, settings, ret := gorm.UserInfoService.GetPrivacySettings(req.OwnerId)
+	JsonBack(c, message, ret, settings)
+}
+
+// UpdatePrivacySettings 修改隐私设置
+func UpdatePrivacySettings(c *gin.Context) {
+	var req request.UpdatePrivacySettingsRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, ret := gorm.UserInfoService.UpdatePrivacySettings(req)
+	JsonBack(c, message, ret, nil)
+}
+
+// SearchUser 按电话或昵称搜索用户
+func SearchUser(c *gin.Context) {
+	var req request.SearchUserRequest
+	if err := c.BindJSON(&req); err != nil {
+		zlog.Error(err.Error())
+		c.JSON(http.StatusOK, gin.H{
+			"code":    500,
+			"message": constants.SYSTEM_ERROR,
+		})
+		return
+	}
+	message, userList, ret := gorm.UserInfoService.SearchUser(req.OwnerId, c.ClientIP(), req.Keyword)
+	JsonBack(c, message, ret, userList)
+}
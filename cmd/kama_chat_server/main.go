@@ -6,9 +6,11 @@ import (
 	"kama_chat_server/internal/dao"
 	"kama_chat_server/internal/https_server"
 	"kama_chat_server/internal/service/chat"
+	"kama_chat_server/internal/service/filecheck"
 	"kama_chat_server/internal/service/gorm"
 	"kama_chat_server/internal/service/kafka"
 	myredis "kama_chat_server/internal/service/redis"
+	"kama_chat_server/internal/service/storage"
 	"kama_chat_server/pkg/zlog"
 	"os"
 	"os/signal"
@@ -21,17 +23,46 @@ func main() {
 	port := conf.MainConfig.Port
 	kafkaConfig := conf.KafkaConfig
 
+	if err := storage.Init(conf); err != nil {
+		zlog.Fatal(err.Error())
+	}
+	if conf.DownloadConfig.SignSecret == "" {
+		zlog.Warn("未配置downloadConfig.signSecret，使用随机密钥签名下载地址")
+	}
+	filecheck.Init(conf.UploadCheckConfig)
+	if conf.UploadCheckConfig.ScanMaxSize > 0 {
+		zlog.Info(fmt.Sprintf("超过%dMB的上传文件不扫描", conf.UploadCheckConfig.ScanMaxSize))
+	}
+	chat.CloseUnfinishedCalls()
+
 	userDAO := dao.NewUserDAO(dao.GormDB)
 	groupDAO := dao.NewGroupDAO(dao.GormDB)
 	messageDAO := dao.NewMessageDAO(dao.GormDB)
 	sessionDAO := dao.NewSessionDAO(dao.GormDB)
 	userContactDAO := dao.NewUserContactDAO(dao.GormDB)
+	notificationDAO := dao.NewNotificationDAO(dao.GormDB)
+	contactListDAO := dao.NewContactListDAO(dao.GormDB)
+	favoriteDAO := dao.NewFavoriteDAO(dao.GormDB)
+	scheduledMessageDAO := dao.NewScheduledMessageDAO(dao.GormDB)
+	uploadDAO := dao.NewUploadDAO(dao.GormDB)
+	fileBlobDAO := dao.NewFileBlobDAO(dao.GormDB)
+	fileUsageDAO := dao.NewFileUsageDAO(dao.GormDB)
+	callRecordDAO := dao.NewCallRecordDAO(dao.GormDB)
 
-	gorm.InitSessionService(sessionDAO, userDAO, groupDAO)
+	gorm.InitSessionService(sessionDAO, userDAO, groupDAO, userContactDAO)
 	gorm.InitUserInfoService(userDAO)
 	gorm.InitGroupInfoService(groupDAO, userDAO)
-	gorm.InitMessageService(messageDAO)
+	gorm.InitMessageService(messageDAO, fileBlobDAO)
 	gorm.InitUserContactService(userContactDAO, userDAO, groupDAO)
+	gorm.InitNotificationService(notificationDAO, userDAO, groupDAO)
+	gorm.InitContactListService(contactListDAO, userContactDAO)
+	gorm.InitFavoriteService(favoriteDAO, messageDAO, userContactDAO)
+	gorm.InitScheduledMessageService(scheduledMessageDAO, sessionDAO, userDAO, userContactDAO)
+	gorm.InitMessageExpireService(messageDAO, sessionDAO, groupDAO, userDAO, userContactDAO)
+	gorm.InitUploadService(uploadDAO, fileBlobDAO)
+	gorm.InitDownloadService(messageDAO, favoriteDAO, userContactDAO)
+	gorm.InitStorageQuotaService(fileUsageDAO, uploadDAO, userDAO, groupDAO, userContactDAO)
+	gorm.InitCallService(callRecordDAO, userDAO)
 	if kafkaConfig.MessageMode == "kafka" {
 		kafka.KafkaService.KafkaInit()
 	}
@@ -41,6 +72,10 @@ func main() {
 	} else {
 		go chat.KafkaChatServer.Start()
 	}
+	go chat.SessionUpdater.Start()
+	go gorm.ScheduledMessageService.Start()
+	go gorm.MessageExpireService.Start()
+	go gorm.UploadService.Start()
 
 	go func() {
 		// Win10本地部署
@@ -68,11 +103,16 @@ func main() {
 	// 等待信号
 	<-quit
 
+	// 定时消息会投递到聊天服务或kafka，先等它停下
+	gorm.ScheduledMessageService.Close()
 	if kafkaConfig.MessageMode == "kafka" {
 		kafka.KafkaService.KafkaClose()
 	}
 
+	gorm.MessageExpireService.Close()
+	gorm.UploadService.Close()
 	chat.ChatServer.Close()
+	chat.SessionUpdater.Close()
 
 	zlog.Info("关闭服务器...")
 
@@ -10,6 +10,8 @@ type MainConfig struct {
 	AppName string `toml:"appName"`
 	Host    string `toml:"host"`
 	Port    int    `toml:"port"`
+	// 可信的反向代理地址或网段，只有来自这些地址的X-Forwarded-For才会被采用，为空时使用连接的对端地址
+	TrustedProxies []string `toml:"trustedProxies"`
 }
 
 type MysqlConfig struct {
@@ -51,16 +53,75 @@ type KafkaConfig struct {
 type StaticSrcConfig struct {
 	StaticAvatarPath string `toml:"staticAvatarPath"`
 	StaticFilePath   string `toml:"staticFilePath"`
+	StaticPartPath   string `toml:"staticPartPath"` // 分片上传的临时分片
+}
+
+// StorageConfig 文件存储后端，type为local时使用StaticSrcConfig中的本地目录，为s3时使用S3兼容的对象存储（如MinIO）
+type StorageConfig struct {
+	Type      string `toml:"type"`
+	Endpoint  string `toml:"endpoint"`
+	AccessKey string `toml:"accessKey"`
+	SecretKey string `toml:"secretKey"`
+	Bucket    string `toml:"bucket"`
+	Region    string `toml:"region"`
+	UseSSL    bool   `toml:"useSSL"`
+	PublicUrl string `toml:"publicUrl"` // 头像对外访问的地址前缀，为空时由本服务的/static代理，聊天文件始终通过签名地址下载
+}
+
+// DownloadConfig 聊天文件下载地址的签名配置，多实例部署时需要配置相同的密钥
+type DownloadConfig struct {
+	SignSecret string `toml:"signSecret"`
+}
+
+// QuotaConfig 默认存储配额，单位MB，0表示不限制；管理员可以为单个用户或群聊单独设置
+type QuotaConfig struct {
+	UserStorageQuota  int64 `toml:"userStorageQuota"`
+	GroupStorageQuota int64 `toml:"groupStorageQuota"`
+}
+
+// UploadRule 一种上传的类型限制，类型为嗅探出的MIME类型，支持image/*形式的通配
+// AllowTypes为空表示不限制类型，DenyTypes和DenyExts优先于AllowTypes
+type UploadRule struct {
+	AllowTypes []string `toml:"allowTypes"`
+	DenyTypes  []string `toml:"denyTypes"`
+	DenyExts   []string `toml:"denyExts"`
+}
+
+// UploadCheckConfig 上传文件检查，规则全部为空时使用内置的默认规则
+// scannerType为clamd时上传的文件在可见之前交给clamd扫描，clamdAddress为host:port或unix:/path
+type UploadCheckConfig struct {
+	AvatarRule   UploadRule `toml:"avatar"`
+	FileRule     UploadRule `toml:"file"`
+	VoiceRule    UploadRule `toml:"voice"`
+	ImageRule    UploadRule `toml:"image"`
+	ScannerType  string     `toml:"scannerType"`
+	ClamdAddress string     `toml:"clamdAddress"`
+	ScanTimeout  int        `toml:"scanTimeout"` // 单位秒
+	ScanMaxSize  int64      `toml:"scanMaxSize"` // 单位MB，超过的文件不扫描，应与clamd的StreamMaxLength一致，0表示都扫描
+}
+
+// IceConfig 音视频通话的ICE服务器，turnSecret与coturn的static-auth-secret一致
+// TURN凭证按coturn的REST API方式生成：用户名为"过期时间戳:用户uuid"，密码为用密钥对用户名做HMAC-SHA1后base64编码
+type IceConfig struct {
+	StunUrls      []string `toml:"stunUrls"`
+	TurnUrls      []string `toml:"turnUrls"`
+	TurnSecret    string   `toml:"turnSecret"`
+	CredentialTtl int      `toml:"credentialTtl"` // TURN凭证有效期，单位秒，0表示使用默认值
 }
 
 type Config struct {
-	MainConfig      `toml:"mainConfig"`
-	MysqlConfig     `toml:"mysqlConfig"`
-	RedisConfig     `toml:"redisConfig"`
-	AuthCodeConfig  `toml:"authCodeConfig"`
-	LogConfig       `toml:"logConfig"`
-	KafkaConfig     `toml:"kafkaConfig"`
-	StaticSrcConfig `toml:"staticSrcConfig"`
+	MainConfig        `toml:"mainConfig"`
+	MysqlConfig       `toml:"mysqlConfig"`
+	RedisConfig       `toml:"redisConfig"`
+	AuthCodeConfig    `toml:"authCodeConfig"`
+	LogConfig         `toml:"logConfig"`
+	KafkaConfig       `toml:"kafkaConfig"`
+	StaticSrcConfig   `toml:"staticSrcConfig"`
+	StorageConfig     `toml:"storageConfig"`
+	DownloadConfig    `toml:"downloadConfig"`
+	QuotaConfig       `toml:"quotaConfig"`
+	UploadCheckConfig `toml:"uploadCheckConfig"`
+	IceConfig         `toml:"iceConfig"`
 }
 
 var config *Config
@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"kama_chat_server/internal/model"
+	"kama_chat_server/pkg/enum/call/call_status_enum"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CallRecordDAO interface {
+	CreateCallRecord(record *model.CallRecord) error
+	SaveCallRecord(record *model.CallRecord) error
+	CloseUnfinishedCallRecords(now time.Time) error
+	GetCallHistory(userId, peerId string, offset, limit int) ([]model.CallRecord, int64, error)
+}
+
+type callRecordDAOImpl struct {
+	db *gorm.DB
+}
+
+func NewCallRecordDAO(db *gorm.DB) CallRecordDAO {
+	return &callRecordDAOImpl{db: db}
+}
+
+func (dao *callRecordDAOImpl) CreateCallRecord(record *model.CallRecord) error {
+	return dao.db.Create(record).Error
+}
+
+func (dao *callRecordDAOImpl) SaveCallRecord(record *model.CallRecord) error {
+	return dao.db.Save(record).Error
+}
+
+// CloseUnfinishedCallRecords 服务重启时结束上次未结束的通话，通话状态只保存在内存中，重启后无法继续
+// 呼叫中的记为超时，通话中的记为已结束，时长按接通时间计算到now
+func (dao *callRecordDAOImpl) CloseUnfinishedCallRecords(now time.Time) error {
+	return dao.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.CallRecord{}).Where("status = ?", call_status_enum.RINGING).Updates(map[string]interface{}{
+			"status":     call_status_enum.TIMEOUT,
+			"ended_at":   now,
+			"updated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.CallRecord{}).Where("status = ?", call_status_enum.ACCEPTED).Updates(map[string]interface{}{
+			"status":     call_status_enum.ENDED,
+			"ended_at":   now,
+			"duration":   gorm.Expr("GREATEST(TIMESTAMPDIFF(SECOND, accepted_at, ?), 0)", now),
+			"updated_at": now,
+		}).Error
+	})
+}
+
+// GetCallHistory 分页查询用户的通话记录，按呼叫时间倒序，peerId不为空时只查与该用户之间的通话
+func (dao *callRecordDAOImpl) GetCallHistory(userId, peerId string, offset, limit int) ([]model.CallRecord, int64, error) {
+	query := dao.db.Model(&model.CallRecord{})
+	if peerId == "" {
+		query = query.Where("caller_id = ? OR callee_id = ?", userId, userId)
+	} else {
+		query = query.Where("(caller_id = ? AND callee_id = ?) OR (caller_id = ? AND callee_id = ?)", userId, peerId, peerId, userId)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var records []model.CallRecord
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&records).Error
+	return records, total, err
+}
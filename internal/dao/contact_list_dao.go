@@ -0,0 +1,50 @@
+package dao
+
+import (
+	"kama_chat_server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type ContactListDAO interface {
+	CreateContactList(contactList *model.ContactList) error
+	GetContactListsByOwner(ownerId string) ([]model.ContactList, error)
+	GetContactListByUUID(uuid string) (*model.ContactList, error)
+	SaveContactList(contactList *model.ContactList) error
+	DeleteContactList(uuid string) error
+}
+
+type contactListDAOImpl struct {
+	db *gorm.DB
+}
+
+func NewContactListDAO(db *gorm.DB) ContactListDAO {
+	return &contactListDAOImpl{db: db}
+}
+
+func (dao *contactListDAOImpl) CreateContactList(contactList *model.ContactList) error {
+	return dao.db.Create(contactList).Error
+}
+
+func (dao *contactListDAOImpl) GetContactListsByOwner(ownerId string) ([]model.ContactList, error) {
+	var contactLists []model.ContactList
+	err := dao.db.Order("created_at ASC").Where("owner_id = ?", ownerId).Find(&contactLists).Error
+	return contactLists, err
+}
+
+func (dao *contactListDAOImpl) GetContactListByUUID(uuid string) (*model.ContactList, error) {
+	var contactList model.ContactList
+	err := dao.db.Where("uuid = ?", uuid).First(&contactList).Error
+	if err != nil {
+		return nil, err
+	}
+	return &contactList, nil
+}
+
+func (dao *contactListDAOImpl) SaveContactList(contactList *model.ContactList) error {
+	return dao.db.Save(contactList).Error
+}
+
+func (dao *contactListDAOImpl) DeleteContactList(uuid string) error {
+	return dao.db.Where("uuid = ?", uuid).Delete(&model.ContactList{}).Error
+}
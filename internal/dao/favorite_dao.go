@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"kama_chat_server/internal/model"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type FavoriteDAO interface {
+	CreateFavorite(favorite *model.Favorite) error
+	GetFavoriteByUUID(uuid string) (*model.Favorite, error)
+	GetFavoriteByMessage(ownerId, messageId string) (*model.Favorite, error)
+	SearchFavorites(ownerId, keyword, tag string, offset, limit int) ([]model.Favorite, int64, error)
+	SaveFavorite(favorite *model.Favorite) error
+	DeleteFavorite(uuid string) error
+}
+
+type favoriteDAOImpl struct {
+	db *gorm.DB
+}
+
+func NewFavoriteDAO(db *gorm.DB) FavoriteDAO {
+	return &favoriteDAOImpl{db: db}
+}
+
+func (dao *favoriteDAOImpl) CreateFavorite(favorite *model.Favorite) error {
+	return dao.db.Create(favorite).Error
+}
+
+func (dao *favoriteDAOImpl) GetFavoriteByUUID(uuid string) (*model.Favorite, error) {
+	var favorite model.Favorite
+	err := dao.db.Where("uuid = ?", uuid).First(&favorite).Error
+	if err != nil {
+		return nil, err
+	}
+	return &favorite, nil
+}
+
+func (dao *favoriteDAOImpl) GetFavoriteByMessage(ownerId, messageId string) (*model.Favorite, error) {
+	var favorite model.Favorite
+	err := dao.db.Where("owner_id = ? AND message_id = ?", ownerId, messageId).First(&favorite).Error
+	if err != nil {
+		return nil, err
+	}
+	return &favorite, nil
+}
+
+// SearchFavorites 分页查询收藏，按收藏时间倒序
+// keyword匹配内容、文件名和发送者昵称，tag为空表示不按标签过滤
+func (dao *favoriteDAOImpl) SearchFavorites(ownerId, keyword, tag string, offset, limit int) ([]model.Favorite, int64, error) {
+	query := dao.db.Model(&model.Favorite{}).Where("owner_id = ?", ownerId)
+	if keyword != "" {
+		escaped := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(keyword)
+		like := "%" + escaped + "%"
+		query = query.Where("(content LIKE ? OR file_name LIKE ? OR send_name LIKE ?)", like, like, like)
+	}
+	if tag != "" {
+		query = query.Where("JSON_CONTAINS(tags, JSON_QUOTE(?))", tag)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var favorites []model.Favorite
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&favorites).Error
+	return favorites, total, err
+}
+
+func (dao *favoriteDAOImpl) SaveFavorite(favorite *model.Favorite) error {
+	return dao.db.Save(favorite).Error
+}
+
+func (dao *favoriteDAOImpl) DeleteFavorite(uuid string) error {
+	return dao.db.Where("uuid = ?", uuid).Delete(&model.Favorite{}).Error
+}
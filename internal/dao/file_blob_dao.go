@@ -0,0 +1,102 @@
+package dao
+
+import (
+	"kama_chat_server/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type FileBlobDAO interface {
+	GetBlobBySha256(sha256 string) (*model.FileBlob, error)
+	GetBlobByKey(fileKey string) (*model.FileBlob, error)
+	CreateOrTouchBlob(blob *model.FileBlob) error
+	TouchBlob(fileKey string, now time.Time) (bool, error)
+	IncrBlobRef(fileKey string) (bool, error)
+	DecrBlobRef(fileKey string) (bool, error)
+	GetGarbageBlobs(before time.Time, limit int) ([]model.FileBlob, error)
+	DeleteGarbageBlob(id int64, before time.Time) (bool, error)
+}
+
+type fileBlobDAOImpl struct {
+	db *gorm.DB
+}
+
+func NewFileBlobDAO(db *gorm.DB) FileBlobDAO {
+	return &fileBlobDAOImpl{db: db}
+}
+
+func (dao *fileBlobDAOImpl) GetBlobBySha256(sha256 string) (*model.FileBlob, error) {
+	var blob model.FileBlob
+	err := dao.db.Where("sha256 = ?", sha256).First(&blob).Error
+	if err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+func (dao *fileBlobDAOImpl) GetBlobByKey(fileKey string) (*model.FileBlob, error) {
+	var blob model.FileBlob
+	err := dao.db.Where("file_key = ?", fileKey).First(&blob).Error
+	if err != nil {
+		return nil, err
+	}
+	return &blob, nil
+}
+
+// CreateOrTouchBlob 登记上传的文件，已存在时只刷新更新时间，推迟回收
+func (dao *fileBlobDAOImpl) CreateOrTouchBlob(blob *model.FileBlob) error {
+	return dao.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at"}),
+	}).Create(blob).Error
+}
+
+// TouchBlob 刷新更新时间，推迟回收，返回该文件是否仍然存在
+func (dao *fileBlobDAOImpl) TouchBlob(fileKey string, now time.Time) (bool, error) {
+	res := dao.db.Model(&model.FileBlob{}).Where("file_key = ?", fileKey).Update("updated_at", now)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// IncrBlobRef 增加引用计数，返回该文件是否为登记过的文件
+func (dao *fileBlobDAOImpl) IncrBlobRef(fileKey string) (bool, error) {
+	res := dao.db.Model(&model.FileBlob{}).Where("file_key = ?", fileKey).Updates(map[string]interface{}{
+		"ref_count":  gorm.Expr("ref_count + 1"),
+		"updated_at": time.Now(),
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// DecrBlobRef 减少引用计数，返回该文件是否为登记过的文件
+func (dao *fileBlobDAOImpl) DecrBlobRef(fileKey string) (bool, error) {
+	res := dao.db.Model(&model.FileBlob{}).Where("file_key = ?", fileKey).Updates(map[string]interface{}{
+		"ref_count":  gorm.Expr("GREATEST(ref_count - 1, 0)"),
+		"updated_at": time.Now(),
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// GetGarbageBlobs 获取没有引用且在before之后没有再被上传或引用的文件
+func (dao *fileBlobDAOImpl) GetGarbageBlobs(before time.Time, limit int) ([]model.FileBlob, error) {
+	var blobs []model.FileBlob
+	err := dao.db.Where("ref_count = 0 AND updated_at < ?", before).Limit(limit).Find(&blobs).Error
+	return blobs, err
+}
+
+// DeleteGarbageBlob 条件删除，期间被重新上传或引用的文件不会被删除
+func (dao *fileBlobDAOImpl) DeleteGarbageBlob(id int64, before time.Time) (bool, error) {
+	res := dao.db.Where("id = ? AND ref_count = 0 AND updated_at < ?", id, before).Delete(&model.FileBlob{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
@@ -0,0 +1,93 @@
+package dao
+
+import (
+	"kama_chat_server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type FileUsageDAO interface {
+	CreateFileUsage(usage *model.FileUsage) error
+	DeleteFileUsageByKey(fileKey string) error
+	GetUserUsed(ownerId string) (int64, error)
+	GetGroupUsed(groupId string) (int64, error)
+	GetUserUsageByType(ownerId string) ([]UsageStat, error)
+	GetUserUsageByGroup(ownerId string) ([]UsageStat, error)
+	GetGroupUsageByType(groupId string) ([]UsageStat, error)
+	GetTopUsers(limit int) ([]UsageStat, error)
+	GetTopGroups(limit int) ([]UsageStat, error)
+}
+
+// UsageStat 按用户、群聊或文件用途汇总的占用，Id为用户或群聊uuid
+type UsageStat struct {
+	Id   string
+	Type int8
+	Used int64
+}
+
+type fileUsageDAOImpl struct {
+	db *gorm.DB
+}
+
+func NewFileUsageDAO(db *gorm.DB) FileUsageDAO {
+	return &fileUsageDAOImpl{db: db}
+}
+
+func (dao *fileUsageDAOImpl) CreateFileUsage(usage *model.FileUsage) error {
+	return dao.db.Create(usage).Error
+}
+
+// DeleteFileUsageByKey 文件被回收后释放所有上传者的配额
+func (dao *fileUsageDAOImpl) DeleteFileUsageByKey(fileKey string) error {
+	return dao.db.Where("file_key = ?", fileKey).Delete(&model.FileUsage{}).Error
+}
+
+func (dao *fileUsageDAOImpl) GetUserUsed(ownerId string) (int64, error) {
+	var used int64
+	err := dao.db.Model(&model.FileUsage{}).Where("owner_id = ?", ownerId).
+		Select("COALESCE(SUM(size), 0)").Scan(&used).Error
+	return used, err
+}
+
+func (dao *fileUsageDAOImpl) GetGroupUsed(groupId string) (int64, error) {
+	var used int64
+	err := dao.db.Model(&model.FileUsage{}).Where("group_id = ?", groupId).
+		Select("COALESCE(SUM(size), 0)").Scan(&used).Error
+	return used, err
+}
+
+func (dao *fileUsageDAOImpl) GetUserUsageByType(ownerId string) ([]UsageStat, error) {
+	var stats []UsageStat
+	err := dao.db.Model(&model.FileUsage{}).Where("owner_id = ?", ownerId).
+		Select("type, SUM(size) AS used").Group("type").Order("type").Scan(&stats).Error
+	return stats, err
+}
+
+// GetUserUsageByGroup 用户发送到各个群聊的文件占用
+func (dao *fileUsageDAOImpl) GetUserUsageByGroup(ownerId string) ([]UsageStat, error) {
+	var stats []UsageStat
+	err := dao.db.Model(&model.FileUsage{}).Where("owner_id = ? AND group_id != ''", ownerId).
+		Select("group_id AS id, SUM(size) AS used").Group("group_id").Order("used DESC").Scan(&stats).Error
+	return stats, err
+}
+
+func (dao *fileUsageDAOImpl) GetGroupUsageByType(groupId string) ([]UsageStat, error) {
+	var stats []UsageStat
+	err := dao.db.Model(&model.FileUsage{}).Where("group_id = ?", groupId).
+		Select("type, SUM(size) AS used").Group("type").Order("type").Scan(&stats).Error
+	return stats, err
+}
+
+func (dao *fileUsageDAOImpl) GetTopUsers(limit int) ([]UsageStat, error) {
+	var stats []UsageStat
+	err := dao.db.Model(&model.FileUsage{}).
+		Select("owner_id AS id, SUM(size) AS used").Group("owner_id").Order("used DESC").Limit(limit).Scan(&stats).Error
+	return stats, err
+}
+
+func (dao *fileUsageDAOImpl) GetTopGroups(limit int) ([]UsageStat, error) {
+	var stats []UsageStat
+	err := dao.db.Model(&model.FileUsage{}).Where("group_id != ''").
+		Select("group_id AS id, SUM(size) AS used").Group("group_id").Order("used DESC").Limit(limit).Scan(&stats).Error
+	return stats, err
+}
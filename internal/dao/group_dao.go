@@ -22,7 +22,10 @@ type GroupDAO interface {
 	CheckGroupExists(groupId string) (*model.GroupInfo, error)
 	EnterGroup(group *model.GroupInfo, contact *model.UserContact) error
 	SetGroupsStatus(uuids []string, status int8) error
+	BatchSetStorageQuota(uuids []string, quota int64) error
 	UpdateGroupWithSessions(group *model.GroupInfo) error
+	UpdateGroupAvatar(groupId, oldAvatar, avatar string, generated int8) (bool, error)
+	GetGeneratedAvatarGroupIds(memberId string) ([]string, error)
 	RemoveGroupMembers(group *model.GroupInfo, removedUUIDs []string) error
 }
 
@@ -182,6 +185,10 @@ func (dao *groupDAOImpl) EnterGroup(group *model.GroupInfo, contact *model.UserC
 }
 
 // SetGroupsStatus 设置状态 (事务)
+func (dao *groupDAOImpl) BatchSetStorageQuota(uuids []string, quota int64) error {
+	return dao.db.Model(&model.GroupInfo{}).Where("uuid IN ?", uuids).Update("storage_quota", quota).Error
+}
+
 func (dao *groupDAOImpl) SetGroupsStatus(uuids []string, status int8) error {
 	return dao.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&model.GroupInfo{}).Where("uuid IN ?", uuids).Update("status", status).Error; err != nil {
@@ -216,6 +223,36 @@ func (dao *groupDAOImpl) UpdateGroupWithSessions(group *model.GroupInfo) error {
 	})
 }
 
+// UpdateGroupAvatar 头像仍为oldAvatar时才更新，避免覆盖期间设置的自定义头像，同时更新会话中的头像
+func (dao *groupDAOImpl) UpdateGroupAvatar(groupId, oldAvatar, avatar string, generated int8) (bool, error) {
+	updated := false
+	err := dao.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.GroupInfo{}).Where("uuid = ? AND avatar = ?", groupId, oldAvatar).Updates(map[string]interface{}{
+			"avatar":           avatar,
+			"avatar_generated": generated,
+			"updated_at":       time.Now(),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		updated = true
+		return tx.Model(&model.Session{}).Where("receive_id = ?", groupId).Update("avatar", avatar).Error
+	})
+	return updated, err
+}
+
+// GetGeneratedAvatarGroupIds 成员所在的、使用生成的群头像的群聊
+func (dao *groupDAOImpl) GetGeneratedAvatarGroupIds(memberId string) ([]string, error) {
+	var uuids []string
+	err := dao.db.Model(&model.GroupInfo{}).
+		Where("avatar_generated = ? AND JSON_CONTAINS(members, JSON_QUOTE(?))", 1, memberId).
+		Pluck("uuid", &uuids).Error
+	return uuids, err
+}
+
 // RemoveGroupMembers 移除成员 (事务)
 func (dao *groupDAOImpl) RemoveGroupMembers(group *model.GroupInfo, removedUUIDs []string) error {
 	return dao.db.Transaction(func(tx *gorm.DB) error {
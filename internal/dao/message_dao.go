@@ -2,6 +2,7 @@ package dao
 
 import (
 	"kama_chat_server/internal/model"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -9,6 +10,10 @@ import (
 type MessageDAO interface {
 	GetMessageListByUserID(userOneID, UserTwoID string) ([]*model.Message, error)
 	GetMessageListByGroupID(groupID string) ([]*model.Message, error)
+	CreateMessage(message *model.Message) error
+	GetMessageByUUID(uuid string) (*model.Message, error)
+	GetExpiredMessages(now time.Time, limit int) ([]model.Message, error)
+	DeleteMessages(uuids []string) error
 }
 
 type messageDAOImpl struct {
@@ -33,9 +38,35 @@ func (dao *messageDAOImpl) GetMessageListByUserID(userOneID, UserTwoID string) (
 func (dao *messageDAOImpl) GetMessageListByGroupID(groupID string) ([]*model.Message, error) {
 	var messages []*model.Message
 
-	err := dao.db.Where("group_id = ?", groupID).
+	err := dao.db.Where("receive_id = ?", groupID).
 		Order("created_at ASC").
 		Find(&messages).Error
 
 	return messages, err
 }
+
+func (dao *messageDAOImpl) CreateMessage(message *model.Message) error {
+	return dao.db.Create(message).Error
+}
+
+func (dao *messageDAOImpl) GetMessageByUUID(uuid string) (*model.Message, error) {
+	var message model.Message
+	err := dao.db.Where("uuid = ?", uuid).First(&message).Error
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (dao *messageDAOImpl) GetExpiredMessages(now time.Time, limit int) ([]model.Message, error) {
+	var messages []model.Message
+	err := dao.db.Where("expire_at IS NOT NULL AND expire_at <= ?", now).
+		Order("expire_at ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+func (dao *messageDAOImpl) DeleteMessages(uuids []string) error {
+	return dao.db.Where("uuid IN ?", uuids).Delete(&model.Message{}).Error
+}
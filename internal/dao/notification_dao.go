@@ -0,0 +1,38 @@
+package dao
+
+import (
+	"kama_chat_server/internal/model"
+	"kama_chat_server/pkg/enum/notification/notification_status_enum"
+
+	"gorm.io/gorm"
+)
+
+type NotificationDAO interface {
+	CreateNotification(notification *model.Notification) error
+	GetUnsentNotifications(userId string) ([]*model.Notification, error)
+	UpdateNotificationStatus(uuid string, status int8) error
+}
+
+type notificationDAOImpl struct {
+	db *gorm.DB
+}
+
+func NewNotificationDAO(db *gorm.DB) NotificationDAO {
+	return &notificationDAOImpl{db: db}
+}
+
+func (dao *notificationDAOImpl) CreateNotification(notification *model.Notification) error {
+	return dao.db.Create(notification).Error
+}
+
+func (dao *notificationDAOImpl) GetUnsentNotifications(userId string) ([]*model.Notification, error) {
+	var notifications []*model.Notification
+	err := dao.db.Order("created_at ASC").
+		Where("user_id = ? AND status = ?", userId, notification_status_enum.UNSENT).
+		Find(&notifications).Error
+	return notifications, err
+}
+
+func (dao *notificationDAOImpl) UpdateNotificationStatus(uuid string, status int8) error {
+	return dao.db.Model(&model.Notification{}).Where("uuid = ?", uuid).Update("status", status).Error
+}
@@ -0,0 +1,107 @@
+package dao
+
+import (
+	"kama_chat_server/internal/model"
+	"kama_chat_server/pkg/enum/scheduled_message/scheduled_message_status_enum"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ScheduledMessageDAO interface {
+	CreateScheduledMessage(scheduledMessage *model.ScheduledMessage) error
+	GetScheduledMessageByUUID(uuid string) (*model.ScheduledMessage, error)
+	GetPendingScheduledMessages(sendId string) ([]model.ScheduledMessage, error)
+	CountPendingScheduledMessages(sendId string) (int64, error)
+	GetDueScheduledMessages(now time.Time, after *model.ScheduledMessage, limit int) ([]model.ScheduledMessage, error)
+	UpdatePendingScheduledMessage(uuid string, updates map[string]interface{}) (bool, error)
+	UpdateScheduledMessageStatus(uuid string, status int8, failReason string) error
+	ClaimDueScheduledMessage(uuid string, now time.Time) (bool, error)
+}
+
+type scheduledMessageDAOImpl struct {
+	db *gorm.DB
+}
+
+func NewScheduledMessageDAO(db *gorm.DB) ScheduledMessageDAO {
+	return &scheduledMessageDAOImpl{db: db}
+}
+
+func (dao *scheduledMessageDAOImpl) CreateScheduledMessage(scheduledMessage *model.ScheduledMessage) error {
+	return dao.db.Create(scheduledMessage).Error
+}
+
+func (dao *scheduledMessageDAOImpl) GetScheduledMessageByUUID(uuid string) (*model.ScheduledMessage, error) {
+	var scheduledMessage model.ScheduledMessage
+	err := dao.db.Where("uuid = ?", uuid).First(&scheduledMessage).Error
+	if err != nil {
+		return nil, err
+	}
+	return &scheduledMessage, nil
+}
+
+func (dao *scheduledMessageDAOImpl) GetPendingScheduledMessages(sendId string) ([]model.ScheduledMessage, error) {
+	var scheduledMessages []model.ScheduledMessage
+	err := dao.db.Order("send_at ASC").
+		Where("send_id = ? AND status = ?", sendId, scheduled_message_status_enum.PENDING).
+		Find(&scheduledMessages).Error
+	return scheduledMessages, err
+}
+
+func (dao *scheduledMessageDAOImpl) CountPendingScheduledMessages(sendId string) (int64, error) {
+	var count int64
+	err := dao.db.Model(&model.ScheduledMessage{}).
+		Where("send_id = ? AND status = ?", sendId, scheduled_message_status_enum.PENDING).
+		Count(&count).Error
+	return count, err
+}
+
+// GetDueScheduledMessages 获取已到发送时间但还未发送的定时消息，包括服务停机期间到期的
+// 按send_at、id排序分页，after不为空时从after之后开始，本次没有处理掉的消息不会被重复取到
+func (dao *scheduledMessageDAOImpl) GetDueScheduledMessages(now time.Time, after *model.ScheduledMessage, limit int) ([]model.ScheduledMessage, error) {
+	var scheduledMessages []model.ScheduledMessage
+	query := dao.db.Order("send_at ASC, id ASC").
+		Where("status = ? AND send_at <= ?", scheduled_message_status_enum.PENDING, now)
+	if after != nil {
+		query = query.Where("send_at > ? OR (send_at = ? AND id > ?)", after.SendAt, after.SendAt, after.Id)
+	}
+	err := query.Limit(limit).Find(&scheduledMessages).Error
+	return scheduledMessages, err
+}
+
+// UpdatePendingScheduledMessage 只修改仍处于等待发送状态的定时消息，返回是否修改成功
+// 用条件更新保证编辑、取消与定时发送之间不会互相覆盖，同一条消息也不会被发送两次
+func (dao *scheduledMessageDAOImpl) UpdatePendingScheduledMessage(uuid string, updates map[string]interface{}) (bool, error) {
+	res := dao.db.Model(&model.ScheduledMessage{}).
+		Where("uuid = ? AND status = ?", uuid, scheduled_message_status_enum.PENDING).
+		Updates(updates)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func (dao *scheduledMessageDAOImpl) UpdateScheduledMessageStatus(uuid string, status int8, failReason string) error {
+	return dao.db.Model(&model.ScheduledMessage{}).
+		Where("uuid = ?", uuid).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"fail_reason": failReason,
+			"updated_at":  time.Now(),
+		}).Error
+}
+
+// ClaimDueScheduledMessage 将已到期且仍在等待发送的定时消息抢占为已发送，返回是否抢占成功
+// 抢占前用户可能刚修改了发送时间或取消，所以需要同时判断状态和发送时间
+func (dao *scheduledMessageDAOImpl) ClaimDueScheduledMessage(uuid string, now time.Time) (bool, error) {
+	res := dao.db.Model(&model.ScheduledMessage{}).
+		Where("uuid = ? AND status = ? AND send_at <= ?", uuid, scheduled_message_status_enum.PENDING, now).
+		Updates(map[string]interface{}{
+			"status":     scheduled_message_status_enum.SENT,
+			"updated_at": now,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
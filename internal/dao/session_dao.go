@@ -2,6 +2,7 @@ package dao
 
 import (
 	"kama_chat_server/internal/model"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -13,8 +14,14 @@ type SessionDAO interface {
 	GetGroupSessionList(groupID string) ([]*model.Session, error)
 	GetSessionByUUID(uuid string) (*model.Session, error)
 	UpdateSession(session *model.Session) error
+	UpdateSessionColumns(uuid string, updates map[string]interface{}) error
+	ClearLastMessageBefore(sendID, receiveID string, before time.Time) error
+	ClearGroupLastMessageBefore(groupID string, before time.Time) ([]string, error)
 }
 
+// sessionListOrder 置顶会话在前，其余按最近活跃时间排序，没有消息的会话以创建时间作为活跃时间
+const sessionListOrder = "is_pinned DESC, COALESCE(last_message_at, created_at) DESC"
+
 type sessionDAOImpl struct {
 	db *gorm.DB
 }
@@ -36,14 +43,14 @@ func (dao *sessionDAOImpl) GetSessionBySendAndReceive(sendID, receiveID string)
 
 func (dao *sessionDAOImpl) GetUserSessionList(ownerID string) ([]*model.Session, error) {
 	var sessions []*model.Session
-	err := dao.db.Order("created_at DESC").
+	err := dao.db.Order(sessionListOrder).
 		Where("send_id = ? AND receive_id LIKE 'U%'", ownerID).
 		Find(&sessions).Error
 	return sessions, err
 }
 func (dao *sessionDAOImpl) GetGroupSessionList(ownerID string) ([]*model.Session, error) {
 	var sessions []*model.Session
-	err := dao.db.Order("created_at DESC").
+	err := dao.db.Order(sessionListOrder).
 		Where("send_id = ? AND receive_id LIKE 'G%'", ownerID).
 		Find(&sessions).Error
 	return sessions, err
@@ -59,3 +66,28 @@ func (dao *sessionDAOImpl) GetSessionByUUID(uuid string) (*model.Session, error)
 func (dao *sessionDAOImpl) UpdateSession(session *model.Session) error {
 	return dao.db.Save(session).Error
 }
+
+// UpdateSessionColumns 只更新指定的列，不会覆盖后台同时写入的最新消息和草稿
+func (dao *sessionDAOImpl) UpdateSessionColumns(uuid string, updates map[string]interface{}) error {
+	return dao.db.Model(&model.Session{}).Where("uuid = ?", uuid).Updates(updates).Error
+}
+
+// ClearLastMessageBefore 单聊双方的会话最新消息已过期时清空预览，保留last_message_at用于排序
+func (dao *sessionDAOImpl) ClearLastMessageBefore(sendID, receiveID string, before time.Time) error {
+	return dao.db.Model(&model.Session{}).
+		Where("(send_id = ? AND receive_id = ?) OR (send_id = ? AND receive_id = ?)", sendID, receiveID, receiveID, sendID).
+		Where("last_message_at <= ?", before).
+		Update("last_message", "").Error
+}
+
+// ClearGroupLastMessageBefore 群聊会话最新消息已过期时清空预览，返回该群聊会话所属的用户
+func (dao *sessionDAOImpl) ClearGroupLastMessageBefore(groupID string, before time.Time) ([]string, error) {
+	if err := dao.db.Model(&model.Session{}).
+		Where("receive_id = ? AND last_message_at <= ?", groupID, before).
+		Update("last_message", "").Error; err != nil {
+		return nil, err
+	}
+	var owners []string
+	err := dao.db.Model(&model.Session{}).Where("receive_id = ?", groupID).Pluck("send_id", &owners).Error
+	return owners, err
+}
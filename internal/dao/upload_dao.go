@@ -0,0 +1,100 @@
+package dao
+
+import (
+	"kama_chat_server/internal/model"
+	"kama_chat_server/pkg/enum/upload/upload_status_enum"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UploadDAO interface {
+	CreateUpload(upload *model.Upload) error
+	GetUploadByUUID(uuid string) (*model.Upload, error)
+	UpdateUploadStatus(uuid string, from int8, updates map[string]interface{}) (bool, error)
+	SaveUploadPart(part *model.UploadPart) error
+	GetUploadParts(uploadId string) ([]model.UploadPart, error)
+	DeleteUploadParts(uploadId string) error
+	GetExpiredUploads(now time.Time, limit int) ([]model.Upload, error)
+	GetUploadingSize(ownerId string) (int64, error)
+	GetGroupUploadingSize(groupId string) (int64, error)
+}
+
+type uploadDAOImpl struct {
+	db *gorm.DB
+}
+
+func NewUploadDAO(db *gorm.DB) UploadDAO {
+	return &uploadDAOImpl{db: db}
+}
+
+func (dao *uploadDAOImpl) CreateUpload(upload *model.Upload) error {
+	return dao.db.Create(upload).Error
+}
+
+func (dao *uploadDAOImpl) GetUploadByUUID(uuid string) (*model.Upload, error) {
+	var upload model.Upload
+	err := dao.db.Where("uuid = ?", uuid).First(&upload).Error
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// UpdateUploadStatus 只修改处于from状态的任务，返回是否修改成功，用于防止同一个任务被重复合并
+func (dao *uploadDAOImpl) UpdateUploadStatus(uuid string, from int8, updates map[string]interface{}) (bool, error) {
+	res := dao.db.Model(&model.Upload{}).
+		Where("uuid = ? AND status = ?", uuid, from).
+		Updates(updates)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// SaveUploadPart 记录收到的分片，同一分片重传时覆盖之前的记录
+func (dao *uploadDAOImpl) SaveUploadPart(part *model.UploadPart) error {
+	return dao.db.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"size", "sha256", "created_at"}),
+	}).Create(part).Error
+}
+
+func (dao *uploadDAOImpl) GetUploadParts(uploadId string) ([]model.UploadPart, error) {
+	var parts []model.UploadPart
+	err := dao.db.Order("part_index ASC").Where("upload_id = ?", uploadId).Find(&parts).Error
+	return parts, err
+}
+
+func (dao *uploadDAOImpl) DeleteUploadParts(uploadId string) error {
+	return dao.db.Where("upload_id = ?", uploadId).Delete(&model.UploadPart{}).Error
+}
+
+// GetExpiredUploads 获取过期仍未完成的上传任务，用于清理分片
+// 合并中的任务过期说明合并时服务异常退出，也一起清理
+func (dao *uploadDAOImpl) GetExpiredUploads(now time.Time, limit int) ([]model.Upload, error) {
+	var uploads []model.Upload
+	err := dao.db.Where("status IN ? AND expire_at <= ?",
+		[]int8{upload_status_enum.UPLOADING, upload_status_enum.COMPLETING}, now).
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
+
+// GetUploadingSize 用户未完成的上传任务的文件总大小，创建任务时预占配额，避免并发上传超出配额
+func (dao *uploadDAOImpl) GetUploadingSize(ownerId string) (int64, error) {
+	var size int64
+	err := dao.db.Model(&model.Upload{}).Where("owner_id = ? AND status IN ?",
+		ownerId, []int8{upload_status_enum.UPLOADING, upload_status_enum.COMPLETING}).
+		Select("COALESCE(SUM(file_size), 0)").Scan(&size).Error
+	return size, err
+}
+
+// GetGroupUploadingSize 发送到群聊的未完成上传任务的文件总大小
+func (dao *uploadDAOImpl) GetGroupUploadingSize(groupId string) (int64, error) {
+	var size int64
+	err := dao.db.Model(&model.Upload{}).Where("group_id = ? AND status IN ?",
+		groupId, []int8{upload_status_enum.UPLOADING, upload_status_enum.COMPLETING}).
+		Select("COALESCE(SUM(file_size), 0)").Scan(&size).Error
+	return size, err
+}
@@ -7,6 +7,8 @@ import (
 
 	"kama_chat_server/internal/model"
 	"kama_chat_server/pkg/enum/contact/contact_status_enum"
+	"kama_chat_server/pkg/enum/contact/contact_type_enum"
+	"kama_chat_server/pkg/enum/contact_apply/contact_apply_status_enum"
 )
 
 type UserContactDAO interface {
@@ -22,6 +24,17 @@ type UserContactDAO interface {
 	DeleteContactCascade(ownerId, contactId string, deletedAt gorm.DeletedAt) error
 	BlackContactCascade(ownerId, contactId string, deletedAt gorm.DeletedAt, updatedAt time.Time) error
 	CancelBlackContact(ownerId, contactId string, updatedAt time.Time) error
+	GetMutualContactCount(userId, otherId string) (int64, error)
+	GetMutualContactCandidates(userId string) ([]ContactCandidate, error)
+	GetSharedGroupCandidates(userId string) ([]ContactCandidate, error)
+	GetRecommendExcludedIds(userId string) ([]string, error)
+	SetContactMessageTtl(userId, contactId string, ttl int, updatedAt time.Time) error
+}
+
+// ContactCandidate 推荐好友候选人，Count为共同好友数或共同群聊数
+type ContactCandidate struct {
+	UserId string
+	Count  int64
 }
 
 type userContactDAOImpl struct {
@@ -172,3 +185,76 @@ func (dao *userContactDAOImpl) CancelBlackContact(ownerId, contactId string, upd
 		return nil
 	})
 }
+
+// GetMutualContactCount 获取两个用户的共同好友数量
+func (dao *userContactDAOImpl) GetMutualContactCount(userId, otherId string) (int64, error) {
+	var count int64
+	err := dao.db.Table("user_contact AS a").
+		Joins("JOIN user_contact AS b ON a.contact_id = b.contact_id").
+		Where("a.user_id = ? AND b.user_id = ?", userId, otherId).
+		Where("a.contact_type = ? AND b.contact_type = ?", contact_type_enum.USER, contact_type_enum.USER).
+		Where("a.status = ? AND b.status = ?", contact_status_enum.NORMAL, contact_status_enum.NORMAL).
+		Where("a.deleted_at IS NULL AND b.deleted_at IS NULL").
+		Count(&count).Error
+	return count, err
+}
+
+// getCommonContactCandidates 获取与userId有共同联系人（好友或群聊）的用户及共同数量
+func (dao *userContactDAOImpl) getCommonContactCandidates(userId string, contactType int8) ([]ContactCandidate, error) {
+	var candidates []ContactCandidate
+	err := dao.db.Table("user_contact AS a").
+		Select("b.user_id AS user_id, COUNT(*) AS count").
+		Joins("JOIN user_contact AS b ON a.contact_id = b.contact_id").
+		Where("a.user_id = ? AND b.user_id != ?", userId, userId).
+		Where("a.contact_type = ? AND b.contact_type = ?", contactType, contactType).
+		Where("a.status = ? AND b.status = ?", contact_status_enum.NORMAL, contact_status_enum.NORMAL).
+		Where("a.deleted_at IS NULL AND b.deleted_at IS NULL").
+		Group("b.user_id").
+		Scan(&candidates).Error
+	return candidates, err
+}
+
+// GetMutualContactCandidates 获取好友的好友及共同好友数
+func (dao *userContactDAOImpl) GetMutualContactCandidates(userId string) ([]ContactCandidate, error) {
+	return dao.getCommonContactCandidates(userId, contact_type_enum.USER)
+}
+
+// GetSharedGroupCandidates 获取同群的用户及共同群聊数
+func (dao *userContactDAOImpl) GetSharedGroupCandidates(userId string) ([]ContactCandidate, error) {
+	return dao.getCommonContactCandidates(userId, contact_type_enum.GROUP)
+}
+
+// GetRecommendExcludedIds 获取不应推荐给userId的用户：
+// 与userId有过联系人关系的用户（包括已删除、拉黑、被拉黑），以及双方之间存在被拉黑的好友申请的用户
+func (dao *userContactDAOImpl) GetRecommendExcludedIds(userId string) ([]string, error) {
+	var uuids []string
+	if err := dao.db.Unscoped().Model(&model.UserContact{}).
+		Where("user_id = ? AND contact_type = ?", userId, contact_type_enum.USER).
+		Pluck("contact_id", &uuids).Error; err != nil {
+		return nil, err
+	}
+	var applies []model.ContactApply
+	if err := dao.db.Where("contact_type = ? AND status = ? AND (user_id = ? OR contact_id = ?)",
+		contact_type_enum.USER, contact_apply_status_enum.BLACK, userId, userId).
+		Find(&applies).Error; err != nil {
+		return nil, err
+	}
+	for _, apply := range applies {
+		if apply.UserId == userId {
+			uuids = append(uuids, apply.ContactId)
+		} else {
+			uuids = append(uuids, apply.UserId)
+		}
+	}
+	return uuids, nil
+}
+
+// SetContactMessageTtl 设置单聊消息过期时长，双方的联系关系同时修改
+func (dao *userContactDAOImpl) SetContactMessageTtl(userId, contactId string, ttl int, updatedAt time.Time) error {
+	return dao.db.Model(&model.UserContact{}).
+		Where("(user_id = ? AND contact_id = ?) OR (user_id = ? AND contact_id = ?)", userId, contactId, contactId, userId).
+		Updates(map[string]interface{}{
+			"message_ttl": ttl,
+			"update_at":   updatedAt,
+		}).Error
+}
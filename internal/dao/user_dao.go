@@ -2,6 +2,8 @@ package dao
 
 import (
 	"kama_chat_server/internal/model"
+	"kama_chat_server/pkg/enum/user_info/user_status_enum"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -17,8 +19,10 @@ type UserDAO interface {
 	DisableUsers(uuids []string, status int8) error
 	DeleteUsers(uuids []string) error
 	BatchSetAdmin(uuids []string, isAdmin int8) error
+	BatchSetStorageQuota(uuids []string, quota int64) error
 
 	GetUserContact(userId, contactId string) (*model.UserContact, error)
+	SearchUsersByNicknamePrefix(prefix string, excludeId string, limit int) ([]model.UserInfo, error)
 }
 
 type userDAOImpl struct {
@@ -116,8 +120,24 @@ func (dao *userDAOImpl) BatchSetAdmin(uuids []string, isAdmin int8) error {
 	return dao.db.Model(&model.UserInfo{}).Where("uuid in ?", uuids).Update("is_admin", isAdmin).Error
 }
 
+func (dao *userDAOImpl) BatchSetStorageQuota(uuids []string, quota int64) error {
+	return dao.db.Model(&model.UserInfo{}).Where("uuid in ?", uuids).Update("storage_quota", quota).Error
+}
+
 func (dao *userDAOImpl) GetUserContact(userId, contactId string) (*model.UserContact, error) {
 	var contact model.UserContact
 	err := dao.db.Where("user_id = ? AND contact_id = ?", userId, contactId).First(&contact).Error
 	return &contact, err
 }
+
+// SearchUsersByNicknamePrefix 按昵称前缀搜索正常状态的用户
+func (dao *userDAOImpl) SearchUsersByNicknamePrefix(prefix string, excludeId string, limit int) ([]model.UserInfo, error) {
+	var users []model.UserInfo
+	// 转义LIKE通配符，保证只做前缀匹配
+	escaped := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(prefix)
+	err := dao.db.Where("nickname LIKE ? AND uuid != ? AND status = ?", escaped+"%", excludeId, user_status_enum.NORMAL).
+		Order("nickname ASC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
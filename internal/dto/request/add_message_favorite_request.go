@@ -0,0 +1,7 @@
+package request
+
+type AddMessageFavoriteRequest struct {
+	OwnerId   string   `json:"owner_id"`
+	MessageId string   `json:"message_id"`
+	Tags      []string `json:"tags"`
+}
@@ -0,0 +1,7 @@
+package request
+
+type AddNoteFavoriteRequest struct {
+	OwnerId string   `json:"owner_id"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+}
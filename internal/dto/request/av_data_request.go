@@ -3,4 +3,5 @@ package request
 type AVData struct {
 	MessageId string `json:"messageId"`
 	Type      string `json:"type"`
+	TargetId  string `json:"targetId"` // 群通话中sdp、candidate的接收者
 }
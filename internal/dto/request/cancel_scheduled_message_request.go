@@ -0,0 +1,6 @@
+package request
+
+type CancelScheduledMessageRequest struct {
+	OwnerId            string `json:"owner_id"`
+	ScheduledMessageId string `json:"scheduled_message_id"`
+}
@@ -1,16 +1,19 @@
 package request
 
 type ChatMessageRequest struct {
-	SessionId  string `json:"session_id"`
-	Type       int8   `json:"type"`
-	Content    string `json:"content"`
-	Url        string `json:"url"`
-	SendId     string `json:"send_id"`
-	SendName   string `json:"send_name"`
-	SendAvatar string `json:"send_avatar"`
-	ReceiveId  string `json:"receive_id"`
-	FileSize   string `json:"file_size"`
-	FileType   string `json:"file_type"`
-	FileName   string `json:"file_name"`
-	AVdata     string `json:"av_data"`
+	SessionId  string           `json:"session_id"`
+	Type       int8             `json:"type"`
+	Content    string           `json:"content"`
+	Url        string           `json:"url"`
+	SendId     string           `json:"send_id"`
+	SendName   string           `json:"send_name"`
+	SendAvatar string           `json:"send_avatar"`
+	ReceiveId  string           `json:"receive_id"`
+	FileSize   string           `json:"file_size"`
+	FileType   string           `json:"file_type"`
+	FileName   string           `json:"file_name"`
+	Duration   int              `json:"duration"` // 语音时长，单位秒
+	Location   *LocationPayload `json:"location"`
+	CardId     string           `json:"card_id"` // 名片对应的用户或群聊uuid
+	AVdata     string           `json:"av_data"`
 }
@@ -0,0 +1,7 @@
+package request
+
+type CreateContactListRequest struct {
+	OwnerId string   `json:"owner_id"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
@@ -0,0 +1,6 @@
+package request
+
+type DeleteContactListRequest struct {
+	OwnerId string `json:"owner_id"`
+	ListId  string `json:"list_id"`
+}
@@ -0,0 +1,6 @@
+package request
+
+type DeleteFavoriteRequest struct {
+	OwnerId    string `json:"owner_id"`
+	FavoriteId string `json:"favorite_id"`
+}
@@ -0,0 +1,8 @@
+package request
+
+type GetCallHistoryRequest struct {
+	OwnerId   string `json:"owner_id"`
+	ContactId string `json:"contact_id"` // 为空表示查看所有通话记录
+	Page      int    `json:"page"`       // 从1开始
+	PageSize  int    `json:"page_size"`
+}
@@ -1,5 +1,6 @@
 package request
 
 type GetContactInfoRequest struct {
+	OwnerId   string `json:"owner_id"` // 查看者
 	ContactId string `json:"contact_id"`
 }
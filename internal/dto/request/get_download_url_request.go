@@ -0,0 +1,8 @@
+package request
+
+// GetDownloadUrlRequest 消息和收藏二选一，优先使用消息
+type GetDownloadUrlRequest struct {
+	OwnerId    string `json:"owner_id"`
+	MessageId  string `json:"message_id"`
+	FavoriteId string `json:"favorite_id"`
+}
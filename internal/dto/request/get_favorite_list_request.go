@@ -0,0 +1,9 @@
+package request
+
+type GetFavoriteListRequest struct {
+	OwnerId  string `json:"owner_id"`
+	Keyword  string `json:"keyword"` // 为空表示不搜索
+	Tag      string `json:"tag"`     // 为空表示不按标签过滤
+	Page     int    `json:"page"`    // 从1开始
+	PageSize int    `json:"page_size"`
+}
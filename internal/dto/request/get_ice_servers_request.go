@@ -0,0 +1,5 @@
+package request
+
+type GetIceServersRequest struct {
+	OwnerId string `json:"owner_id"`
+}
@@ -0,0 +1,7 @@
+package request
+
+// GetStorageUsageRequest GroupId为空时查看自己的占用，否则查看群聊的占用
+type GetStorageUsageRequest struct {
+	OwnerId string `json:"owner_id"`
+	GroupId string `json:"group_id"`
+}
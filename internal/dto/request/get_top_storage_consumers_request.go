@@ -0,0 +1,6 @@
+package request
+
+type GetTopStorageConsumersRequest struct {
+	OwnerId string `json:"owner_id"`
+	Limit   int    `json:"limit"`
+}
@@ -1,5 +1,6 @@
 package request
 
 type GetUserInfoRequest struct {
-	Uuid string `json:"uuid"`
+	OwnerId string `json:"owner_id"` // 查看者，与uuid相同时表示查看自己，为空时按陌生人处理
+	Uuid    string `json:"uuid"`
 }
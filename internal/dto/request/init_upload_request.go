@@ -0,0 +1,10 @@
+package request
+
+type InitUploadRequest struct {
+	OwnerId   string `json:"owner_id"`
+	FileName  string `json:"file_name"`
+	FileSize  int64  `json:"file_size"`
+	ChunkSize int64  `json:"chunk_size"` // 为0时使用默认分片大小
+	Sha256    string `json:"sha256"`     // 整个文件的sha256，十六进制
+	ReceiveId string `json:"receive_id"` // 发送到群聊时占用群聊配额
+}
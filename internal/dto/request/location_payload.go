@@ -0,0 +1,9 @@
+package request
+
+// LocationPayload 位置消息的内容
+type LocationPayload struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name"`
+	Address   string  `json:"address"`
+}
@@ -0,0 +1,6 @@
+package request
+
+type OwnlistFilterRequest struct {
+	OwnerId string `json:"owner_id"`
+	ListId  string `json:"list_id"` // 联系人分组uuid，为空表示不过滤
+}
@@ -0,0 +1,9 @@
+package request
+
+type QuickUploadRequest struct {
+	OwnerId   string `json:"owner_id"`
+	Sha256    string `json:"sha256"`
+	FileSize  int64  `json:"file_size"`
+	ReceiveId string `json:"receive_id"` // 发送到群聊时占用群聊配额
+	Proof     string `json:"proof"`      // 第一次请求为空，之后为hex(sha256(nonce + 文件[offset, offset+length)))
+}
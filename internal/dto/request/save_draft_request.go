@@ -0,0 +1,7 @@
+package request
+
+type SaveDraftRequest struct {
+	OwnerId   string `json:"owner_id"`
+	SessionId string `json:"session_id"`
+	Draft     string `json:"draft"` // 为空表示清除草稿
+}
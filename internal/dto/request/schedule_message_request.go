@@ -0,0 +1,14 @@
+package request
+
+type ScheduleMessageRequest struct {
+	OwnerId   string `json:"owner_id"`
+	SessionId string `json:"session_id"`
+	ReceiveId string `json:"receive_id"`
+	Type      int8   `json:"type"` // 只支持文本和文件
+	Content   string `json:"content"`
+	Url       string `json:"url"`
+	FileType  string `json:"file_type"`
+	FileName  string `json:"file_name"`
+	FileSize  string `json:"file_size"`
+	SendAt    string `json:"send_at"` // 格式为2006-01-02 15:04:05
+}
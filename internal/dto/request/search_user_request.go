@@ -0,0 +1,6 @@
+package request
+
+type SearchUserRequest struct {
+	OwnerId string `json:"owner_id"`
+	Keyword string `json:"keyword"` // 完整电话号码或昵称前缀
+}
@@ -0,0 +1,7 @@
+package request
+
+type SetContactRemarkRequest struct {
+	OwnerId   string `json:"owner_id"`
+	ContactId string `json:"contact_id"`
+	Remark    string `json:"remark"` // 为空表示清除备注
+}
@@ -0,0 +1,7 @@
+package request
+
+type SetMessageTtlRequest struct {
+	OwnerId   string `json:"owner_id"`
+	ContactId string `json:"contact_id"` // 用户或群聊uuid
+	Ttl       int    `json:"ttl"`        // 消息过期时长，单位秒，0表示关闭
+}
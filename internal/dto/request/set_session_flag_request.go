@@ -0,0 +1,8 @@
+package request
+
+// SetSessionFlagRequest 设置会话置顶、免打扰、归档
+type SetSessionFlagRequest struct {
+	OwnerId   string `json:"owner_id"`
+	SessionId string `json:"session_id"`
+	Enable    bool   `json:"enable"` // true为开启，false为取消
+}
@@ -0,0 +1,8 @@
+package request
+
+// SetStorageQuotaRequest 管理员为用户或群聊设置配额，UuidList中可以同时有用户和群聊
+type SetStorageQuotaRequest struct {
+	OwnerId  string   `json:"owner_id"`
+	UuidList []string `json:"uuid_list"`
+	Quota    int64    `json:"quota"` // 单位字节，0表示恢复默认配额
+}
@@ -0,0 +1,8 @@
+package request
+
+type UpdateContactListRequest struct {
+	OwnerId string   `json:"owner_id"`
+	ListId  string   `json:"list_id"`
+	Name    string   `json:"name"`    // 为空表示不修改
+	Members []string `json:"members"` // 为null表示不修改
+}
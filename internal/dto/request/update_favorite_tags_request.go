@@ -0,0 +1,7 @@
+package request
+
+type UpdateFavoriteTagsRequest struct {
+	OwnerId    string   `json:"owner_id"`
+	FavoriteId string   `json:"favorite_id"`
+	Tags       []string `json:"tags"`
+}
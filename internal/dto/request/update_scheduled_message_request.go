@@ -0,0 +1,8 @@
+package request
+
+type UpdateScheduledMessageRequest struct {
+	OwnerId            string `json:"owner_id"`
+	ScheduledMessageId string `json:"scheduled_message_id"`
+	Content            string `json:"content"` // 为空表示不修改，只有文本消息可以修改
+	SendAt             string `json:"send_at"` // 为空表示不修改
+}
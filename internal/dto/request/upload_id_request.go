@@ -0,0 +1,6 @@
+package request
+
+type UploadIdRequest struct {
+	OwnerId  string `json:"owner_id"`
+	UploadId string `json:"upload_id"`
+}
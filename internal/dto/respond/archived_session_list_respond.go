@@ -0,0 +1,6 @@
+package respond
+
+type ArchivedSessionListRespond struct {
+	UserSessions  []UserSessionListRespond  `json:"user_sessions"`
+	GroupSessions []GroupSessionListRespond `json:"group_sessions"`
+}
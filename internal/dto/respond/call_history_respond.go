@@ -0,0 +1,6 @@
+package respond
+
+type CallHistoryRespond struct {
+	Total int64               `json:"total"`
+	Calls []CallRecordRespond `json:"calls"`
+}
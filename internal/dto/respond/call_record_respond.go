@@ -0,0 +1,14 @@
+package respond
+
+type CallRecordRespond struct {
+	CallId     string `json:"call_id"`
+	PeerId     string `json:"peer_id"` // 通话的另一方
+	PeerName   string `json:"peer_name"`
+	PeerAvatar string `json:"peer_avatar"`
+	Outgoing   bool   `json:"outgoing"` // 是否由自己发起
+	Status     int8   `json:"status"`
+	Duration   int    `json:"duration"` // 通话时长，单位秒
+	CreatedAt  string `json:"created_at"`
+	AcceptedAt string `json:"accepted_at"` // 未接通为空
+	EndedAt    string `json:"ended_at"`
+}
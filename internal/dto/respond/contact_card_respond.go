@@ -0,0 +1,8 @@
+package respond
+
+// ContactCardRespond 名片消息的内容，名称和头像是发送时的快照
+type ContactCardRespond struct {
+	CardId string `json:"card_id"` // 用户或群聊uuid
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+}
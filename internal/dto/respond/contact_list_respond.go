@@ -0,0 +1,8 @@
+package respond
+
+type ContactListRespond struct {
+	ListId    string   `json:"list_id"`
+	Name      string   `json:"name"`
+	Members   []string `json:"members"`
+	MemberCnt int      `json:"member_cnt"`
+}
@@ -0,0 +1,7 @@
+package respond
+
+type DownloadUrlRespond struct {
+	Url          string `json:"url"`
+	ThumbnailUrl string `json:"thumbnail_url,omitempty"` // 图片消息的缩略图
+	ExpireAt     string `json:"expire_at"`
+}
@@ -0,0 +1,6 @@
+package respond
+
+type FavoriteListRespond struct {
+	Total     int64             `json:"total"`
+	Favorites []FavoriteRespond `json:"favorites"`
+}
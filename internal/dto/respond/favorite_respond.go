@@ -0,0 +1,22 @@
+package respond
+
+type FavoriteRespond struct {
+	FavoriteId       string              `json:"favorite_id"`
+	Type             int8                `json:"type"` // 0.消息，1.笔记
+	MessageId        string              `json:"message_id"`
+	MessageType      int8                `json:"message_type"`
+	SourceId         string              `json:"source_id"`
+	SendId           string              `json:"send_id"`
+	SendName         string              `json:"send_name"`
+	SendAvatar       string              `json:"send_avatar"`
+	Content          string              `json:"content"`
+	Url              string              `json:"url"`
+	FileType         string              `json:"file_type"`
+	FileName         string              `json:"file_name"`
+	FileSize         string              `json:"file_size"`
+	Location         *LocationRespond    `json:"location,omitempty"`
+	ContactCard      *ContactCardRespond `json:"contact_card,omitempty"`
+	Tags             []string            `json:"tags"`
+	MessageCreatedAt string              `json:"message_created_at"`
+	CreatedAt        string              `json:"created_at"`
+}
@@ -11,9 +11,11 @@ type GetContactInfoRespond struct {
 	ContactGender    int8            `json:"contact_gender"`
 	ContactSignature string          `json:"contact_signature"`
 	ContactBirthday  string          `json:"contact_birthday"`
+	ContactLastSeen  string          `json:"contact_last_seen"` // 最近在线时间，对查看者不可见时为空
 	ContactNotice    string          `json:"contact_notice"`
 	ContactMembers   json.RawMessage `json:"contact_members"`
 	ContactMemberCnt int             `json:"contact_member_cnt"`
 	ContactOwnerId   string          `json:"contact_owner_id"`
 	ContactAddMode   int8            `json:"contact_add_mode"`
+	MessageTtl       int             `json:"message_ttl"` // 消息过期时长，单位秒，0表示不过期
 }
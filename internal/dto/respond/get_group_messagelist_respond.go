@@ -1,15 +1,22 @@
 package respond
 
 type GetGroupMessageListRespond struct {
-	SendId     string `json:"send_id"`
-	SendName   string `json:"send_name"`
-	SendAvatar string `json:"send_avatar"`
-	ReceiveId  string `json:"receive_id"`
-	Type       int8   `json:"type"`
-	Content    string `json:"content"`
-	Url        string `json:"url"`
-	FileType   string `json:"file_type"`
-	FileName   string `json:"file_name"`
-	FileSize   string `json:"file_size"`
-	CreatedAt  string `json:"created_at"` // 先用CreatedAt排序，后面考虑改成SentAt
+	MessageId    string              `json:"message_id"`
+	SendId       string              `json:"send_id"`
+	SendName     string              `json:"send_name"`
+	SendAvatar   string              `json:"send_avatar"`
+	ReceiveId    string              `json:"receive_id"`
+	Type         int8                `json:"type"`
+	Content      string              `json:"content"`
+	Url          string              `json:"url"`
+	FileType     string              `json:"file_type"`
+	FileName     string              `json:"file_name"`
+	FileSize     string              `json:"file_size"`
+	Location     *LocationRespond    `json:"location,omitempty"`
+	ContactCard  *ContactCardRespond `json:"contact_card,omitempty"`
+	Width        int                 `json:"width"`  // 图片宽度
+	Height       int                 `json:"height"` // 图片高度
+	ThumbnailUrl string              `json:"thumbnail_url"`
+	Duration     int                 `json:"duration"`   // 语音时长，单位秒
+	CreatedAt    string              `json:"created_at"` // 先用CreatedAt排序，后面考虑改成SentAt
 }
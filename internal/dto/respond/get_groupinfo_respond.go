@@ -1,13 +1,14 @@
 package respond
 
 type GetGroupInfoRespond struct {
-	Uuid      string `json:"uuid"`
-	Name      string `json:"name"`
-	Notice    string `json:"notice"`
-	MemberCnt int    `json:"member_cnt"`
-	OwnerId   string `json:"owner_id"`
-	AddMode   int8   `json:"add_mode"`
-	Status    int8   `json:"status"`
-	Avatar    string `json:"avatar"`
-	IsDeleted bool   `json:"is_deleted"`
+	Uuid       string `json:"uuid"`
+	Name       string `json:"name"`
+	Notice     string `json:"notice"`
+	MemberCnt  int    `json:"member_cnt"`
+	OwnerId    string `json:"owner_id"`
+	AddMode    int8   `json:"add_mode"`
+	Status     int8   `json:"status"`
+	Avatar     string `json:"avatar"`
+	IsDeleted  bool   `json:"is_deleted"`
+	MessageTtl int    `json:"message_ttl"` // 消息过期时长，单位秒，0表示不过期
 }
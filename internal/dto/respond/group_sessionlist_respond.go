@@ -1,8 +1,15 @@
 package respond
 
 type GroupSessionListRespond struct {
-	SessionId string `json:"session_id"`
-	GroupName string `json:"group_name"`
-	GroupId   string `json:"group_id"`
-	Avatar    string `json:"avatar"`
+	SessionId     string `json:"session_id"`
+	GroupName     string `json:"group_name"` // 有备注时为备注名
+	GroupId       string `json:"group_id"`
+	Avatar        string `json:"avatar"`
+	Remark        string `json:"remark"`
+	LastMessage   string `json:"last_message"`    // 最新消息预览
+	LastMessageAt string `json:"last_message_at"` // 没有消息时为空
+	Draft         string `json:"draft"`           // 未发送的草稿，多端同步
+	IsPinned      bool   `json:"is_pinned"`
+	IsMuted       bool   `json:"is_muted"` // 免打扰，新消息推送时带"muted": true，前端只展示角标不弹出提醒
+	IsArchived    bool   `json:"is_archived"`
 }
@@ -0,0 +1,7 @@
+package respond
+
+type GroupStorageUsageRespond struct {
+	GroupId   string `json:"group_id"`
+	GroupName string `json:"group_name"`
+	Used      int64  `json:"used"`
+}
@@ -0,0 +1,8 @@
+package respond
+
+// IceServerRespond 对应RTCIceServer，STUN服务器不需要凭证
+type IceServerRespond struct {
+	Urls       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
@@ -0,0 +1,8 @@
+package respond
+
+// IceServersRespond 字段与RTCConfiguration一致，ice_servers可以直接用作前端的iceServers
+type IceServersRespond struct {
+	IceServers []IceServerRespond `json:"ice_servers"`
+	Ttl        int                `json:"ttl"`       // TURN凭证有效期，单位秒，过期前需要重新获取
+	ExpireAt   int64              `json:"expire_at"` // TURN凭证过期的时间戳
+}
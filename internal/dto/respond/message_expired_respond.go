@@ -0,0 +1,9 @@
+package respond
+
+// MessageExpiredRespond 通知前端删除已过期的消息
+type MessageExpiredRespond struct {
+	Type       int8     `json:"type"`    // 固定为message_type_enum.Expire
+	SendId     string   `json:"send_id"` // 单聊为其中一方，群聊为空
+	ReceiveId  string   `json:"receive_id"`
+	MessageIds []string `json:"message_ids"`
+}
@@ -0,0 +1,10 @@
+package respond
+
+// MessageFailedRespond 通知发送者消息未通过检查，没有发出
+type MessageFailedRespond struct {
+	Type        int8   `json:"type"` // 固定为message_type_enum.SendFailed
+	ReceiveId   string `json:"receive_id"`
+	MessageType int8   `json:"message_type"` // 发送失败的消息类型
+	Url         string `json:"url"`          // 语音、图片、文件消息的地址，便于前端定位失败的消息
+	Reason      string `json:"reason"`
+}
@@ -2,6 +2,7 @@ package respond
 
 type MyUserListRespond struct {
 	UserId   string `json:"user_id"`
-	UserName string `json:"user_name"`
+	UserName string `json:"user_name"` // 有备注时为备注名
 	Avatar   string `json:"avatar"`
+	Remark   string `json:"remark"`
 }
@@ -0,0 +1,14 @@
+package respond
+
+type NotificationRespond struct {
+	NotificationId string `json:"notification_id"`
+	Type           int8   `json:"type"` // 固定为message_type_enum.Notification，前端据此区分聊天消息
+	NotifyType     int8   `json:"notify_type"`
+	FromId         string `json:"from_id"`
+	FromName       string `json:"from_name"`
+	FromAvatar     string `json:"from_avatar"`
+	TargetId       string `json:"target_id"`
+	TargetName     string `json:"target_name"`
+	Content        string `json:"content"`
+	CreatedAt      string `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package respond
+
+type PrivacySettingsRespond struct {
+	Uuid                string `json:"uuid"`
+	AddMePolicy         int8   `json:"add_me_policy"`
+	ForbidPhoneSearch   int8   `json:"forbid_phone_search"`
+	SignatureVisibility int8   `json:"signature_visibility"`
+	AvatarVisibility    int8   `json:"avatar_visibility"`
+	LastSeenVisibility  int8   `json:"last_seen_visibility"`
+}
@@ -0,0 +1,11 @@
+package respond
+
+type QuickUploadRespond struct {
+	Exists   bool   `json:"exists"` // 为false时需要正常上传
+	FileName string `json:"file_name"`
+	Url      string `json:"url"`
+	// 没有proof时下发的校验，客户端用文件内容计算proof后再次请求
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Nonce  string `json:"nonce"`
+}
@@ -0,0 +1,9 @@
+package respond
+
+type RecommendUserRespond struct {
+	UserId           string `json:"user_id"`
+	Nickname         string `json:"nickname"`
+	Avatar           string `json:"avatar"`
+	MutualContactCnt int64  `json:"mutual_contact_cnt"` // 共同好友数
+	SharedGroupCnt   int64  `json:"shared_group_cnt"`   // 共同群聊数
+}
@@ -0,0 +1,7 @@
+package respond
+
+type SearchUserRespond struct {
+	UserId   string `json:"user_id"`
+	Nickname string `json:"nickname"`
+	Avatar   string `json:"avatar"`
+}
@@ -0,0 +1,8 @@
+package respond
+
+type StorageConsumerRespond struct {
+	Uuid  string `json:"uuid"`
+	Name  string `json:"name"` // 用户昵称或群名称，已删除时为空
+	Used  int64  `json:"used"`
+	Quota int64  `json:"quota"`
+}
@@ -0,0 +1,7 @@
+package respond
+
+// StorageTypeUsageRespond 按文件用途汇总的占用，Type与消息类型一致
+type StorageTypeUsageRespond struct {
+	Type int8  `json:"type"`
+	Used int64 `json:"used"`
+}
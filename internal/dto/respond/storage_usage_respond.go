@@ -0,0 +1,10 @@
+package respond
+
+// StorageUsageRespond 大小单位均为字节，Quota为0表示不限制
+type StorageUsageRespond struct {
+	Used      int64                      `json:"used"`      // 包含未完成的分片上传
+	Uploading int64                      `json:"uploading"` // 未完成的分片上传预占的大小
+	Quota     int64                      `json:"quota"`
+	Types     []StorageTypeUsageRespond  `json:"types"`
+	Groups    []GroupStorageUsageRespond `json:"groups,omitempty"` // 发送到各个群聊的占用，查看群聊时为空
+}
@@ -0,0 +1,6 @@
+package respond
+
+type TopStorageConsumersRespond struct {
+	Users  []StorageConsumerRespond `json:"users"`
+	Groups []StorageConsumerRespond `json:"groups"`
+}
@@ -0,0 +1,7 @@
+package respond
+
+type UploadAvatarRespond struct {
+	FileName string         `json:"file_name"`
+	Url      string         `json:"url"`       // 裁剪后的头像，最大边长640
+	SizeUrls map[int]string `json:"size_urls"` // 边长到缩小尺寸头像地址
+}
@@ -0,0 +1,6 @@
+package respond
+
+type UploadFileRespond struct {
+	FileName string `json:"file_name"`
+	Url      string `json:"url"` // 由存储后端生成的访问地址
+}
@@ -0,0 +1,11 @@
+package respond
+
+type UploadImageRespond struct {
+	FileName      string `json:"file_name"`
+	ThumbnailName string `json:"thumbnail_name"`
+	Url           string `json:"url"`
+	ThumbnailUrl  string `json:"thumbnail_url"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	FileSize      int64  `json:"file_size"` // 去除元数据重新编码后的大小，单位字节
+}
@@ -0,0 +1,11 @@
+package respond
+
+type UploadStatusRespond struct {
+	UploadId      string `json:"upload_id"`
+	FileName      string `json:"file_name"`
+	FileSize      int64  `json:"file_size"`
+	ChunkSize     int64  `json:"chunk_size"`
+	ChunkCount    int    `json:"chunk_count"`
+	Status        int8   `json:"status"`
+	UploadedParts []int  `json:"uploaded_parts"` // 已收到的分片序号，断点续传时跳过这些分片
+}
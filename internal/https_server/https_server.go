@@ -6,20 +6,29 @@ import (
 	v1 "kama_chat_server/api/v1"
 	"kama_chat_server/internal/config"
 	"kama_chat_server/pkg/ssl"
+	"log"
 )
 
 var GE *gin.Engine
 
 func init() {
 	GE = gin.Default()
+	// 默认信任所有代理，客户端可以伪造X-Forwarded-For绕过按ip的限流
+	if err := GE.SetTrustedProxies(config.GetConfig().MainConfig.TrustedProxies); err != nil {
+		log.Fatal(err.Error())
+	}
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{"*"}
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
 	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
 	GE.Use(cors.New(corsConfig))
 	GE.Use(ssl.TlsHandler(config.GetConfig().MainConfig.Host, config.GetConfig().MainConfig.Port))
-	GE.Static("/static/avatars", config.GetConfig().StaticAvatarPath)
-	GE.Static("/static/files", config.GetConfig().StaticFilePath)
+	// 头像和文件都通过存储后端读取，不直接暴露本地目录
+	// 头像公开访问，聊天文件只能通过/message/getDownloadUrl签发的地址下载
+	GE.GET("/static/*filepath", v1.DownloadStaticFile)
+	GE.HEAD("/static/*filepath", v1.DownloadStaticFile)
+	GE.GET("/download/*filepath", v1.DownloadFile)
+	GE.HEAD("/download/*filepath", v1.DownloadFile)
 	GE.POST("/login", v1.Login)
 	GE.POST("/register", v1.Register)
 	GE.POST("/user/updateUserInfo", v1.UpdateUserInfo)
@@ -32,6 +41,9 @@ func init() {
 	GE.POST("/user/sendSmsCode", v1.SendSmsCode)
 	GE.POST("/user/smsLogin", v1.SmsLogin)
 	GE.POST("/user/wsLogout", v1.WsLogout)
+	GE.POST("/user/getPrivacySettings", v1.GetPrivacySettings)
+	GE.POST("/user/updatePrivacySettings", v1.UpdatePrivacySettings)
+	GE.POST("/user/searchUser", v1.SearchUser)
 	GE.POST("/group/createGroup", v1.CreateGroup)
 	GE.POST("/group/loadMyGroup", v1.LoadMyGroup)
 	GE.POST("/group/checkGroupAddMode", v1.CheckGroupAddMode)
@@ -45,11 +57,17 @@ func init() {
 	GE.POST("/group/updateGroupInfo", v1.UpdateGroupInfo)
 	GE.POST("/group/getGroupMemberList", v1.GetGroupMemberList)
 	GE.POST("/group/removeGroupMembers", v1.RemoveGroupMembers)
+	GE.POST("/group/transferGroupOwner", v1.TransferGroupOwner)
 	GE.POST("/session/openSession", v1.OpenSession)
 	GE.POST("/session/getUserSessionList", v1.GetUserSessionList)
 	GE.POST("/session/getGroupSessionList", v1.GetGroupSessionList)
 	GE.POST("/session/deleteSession", v1.DeleteSession)
 	GE.POST("/session/checkOpenSessionAllowed", v1.CheckOpenSessionAllowed)
+	GE.POST("/session/getArchivedSessionList", v1.GetArchivedSessionList)
+	GE.POST("/session/pinSession", v1.PinSession)
+	GE.POST("/session/muteSession", v1.MuteSession)
+	GE.POST("/session/archiveSession", v1.ArchiveSession)
+	GE.POST("/session/saveDraft", v1.SaveDraft)
 	GE.POST("/contact/getUserList", v1.GetUserList)
 	GE.POST("/contact/loadMyJoinedGroup", v1.LoadMyJoinedGroup)
 	GE.POST("/contact/getContactInfo", v1.GetContactInfo)
@@ -62,11 +80,41 @@ func init() {
 	GE.POST("/contact/getAddGroupList", v1.GetAddGroupList)
 	GE.POST("/contact/refuseContactApply", v1.RefuseContactApply)
 	GE.POST("/contact/blackApply", v1.BlackApply)
+	GE.POST("/contact/setContactRemark", v1.SetContactRemark)
+	GE.POST("/contact/createContactList", v1.CreateContactList)
+	GE.POST("/contact/getContactLists", v1.GetContactLists)
+	GE.POST("/contact/updateContactList", v1.UpdateContactList)
+	GE.POST("/contact/deleteContactList", v1.DeleteContactList)
+	GE.POST("/contact/getRecommendList", v1.GetRecommendList)
+	GE.POST("/contact/setMessageTtl", v1.SetMessageTtl)
+	GE.POST("/favorite/addMessageFavorite", v1.AddMessageFavorite)
+	GE.POST("/favorite/addNoteFavorite", v1.AddNoteFavorite)
+	GE.POST("/favorite/getFavoriteList", v1.GetFavoriteList)
+	GE.POST("/favorite/updateFavoriteTags", v1.UpdateFavoriteTags)
+	GE.POST("/favorite/deleteFavorite", v1.DeleteFavorite)
 	GE.POST("/message/getMessageList", v1.GetMessageList)
 	GE.POST("/message/getGroupMessageList", v1.GetGroupMessageList)
 	GE.POST("/message/uploadAvatar", v1.UploadAvatar)
 	GE.POST("/message/uploadFile", v1.UploadFile)
+	GE.POST("/message/uploadVoice", v1.UploadVoice)
+	GE.POST("/message/uploadImage", v1.UploadImage)
+	GE.POST("/message/getDownloadUrl", v1.GetDownloadUrl)
+	GE.POST("/upload/initUpload", v1.InitUpload)
+	GE.POST("/upload/uploadPart", v1.UploadPart)
+	GE.POST("/upload/getUploadStatus", v1.GetUploadStatus)
+	GE.POST("/upload/completeUpload", v1.CompleteUpload)
+	GE.POST("/upload/abortUpload", v1.AbortUpload)
+	GE.POST("/upload/quickUpload", v1.QuickUpload)
+	GE.POST("/storage/getStorageUsage", v1.GetStorageUsage)
+	GE.POST("/storage/setStorageQuota", v1.SetStorageQuota)
+	GE.POST("/storage/getTopStorageConsumers", v1.GetTopStorageConsumers)
+	GE.POST("/message/scheduleMessage", v1.ScheduleMessage)
+	GE.POST("/message/getScheduledMessageList", v1.GetScheduledMessageList)
+	GE.POST("/message/updateScheduledMessage", v1.UpdateScheduledMessage)
+	GE.POST("/message/cancelScheduledMessage", v1.CancelScheduledMessage)
 	GE.POST("/chatroom/getCurContactListInChatRoom", v1.GetCurContactListInChatRoom)
+	GE.POST("/call/getCallHistory", v1.GetCallHistory)
+	GE.POST("/call/getIceServers", v1.GetIceServers)
 	GE.GET("/ws", v1.WsLogin)
 
 }
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// CallRecord 单聊音视频通话记录，呼叫时创建，通话结束后写入最终状态和时长
+type CallRecord struct {
+	Id         int64        `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid       string       `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:通话uuid"`
+	CallerId   string       `gorm:"column:caller_id;index;type:char(20);not null;comment:主叫方uuid"`
+	CalleeId   string       `gorm:"column:callee_id;index;type:char(20);not null;comment:被叫方uuid"`
+	Status     int8         `gorm:"column:status;index;not null;comment:状态，0.呼叫中，1.通话中，2.已拒绝，3.已取消，4.未接通，5.已结束，6.超时未接听"`
+	AcceptedAt sql.NullTime `gorm:"column:accepted_at;type:datetime;comment:接通时间"`
+	EndedAt    sql.NullTime `gorm:"column:ended_at;type:datetime;comment:结束时间"`
+	Duration   int          `gorm:"column:duration;not null;default:0;comment:通话时长，单位秒，未接通为0"`
+	CreatedAt  time.Time    `gorm:"column:created_at;type:datetime;not null;comment:呼叫时间"`
+	UpdatedAt  time.Time    `gorm:"column:updated_at;type:datetime;not null;comment:更新时间"`
+}
+
+func (CallRecord) TableName() string {
+	return "call_record"
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"encoding/json"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ContactList 用户自定义的联系人分组，如"同事"、"家人"
+type ContactList struct {
+	Id        int64           `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid      string          `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:分组唯一id"`
+	OwnerId   string          `gorm:"column:owner_id;index;type:char(20);not null;comment:分组所属用户uuid"`
+	Name      string          `gorm:"column:name;type:varchar(20);not null;comment:分组名称"`
+	Members   json.RawMessage `gorm:"column:members;type:json;comment:分组中的联系人uuid，用户或群聊"`
+	CreatedAt time.Time       `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
+	UpdatedAt time.Time       `gorm:"column:updated_at;type:datetime;not null;comment:更新时间"`
+	DeletedAt gorm.DeletedAt  `gorm:"column:deleted_at;index;type:datetime;comment:删除时间"`
+}
+
+func (ContactList) TableName() string {
+	return "contact_list"
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Favorite 用户收藏，收藏的消息保存一份快照，原消息或会话删除后仍可查看
+// 收藏的文件复制一份，不依赖原消息的文件
+type Favorite struct {
+	Id               int64           `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid             string          `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:收藏唯一id"`
+	OwnerId          string          `gorm:"column:owner_id;index;type:char(20);not null;comment:收藏所属用户uuid"`
+	Type             int8            `gorm:"column:type;not null;comment:收藏类型，0.消息，1.笔记"`
+	MessageId        string          `gorm:"column:message_id;index;type:char(20);comment:原消息uuid，笔记为空"`
+	MessageType      int8            `gorm:"column:message_type;not null;comment:原消息类型，笔记为文本"`
+	SourceId         string          `gorm:"column:source_id;type:char(20);comment:消息所在会话的对方uuid，用户或群聊"`
+	SendId           string          `gorm:"column:send_id;type:char(20);comment:原消息发送者uuid"`
+	SendName         string          `gorm:"column:send_name;type:varchar(20);comment:原消息发送者昵称"`
+	SendAvatar       string          `gorm:"column:send_avatar;type:varchar(255);comment:原消息发送者头像"`
+	Content          string          `gorm:"column:content;type:TEXT;comment:消息内容或笔记内容"`
+	Url              string          `gorm:"column:url;type:varchar(255);comment:收藏自己持有的文件url"`
+	FileType         string          `gorm:"column:file_type;type:char(10);comment:文件类型"`
+	FileName         string          `gorm:"column:file_name;type:varchar(50);comment:文件名"`
+	FileSize         string          `gorm:"column:file_size;type:char(20);comment:文件大小"`
+	Payload          json.RawMessage `gorm:"column:payload;type:json;comment:位置、名片等结构化消息的内容"`
+	Tags             json.RawMessage `gorm:"column:tags;type:json;comment:标签"`
+	MessageCreatedAt sql.NullTime    `gorm:"column:message_created_at;type:datetime;comment:原消息发送时间"`
+	CreatedAt        time.Time       `gorm:"column:created_at;index;type:datetime;not null;comment:收藏时间"`
+	UpdatedAt        time.Time       `gorm:"column:updated_at;type:datetime;not null;comment:更新时间"`
+	DeletedAt        gorm.DeletedAt  `gorm:"column:deleted_at;index;type:datetime;comment:删除时间"`
+}
+
+func (Favorite) TableName() string {
+	return "favorite"
+}
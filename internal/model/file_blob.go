@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+)
+
+// FileBlob 按内容sha256存储的文件，相同内容只存一份
+// 消息和收藏引用文件时增加引用计数，引用计数为0且长时间未被使用的文件会被回收
+type FileBlob struct {
+	Id          int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	Sha256      string    `gorm:"column:sha256;uniqueIndex;type:char(64);not null;comment:文件内容的sha256"`
+	FileKey     string    `gorm:"column:file_key;uniqueIndex;type:varchar(255);not null;comment:文件在存储中的key"`
+	Size        int64     `gorm:"column:size;not null;comment:文件大小，单位字节"`
+	ContentType string    `gorm:"column:content_type;type:varchar(100);comment:文件类型"`
+	RefCount    int       `gorm:"column:ref_count;index:idx_ref_count_updated_at,priority:1;not null;default:0;comment:引用计数"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;index:idx_ref_count_updated_at,priority:2;type:datetime;not null;comment:最近一次上传或引用变化的时间"`
+}
+
+func (FileBlob) TableName() string {
+	return "file_blob"
+}
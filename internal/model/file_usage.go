@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+)
+
+// FileUsage 用户上传文件占用的配额，相同内容的文件被不同用户上传时分别计入各自的配额
+// 文件从存储中回收时删除对应的记录，释放配额
+type FileUsage struct {
+	Id        int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	OwnerId   string    `gorm:"column:owner_id;index;type:char(20);not null;comment:上传者uuid"`
+	GroupId   string    `gorm:"column:group_id;index;type:char(20);comment:发送到的群聊uuid，单聊为空"`
+	FileKey   string    `gorm:"column:file_key;index;type:varchar(255);not null;comment:文件在存储中的key"`
+	Size      int64     `gorm:"column:size;not null;comment:文件大小，单位字节"`
+	Type      int8      `gorm:"column:type;not null;comment:文件用途，与消息类型一致，1.语音，2.文件，7.图片"`
+	CreatedAt time.Time `gorm:"column:created_at;type:datetime;not null;comment:上传时间"`
+}
+
+func (FileUsage) TableName() string {
+	return "file_usage"
+}
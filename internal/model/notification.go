@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+type Notification struct {
+	Id         int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid       string    `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:通知uuid"`
+	UserId     string    `gorm:"column:user_id;index;type:char(20);not null;comment:接收通知的用户uuid"`
+	Type       int8      `gorm:"column:type;not null;comment:通知类型，0.好友申请，1.好友申请通过，2.好友申请拒绝，3.加群申请，4.加群申请通过，5.加群申请拒绝，6.定时消息发送失败"`
+	FromId     string    `gorm:"column:from_id;type:char(20);not null;comment:触发通知的用户uuid"`
+	FromName   string    `gorm:"column:from_name;type:varchar(20);not null;comment:触发通知的用户昵称"`
+	FromAvatar string    `gorm:"column:from_avatar;type:varchar(255);not null;comment:触发通知的用户头像"`
+	TargetId   string    `gorm:"column:target_id;type:char(20);comment:相关对象uuid，如群聊uuid"`
+	TargetName string    `gorm:"column:target_name;type:varchar(20);comment:相关对象名称"`
+	Content    string    `gorm:"column:content;type:varchar(255);comment:通知内容"`
+	Status     int8      `gorm:"column:status;index;not null;comment:状态，0.未送达，1.已送达"`
+	CreatedAt  time.Time `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
+}
+
+func (Notification) TableName() string {
+	return "notification"
+}
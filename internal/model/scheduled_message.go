@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+)
+
+// ScheduledMessage 定时消息，到期后由服务端代替用户发送
+// 持久化在数据库中，服务重启后未发送的定时消息会继续发送
+type ScheduledMessage struct {
+	Id         int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid       string    `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:定时消息uuid"`
+	SessionId  string    `gorm:"column:session_id;type:char(20);not null;comment:会话uuid"`
+	SendId     string    `gorm:"column:send_id;index;type:char(20);not null;comment:发送者uuid"`
+	ReceiveId  string    `gorm:"column:receive_id;type:char(20);not null;comment:接受者uuid，用户或群聊"`
+	Type       int8      `gorm:"column:type;not null;comment:消息类型，0.文本，2.文件"`
+	Content    string    `gorm:"column:content;type:TEXT;comment:消息内容"`
+	Url        string    `gorm:"column:url;type:char(255);comment:消息url"`
+	FileType   string    `gorm:"column:file_type;type:char(10);comment:文件类型"`
+	FileName   string    `gorm:"column:file_name;type:varchar(50);comment:文件名"`
+	FileSize   string    `gorm:"column:file_size;type:char(20);comment:文件大小"`
+	SendAt     time.Time `gorm:"column:send_at;index:idx_status_send_at,priority:2;type:datetime;not null;comment:计划发送时间"`
+	Status     int8      `gorm:"column:status;index:idx_status_send_at,priority:1;not null;comment:状态，0.等待发送，1.已发送，2.已取消，3.发送失败"`
+	FailReason string    `gorm:"column:fail_reason;type:varchar(50);comment:发送失败原因"`
+	CreatedAt  time.Time `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;type:datetime;not null;comment:更新时间"`
+}
+
+func (ScheduledMessage) TableName() string {
+	return "scheduled_message"
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+)
+
+// Upload 分片上传任务，分片先存到存储的parts下，全部上传后合并为一个文件并校验sha256
+type Upload struct {
+	Id         int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	Uuid       string    `gorm:"column:uuid;uniqueIndex;type:char(20);not null;comment:上传任务uuid"`
+	OwnerId    string    `gorm:"column:owner_id;index;type:char(20);not null;comment:上传者uuid"`
+	GroupId    string    `gorm:"column:group_id;index;type:char(20);comment:发送到的群聊uuid，占用群聊配额，单聊为空"`
+	FileName   string    `gorm:"column:file_name;type:varchar(255);not null;comment:原文件名"`
+	FileSize   int64     `gorm:"column:file_size;not null;comment:文件大小，单位字节"`
+	ChunkSize  int64     `gorm:"column:chunk_size;not null;comment:分片大小，最后一片可以更小"`
+	ChunkCount int       `gorm:"column:chunk_count;not null;comment:分片数量"`
+	Sha256     string    `gorm:"column:sha256;type:char(64);not null;comment:整个文件的sha256"`
+	FileKey    string    `gorm:"column:file_key;type:varchar(255);comment:合并后文件在存储中的key"`
+	Status     int8      `gorm:"column:status;index:idx_status_expire_at,priority:1;not null;comment:状态，0.上传中，1.合并中，2.已完成，3.校验失败，4.已取消"`
+	ExpireAt   time.Time `gorm:"column:expire_at;index:idx_status_expire_at,priority:2;type:datetime;not null;comment:未完成的任务过期时间"`
+	CreatedAt  time.Time `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;type:datetime;not null;comment:更新时间"`
+}
+
+func (Upload) TableName() string {
+	return "upload"
+}
+
+// UploadPart 已收到的分片
+type UploadPart struct {
+	Id        int64     `gorm:"column:id;primaryKey;comment:自增id"`
+	UploadId  string    `gorm:"column:upload_id;uniqueIndex:idx_upload_part;type:char(20);not null;comment:上传任务uuid"`
+	PartIndex int       `gorm:"column:part_index;uniqueIndex:idx_upload_part;not null;comment:分片序号，从0开始"`
+	Size      int64     `gorm:"column:size;not null;comment:分片大小"`
+	Sha256    string    `gorm:"column:sha256;type:char(64);not null;comment:分片的sha256"`
+	CreatedAt time.Time `gorm:"column:created_at;type:datetime;not null;comment:上传时间"`
+}
+
+func (UploadPart) TableName() string {
+	return "upload_part"
+}
@@ -11,6 +11,8 @@ type UserContact struct {
 	ContactId   string         `gorm:"column:contact_id;index;type:char(20);not null;comment:对应联系id"`
 	ContactType int8           `gorm:"column:contact_type;not null;comment:联系类型，0.用户，1.群聊"`
 	Status      int8           `gorm:"column:status;not null;comment:联系状态，0.正常，1.拉黑，2.被拉黑，3.删除好友，4.被删除好友，5.被禁言，6.退出群聊，7.被踢出群聊"`
+	Remark      string         `gorm:"column:remark;type:varchar(20);comment:备注名，仅自己可见"`
+	MessageTtl  int            `gorm:"column:message_ttl;default:0;not null;comment:单聊消息过期时长，单位秒，0表示不过期，双方一致"`
 	CreatedAt   time.Time      `gorm:"column:created_at;type:datetime;not null;comment:创建时间"`
 	UpdateAt    time.Time      `gorm:"column:update_at;type:datetime;not null;comment:更新时间"`
 	DeletedAt   gorm.DeletedAt `gorm:"column:deleted_at;type:datetime;index;comment:删除时间"`
@@ -0,0 +1,451 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	myredis "kama_chat_server/internal/service/redis"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/call/call_status_enum"
+	"kama_chat_server/pkg/enum/message/message_status_enum"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/util/random"
+	"kama_chat_server/pkg/zlog"
+	"sync"
+	"time"
+)
+
+// 通话信令中的messageId
+const (
+	avProxy      = "PROXY"       // 转发给对方的信令，type为start_call、receive_call、reject_call、sdp、candidate
+	avPeerLeave  = "PEER_LEAVE"  // 挂断
+	avCallState  = "CALL_STATE"  // 服务端推送的通话状态，type为busy、offline、in_call、timeout
+	avCallRecord = "CALL_RECORD" // 通话结束后落库的通话记录消息，type为通话的最终状态
+)
+
+// avMessageData 服务端生成的通话信令
+type avMessageData struct {
+	MessageId   string      `json:"messageId"`
+	Type        string      `json:"type,omitempty"`
+	MessageData *avCallInfo `json:"messageData,omitempty"`
+}
+
+type avCallInfo struct {
+	CallId   string `json:"callId"`
+	Duration int    `json:"duration"` // 通话时长，单位秒
+}
+
+// callStatusNames 通话记录消息中的状态
+var callStatusNames = map[int8]string{
+	call_status_enum.REJECTED:  "rejected",
+	call_status_enum.CANCELLED: "cancelled",
+	call_status_enum.MISSED:    "missed",
+	call_status_enum.ENDED:     "ended",
+	call_status_enum.TIMEOUT:   "timeout",
+}
+
+// callSession 进行中的通话
+type callSession struct {
+	record       *model.CallRecord
+	sessionId    string // 主叫方的会话，通话记录消息落在该会话中
+	callerName   string
+	callerAvatar string
+	timer        *time.Timer // 呼叫超时
+}
+
+// peer 通话中另一方的uuid
+func (s *callSession) peer(userId string) string {
+	if s.record.CallerId == userId {
+		return s.record.CalleeId
+	}
+	return s.record.CallerId
+}
+
+// callManager 进行中的单聊通话和群通话，和在线用户一样只保存在当前进程的内存中
+// 每个用户同时只能处于一个通话中（单聊或群聊），单聊通话的状态变化同步写入通话记录
+type callManager struct {
+	mutex         sync.Mutex
+	users         map[string]*callSession   // 用户uuid -> 所在的单聊通话
+	rooms         map[string]*groupCallRoom // 群聊uuid -> 进行中的群通话
+	roomUsers     map[string]*groupCallRoom // 用户uuid -> 所在的群通话
+	callRecordDao dao.CallRecordDAO
+	groupDao      dao.GroupDAO
+}
+
+var CallManager = &callManager{
+	users:         make(map[string]*callSession),
+	rooms:         make(map[string]*groupCallRoom),
+	roomUsers:     make(map[string]*groupCallRoom),
+	callRecordDao: dao.NewCallRecordDAO(dao.GormDB),
+	groupDao:      dao.NewGroupDAO(dao.GormDB),
+}
+
+// CloseUnfinishedCalls 服务启动时结束上次未结束的通话记录
+func CloseUnfinishedCalls() {
+	if err := CallManager.callRecordDao.CloseUnfinishedCallRecords(time.Now()); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// IsOnline 用户是否连接在当前服务上，也用于确认调用接口的用户持有在线的websocket连接
+func IsOnline(uuid string) bool {
+	if messageMode == "channel" {
+		ChatServer.mutex.Lock()
+		defer ChatServer.mutex.Unlock()
+		_, ok := ChatServer.Clients[uuid]
+		return ok
+	}
+	KafkaChatServer.mutex.Lock()
+	defer KafkaChatServer.mutex.Unlock()
+	_, ok := KafkaChatServer.Clients[uuid]
+	return ok
+}
+
+// busy 用户是否已经在单聊通话或群通话中，调用方持有锁
+func (m *callManager) busy(userId string) bool {
+	if _, ok := m.users[userId]; ok {
+		return true
+	}
+	_, ok := m.roomUsers[userId]
+	return ok
+}
+
+// HandleAVMessage 处理通话信令，群聊的信令交给群通话处理
+// 单聊中呼叫、接听、拒绝、挂断驱动通话状态变化，sdp和candidate只在双方处于同一个通话中时转发
+func HandleAVMessage(req *request.ChatMessageRequest) {
+	if req.ReceiveId == "" {
+		return
+	}
+	var avData request.AVData
+	if err := json.Unmarshal([]byte(req.AVdata), &avData); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if req.ReceiveId[0] == 'G' {
+		CallManager.handleGroupAVMessage(req, &avData)
+		return
+	}
+	if req.ReceiveId[0] != 'U' {
+		return
+	}
+	switch {
+	case avData.MessageId == avProxy && avData.Type == "start_call":
+		CallManager.start(req)
+	case avData.MessageId == avProxy && avData.Type == "receive_call":
+		CallManager.accept(req)
+	case avData.MessageId == avProxy && avData.Type == "reject_call":
+		CallManager.reject(req)
+	case avData.MessageId == avPeerLeave:
+		CallManager.leave(req.SendId, false)
+		// 服务端没有该通话时（如重启后）也转发，让对方结束通话界面
+		forwardAVMessage(req)
+	case avData.MessageId == avProxy:
+		if CallManager.inCall(req.SendId, req.ReceiveId) {
+			forwardAVMessage(req)
+		}
+	default:
+		forwardAVMessage(req)
+	}
+}
+
+// EndUserCall 用户下线时结束其所在的通话，并代替该用户通知对方挂断；在群通话中时退出群通话
+func EndUserCall(userId string) {
+	CallManager.leave(userId, true)
+	CallManager.leaveGroupCall("", userId)
+}
+
+// start 发起呼叫，被叫方不在线或正在通话中时直接记为未接通
+func (m *callManager) start(req *request.ChatMessageRequest) {
+	now := time.Now()
+	session := &callSession{
+		record: &model.CallRecord{
+			Uuid:      fmt.Sprintf("C%s", random.GetNowAndLenRandomString(11)),
+			CallerId:  req.SendId,
+			CalleeId:  req.ReceiveId,
+			Status:    call_status_enum.RINGING,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		sessionId:    req.SessionId,
+		callerName:   req.SendName,
+		callerAvatar: normalizePath(req.SendAvatar),
+	}
+
+	m.mutex.Lock()
+	if m.busy(req.SendId) {
+		m.mutex.Unlock()
+		sendCallState(req.SendId, req.ReceiveId, "in_call", "")
+		return
+	}
+	state := ""
+	if m.busy(req.ReceiveId) {
+		state = "busy"
+	} else if !IsOnline(req.ReceiveId) {
+		state = "offline"
+	}
+	if state != "" {
+		m.mutex.Unlock()
+		session.record.Status = call_status_enum.MISSED
+		session.record.EndedAt.Time, session.record.EndedAt.Valid = now, true
+		if err := m.callRecordDao.CreateCallRecord(session.record); err != nil {
+			zlog.Error(err.Error())
+			return
+		}
+		sendCallState(req.SendId, req.ReceiveId, state, session.record.Uuid)
+		// 主叫方发起呼叫时已经打开了本地媒体，通知其结束通话界面
+		sendPeerLeave(req.SendId, req.ReceiveId)
+		sendCallRecordMessage(session)
+		return
+	}
+	if err := m.callRecordDao.CreateCallRecord(session.record); err != nil {
+		m.mutex.Unlock()
+		zlog.Error(err.Error())
+		return
+	}
+	m.users[req.SendId] = session
+	m.users[req.ReceiveId] = session
+	session.timer = time.AfterFunc(time.Second*constants.CALL_RING_TIMEOUT, func() {
+		m.timeout(session)
+	})
+	m.mutex.Unlock()
+	forwardAVMessage(req)
+}
+
+// ringingFor 被叫方userId与peerId之间正在呼叫中的通话，调用方持有锁
+func (m *callManager) ringingFor(userId, peerId string) *callSession {
+	session, ok := m.users[userId]
+	if !ok || session.record.Status != call_status_enum.RINGING ||
+		session.record.CalleeId != userId || session.record.CallerId != peerId {
+		return nil
+	}
+	return session
+}
+
+// accept 被叫方接听
+func (m *callManager) accept(req *request.ChatMessageRequest) {
+	m.mutex.Lock()
+	session := m.ringingFor(req.SendId, req.ReceiveId)
+	if session == nil {
+		m.mutex.Unlock()
+		sendCallState(req.SendId, req.ReceiveId, "not_found", "")
+		return
+	}
+	session.timer.Stop()
+	now := time.Now()
+	session.record.Status = call_status_enum.ACCEPTED
+	session.record.AcceptedAt.Time, session.record.AcceptedAt.Valid = now, true
+	session.record.UpdatedAt = now
+	if err := m.callRecordDao.SaveCallRecord(session.record); err != nil {
+		zlog.Error(err.Error())
+	}
+	m.mutex.Unlock()
+	forwardAVMessage(req)
+}
+
+// reject 被叫方拒绝
+func (m *callManager) reject(req *request.ChatMessageRequest) {
+	m.mutex.Lock()
+	session := m.ringingFor(req.SendId, req.ReceiveId)
+	if session == nil {
+		m.mutex.Unlock()
+		return
+	}
+	m.finish(session, call_status_enum.REJECTED)
+	m.mutex.Unlock()
+	forwardAVMessage(req)
+	sendCallRecordMessage(session)
+}
+
+// leave 用户挂断或下线
+// 呼叫中主叫方离开记为取消，被叫方挂断记为拒绝，被叫方下线记为未接通；通话中离开记为结束
+func (m *callManager) leave(userId string, offline bool) {
+	m.mutex.Lock()
+	session, ok := m.users[userId]
+	if !ok {
+		m.mutex.Unlock()
+		return
+	}
+	status := int8(call_status_enum.ENDED)
+	if session.record.Status == call_status_enum.RINGING {
+		switch {
+		case session.record.CallerId == userId:
+			status = call_status_enum.CANCELLED
+		case offline:
+			status = call_status_enum.MISSED
+		default:
+			status = call_status_enum.REJECTED
+		}
+	}
+	m.finish(session, status)
+	m.mutex.Unlock()
+	if offline {
+		sendPeerLeave(session.peer(userId), userId)
+	}
+	sendCallRecordMessage(session)
+}
+
+// timeout 呼叫超时无人接听，通知双方结束通话界面
+func (m *callManager) timeout(session *callSession) {
+	m.mutex.Lock()
+	// 定时器触发前通话可能已经结束
+	if m.users[session.record.CallerId] != session || session.record.Status != call_status_enum.RINGING {
+		m.mutex.Unlock()
+		return
+	}
+	m.finish(session, call_status_enum.TIMEOUT)
+	m.mutex.Unlock()
+	callId := session.record.Uuid
+	caller, callee := session.record.CallerId, session.record.CalleeId
+	sendCallState(caller, callee, "timeout", callId)
+	sendCallState(callee, caller, "timeout", callId)
+	sendPeerLeave(caller, callee)
+	sendPeerLeave(callee, caller)
+	sendCallRecordMessage(session)
+}
+
+// inCall 两个用户是否处于同一个通话中
+func (m *callManager) inCall(userId, peerId string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	session, ok := m.users[userId]
+	return ok && session.peer(userId) == peerId
+}
+
+// finish 通话进入最终状态，写入结束时间和时长并移出进行中的通话，调用方持有锁
+func (m *callManager) finish(session *callSession, status int8) {
+	if session.timer != nil {
+		session.timer.Stop()
+	}
+	now := time.Now()
+	record := session.record
+	record.Status = status
+	record.EndedAt.Time, record.EndedAt.Valid = now, true
+	record.UpdatedAt = now
+	if record.AcceptedAt.Valid {
+		record.Duration = int(now.Sub(record.AcceptedAt.Time).Seconds())
+	}
+	delete(m.users, record.CallerId)
+	delete(m.users, record.CalleeId)
+	if err := m.callRecordDao.SaveCallRecord(record); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// avMessageBack 构造推送给前端的通话信令，不落库的信令不需要确认送达
+func avMessageBack(rsp respond.AVMessageRespond) *MessageBack {
+	rsp.Type = message_type_enum.AudioOrVideo
+	if rsp.CreatedAt == "" {
+		rsp.CreatedAt = time.Now().Format("2006-01-02 15:04:05")
+	}
+	jsonMessage, err := json.Marshal(rsp)
+	if err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return &MessageBack{Message: jsonMessage}
+}
+
+// forwardAVMessage 把信令原样转发给接收方，通话信令不回显给发送方
+func forwardAVMessage(req *request.ChatMessageRequest) {
+	if messageBack := avMessageBack(respond.AVMessageRespond{
+		SendId:     req.SendId,
+		SendName:   req.SendName,
+		SendAvatar: normalizePath(req.SendAvatar),
+		ReceiveId:  req.ReceiveId,
+		AVdata:     req.AVdata,
+	}); messageBack != nil {
+		SendMessageToUsers([]string{req.ReceiveId}, messageBack)
+	}
+}
+
+// sendServerAVMessage 推送服务端生成的信令，send_id为通话的另一方
+func sendServerAVMessage(to, peer string, data avMessageData) {
+	avData, err := json.Marshal(data)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if messageBack := avMessageBack(respond.AVMessageRespond{SendId: peer, ReceiveId: to, AVdata: string(avData)}); messageBack != nil {
+		SendMessageToUsers([]string{to}, messageBack)
+	}
+}
+
+// sendCallState 推送通话状态
+func sendCallState(to, peer, state, callId string) {
+	data := avMessageData{MessageId: avCallState, Type: state}
+	if callId != "" {
+		data.MessageData = &avCallInfo{CallId: callId}
+	}
+	sendServerAVMessage(to, peer, data)
+}
+
+// sendPeerLeave 代替离开的一方通知对方挂断，前端收到后结束通话界面
+func sendPeerLeave(to, peer string) {
+	sendServerAVMessage(to, peer, avMessageData{MessageId: avPeerLeave})
+}
+
+// callRecordContent 通话记录消息的文字
+func callRecordContent(record *model.CallRecord) string {
+	switch record.Status {
+	case call_status_enum.ENDED:
+		return fmt.Sprintf("通话时长 %02d:%02d", record.Duration/60, record.Duration%60)
+	case call_status_enum.REJECTED:
+		return "已拒绝"
+	case call_status_enum.CANCELLED:
+		return "已取消"
+	}
+	return "未接来电"
+}
+
+// sendCallRecordMessage 通话结束后以主叫方的名义给被叫方落一条通话记录消息，未接来电时被叫方上线后也能看到
+func sendCallRecordMessage(session *callSession) {
+	record := session.record
+	avData, err := json.Marshal(avMessageData{
+		MessageId:   avCallRecord,
+		Type:        callStatusNames[record.Status],
+		MessageData: &avCallInfo{CallId: record.Uuid, Duration: record.Duration},
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	message := model.Message{
+		Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
+		SessionId:  session.sessionId,
+		Type:       message_type_enum.AudioOrVideo,
+		Content:    callRecordContent(record),
+		SendId:     record.CallerId,
+		SendName:   session.callerName,
+		SendAvatar: session.callerAvatar,
+		ReceiveId:  record.CalleeId,
+		FileSize:   "0B",
+		Status:     message_status_enum.Unsent,
+		CreatedAt:  time.Now(),
+		AVdata:     string(avData),
+	}
+	SetMessageExpireAt(&message)
+	if res := dao.GormDB.Create(&message); res.Error != nil {
+		zlog.Error(res.Error.Error())
+		return
+	}
+	RecordSessionActivity(&message)
+	if err := myredis.DelKeys("message_list_"+record.CallerId+"_"+record.CalleeId, "message_list_"+record.CalleeId+"_"+record.CallerId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if messageBack := avMessageBack(respond.AVMessageRespond{
+		SendId:     message.SendId,
+		SendName:   message.SendName,
+		SendAvatar: message.SendAvatar,
+		ReceiveId:  message.ReceiveId,
+		Content:    message.Content,
+		FileSize:   message.FileSize,
+		CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+		AVdata:     message.AVdata,
+	}); messageBack != nil {
+		messageBack.Uuid = message.Uuid
+		SendMessageToUsers([]string{record.CalleeId, record.CallerId}, messageBack)
+	}
+}
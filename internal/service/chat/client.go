@@ -13,10 +13,12 @@ import (
 	myKafka "kama_chat_server/internal/service/kafka"
 	"kama_chat_server/pkg/constants"
 	"kama_chat_server/pkg/enum/message/message_status_enum"
+	"kama_chat_server/pkg/enum/notification/notification_status_enum"
 	"kama_chat_server/pkg/zlog"
 	"log"
 	"net/http"
 	"strconv"
+	"sync"
 )
 
 type MessageBack struct {
@@ -29,6 +31,15 @@ type Client struct {
 	Uuid     string
 	SendTo   chan []byte       // 给server端
 	SendBack chan *MessageBack // 给前端
+	done     chan struct{}     // 连接结束后关闭，SendBack不关闭，避免其他协程向已关闭的通道发送
+	doneOnce sync.Once
+}
+
+// close 标记连接结束，Read、Write和退出登录都可能调用
+func (c *Client) close() {
+	c.doneOnce.Do(func() {
+		close(c.done)
+	})
 }
 
 var upgrader = websocket.Upgrader{
@@ -52,6 +63,9 @@ func (c *Client) Read() {
 		_, jsonMessage, err := c.Conn.ReadMessage() // 阻塞状态
 		if err != nil {
 			zlog.Error(err.Error())
+			// 连接断开后无法再收到挂断信令，结束所在的通话
+			EndUserCall(c.Uuid)
+			c.close()
 			return // 直接断开websocket
 		} else {
 			var message = request.ChatMessageRequest{}
@@ -93,15 +107,33 @@ func (c *Client) Read() {
 // 从send通道读取消息发送给websocket
 func (c *Client) Write() {
 	zlog.Info("ws write goroutine start")
-	for messageBack := range c.SendBack { // 阻塞状态
+	for {
+		var messageBack *MessageBack
+		select {
+		case messageBack = <-c.SendBack: // 阻塞状态
+		case <-c.done:
+			return
+		}
 		// 通过 WebSocket 发送消息
 		err := c.Conn.WriteMessage(websocket.TextMessage, messageBack.Message)
 		if err != nil {
 			zlog.Error(err.Error())
+			c.close()
 			return // 直接断开websocket
 		}
 		// log.Println("已发送消息：", messageBack.Message)
 		// 说明顺利发送，修改状态为已发送
+		if messageBack.Uuid == "" {
+			// 不需要确认送达的推送，如消息过期
+			continue
+		}
+		if messageBack.Uuid[0] == 'N' {
+			// 通知
+			if err := notificationDao.UpdateNotificationStatus(messageBack.Uuid, notification_status_enum.SENT); err != nil {
+				zlog.Error(err.Error())
+			}
+			continue
+		}
 		if res := dao.GormDB.Model(&model.Message{}).Where("uuid = ?", messageBack.Uuid).Update("status", message_status_enum.Sent); res.Error != nil {
 			zlog.Error(res.Error.Error())
 		}
@@ -120,6 +152,7 @@ func NewClientInit(c *gin.Context, clientId string) {
 		Uuid:     clientId,
 		SendTo:   make(chan []byte, constants.CHANNEL_SIZE),
 		SendBack: make(chan *MessageBack, constants.CHANNEL_SIZE),
+		done:     make(chan struct{}),
 	}
 	if kafkaConfig.MessageMode == "channel" {
 		ChatServer.SendClientToLogin(client)
@@ -146,7 +179,7 @@ func ClientLogout(clientId string) (string, int) {
 			return constants.SYSTEM_ERROR, -1
 		}
 		close(client.SendTo)
-		close(client.SendBack)
+		client.close()
 	}
 	return "退出成功", 0
 }
@@ -0,0 +1,32 @@
+package chat
+
+import (
+	"database/sql"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/pkg/zlog"
+	"time"
+)
+
+// SetMessageExpireAt 消息落库前调用，按照会话的过期设置计算消息的过期时间
+// 单聊取发送者与接收者联系关系上的设置（双方一致），群聊取群聊的设置；设置修改后只影响之后的消息
+func SetMessageExpireAt(message *model.Message) {
+	var ttl int
+	var err error
+	if message.ReceiveId[0] == 'G' {
+		err = dao.GormDB.Model(&model.GroupInfo{}).
+			Where("uuid = ?", message.ReceiveId).
+			Select("message_ttl").Scan(&ttl).Error
+	} else {
+		err = dao.GormDB.Model(&model.UserContact{}).
+			Where("user_id = ? AND contact_id = ?", message.SendId, message.ReceiveId).
+			Select("message_ttl").Scan(&ttl).Error
+	}
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if ttl > 0 {
+		message.ExpireAt = sql.NullTime{Time: message.CreatedAt.Add(time.Duration(ttl) * time.Second), Valid: true}
+	}
+}
@@ -0,0 +1,50 @@
+package chat
+
+import (
+	"fmt"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/storage"
+	"kama_chat_server/pkg/enum/contact/contact_status_enum"
+	"kama_chat_server/pkg/enum/contact/contact_type_enum"
+	"time"
+)
+
+// canReferenceFile 发送者是否可以在消息中引用该文件，防止伪造url引用别人的文件后再申请下载地址
+// 自己上传过的文件（有配额记录），或者自己能看到的消息、自己的收藏中的文件（转发）可以引用
+func canReferenceFile(sendId, key string) (bool, error) {
+	var count int64
+	if err := dao.GormDB.Model(&model.FileUsage{}).Where("owner_id = ? AND file_key = ?", sendId, key).
+		Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+	url := storage.Storage.URL(key)
+	if err := dao.GormDB.Model(&model.Favorite{}).Where("owner_id = ? AND url = ?", sendId, url).
+		Count(&count).Error; err != nil || count > 0 {
+		return count > 0, err
+	}
+	groups := dao.GormDB.Model(&model.UserContact{}).Select("contact_id").
+		Where("user_id = ? AND contact_type = ? AND status = ?", sendId, contact_type_enum.GROUP, contact_status_enum.NORMAL)
+	err := dao.GormDB.Model(&model.Message{}).
+		Where("url = ? AND (expire_at IS NULL OR expire_at > ?)", url, time.Now()).
+		Where("send_id = ? OR receive_id = ? OR receive_id IN (?)", sendId, sendId, groups).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// checkMessageFile 检查语音、图片、文件消息引用的文件，并统一为存储给出的地址
+func checkMessageFile(message *model.Message) error {
+	key, ok := storage.KeyFromURL(message.Url)
+	if !ok || storage.IsAvatarKey(key) {
+		return messageError(fmt.Sprintf("文件路径不合法: %s", message.Url))
+	}
+	ok, err := canReferenceFile(message.SendId, key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return messageError("没有权限发送该文件")
+	}
+	message.Url = storage.Storage.URL(key)
+	return nil
+}
@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/service/storage"
+	"kama_chat_server/pkg/zlog"
+	"path"
+)
+
+// AcquireFile 消息或收藏引用上传的文件时调用，增加引用计数
+// 返回false表示不是按内容存储的文件（去重之前上传的旧文件或外部url），调用方自行处理
+func AcquireFile(url string) bool {
+	key, ok := storage.KeyFromURL(url)
+	if !ok {
+		return false
+	}
+	ok, err := dao.NewFileBlobDAO(dao.GormDB).IncrBlobRef(key)
+	if err != nil {
+		zlog.Error(err.Error())
+		return false
+	}
+	return ok
+}
+
+// ReleaseFile 消息过期删除、收藏取消时调用，减少引用计数，没有引用的文件由后台回收
+// 去重之前上传的旧文件没有引用计数，转发和收藏后可能被多条消息共用，无法判断是否还有引用，不删除
+func ReleaseFile(url string) {
+	key, ok := storage.KeyFromURL(url)
+	if !ok {
+		return
+	}
+	if _, err := dao.NewFileBlobDAO(dao.GormDB).DecrBlobRef(key); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// DeleteFileAndThumbnail 删除存储中的文件，以及图片对应的缩略图，并释放上传者占用的配额
+func DeleteFileAndThumbnail(key string) {
+	if err := storage.Storage.Delete(key); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := dao.NewFileUsageDAO(dao.GormDB).DeleteFileUsageByKey(key); err != nil {
+		zlog.Error(err.Error())
+	}
+	thumbnailKey := storage.FileKey(ImageThumbnailName(path.Base(key)))
+	if thumbnailKey == key {
+		return
+	}
+	if err := storage.Storage.Delete(thumbnailKey); err != nil {
+		zlog.Error(err.Error())
+	}
+}
@@ -0,0 +1,354 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/group_info/group_status_enum"
+	"kama_chat_server/pkg/util/random"
+	"kama_chat_server/pkg/zlog"
+	"time"
+)
+
+// 群通话信令，客户端发给群聊：
+// PROXY start_call 发起群通话，已有进行中的群通话时加入；PROXY join_call 加入群通话
+// PEER_LEAVE 退出群通话；PROXY sdp、candidate 通过targetId指定接收的参与者
+// 服务端推送（receive_id为群聊uuid，send_id为触发的用户）：
+// CURRENT_PEERS 加入后当前的其他参与者，由加入者向每个参与者发起连接；PEER_JOIN、PEER_LEAVE 参与者变化
+// GROUP_CALL 群成员收到的群通话状态，type为started、participants、ended、timeout、full、in_call
+const avGroupCall = "GROUP_CALL"
+
+// groupCallRoom 群聊中进行中的通话，参与者之间两两建立连接（mesh），服务端只转发信令
+type groupCallRoom struct {
+	callId       string
+	groupId      string
+	initiatorId  string
+	participants []string // 按加入顺序
+	joined       bool     // 是否有发起者以外的成员加入过
+	startedAt    time.Time
+	timer        *time.Timer // 发起后无人加入的超时
+}
+
+// remove 移除参与者，返回是否在房间中
+func (r *groupCallRoom) remove(userId string) bool {
+	for i, participant := range r.participants {
+		if participant == userId {
+			r.participants = append(r.participants[:i], r.participants[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// has 是否为参与者
+func (r *groupCallRoom) has(userId string) bool {
+	for _, participant := range r.participants {
+		if participant == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// groupCallData 群通话状态
+type groupCallData struct {
+	MessageId        string         `json:"messageId"`
+	Type             string         `json:"type,omitempty"`
+	MessageContactId string         `json:"messagecontactId,omitempty"` // 与前端聊天室信令的字段名保持一致
+	MessageData      *groupCallInfo `json:"messageData,omitempty"`
+}
+
+type groupCallInfo struct {
+	CallId       string   `json:"callId"`
+	InitiatorId  string   `json:"initiatorId,omitempty"`
+	Participants []string `json:"participants,omitempty"`
+	Duration     int      `json:"duration,omitempty"` // 群通话结束时的时长，单位秒
+}
+
+// currentPeersData 加入者收到的当前参与者列表，为空时也需要返回空数组
+type currentPeersData struct {
+	MessageId   string `json:"messageId"`
+	MessageData struct {
+		CallId         string   `json:"callId"`
+		CurContactList []string `json:"curContactList"`
+	} `json:"messageData"`
+}
+
+// groupCallMembers 群成员，群聊不存在或已被禁用时返回错误
+func (m *callManager) groupCallMembers(groupId string) ([]string, error) {
+	group, err := m.groupDao.GetGroupByUUID(groupId)
+	if err != nil {
+		return nil, err
+	}
+	if group.Status != group_status_enum.NORMAL {
+		return nil, fmt.Errorf("群聊%s已被禁用", groupId)
+	}
+	var members []string
+	if err := json.Unmarshal(group.Members, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// handleGroupAVMessage 处理发给群聊的通话信令，只有群成员可以加入
+func (m *callManager) handleGroupAVMessage(req *request.ChatMessageRequest, avData *request.AVData) {
+	switch {
+	case avData.MessageId == avProxy && (avData.Type == "start_call" || avData.Type == "join_call" || avData.Type == "receive_call"):
+		m.joinGroupCall(req)
+	case avData.MessageId == avPeerLeave:
+		m.leaveGroupCall(req.ReceiveId, req.SendId)
+	case avData.MessageId == avProxy:
+		m.relayGroupSignal(req, avData.TargetId)
+	}
+}
+
+// joinGroupCall 加入群通话，群聊中没有进行中的通话时发起新的群通话并通知在线的群成员
+func (m *callManager) joinGroupCall(req *request.ChatMessageRequest) {
+	groupId, userId := req.ReceiveId, req.SendId
+	members, err := m.groupCallMembers(groupId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	isMember := false
+	for _, member := range members {
+		if member == userId {
+			isMember = true
+			break
+		}
+	}
+	if !isMember {
+		zlog.Warn(fmt.Sprintf("用户%s不在群聊%s中，不能加入群通话", userId, groupId))
+		return
+	}
+
+	m.mutex.Lock()
+	room := m.rooms[groupId]
+	if room != nil && room.has(userId) {
+		// 重复加入时重新下发参与者列表
+		peers := m.peersOf(room, userId)
+		m.mutex.Unlock()
+		sendCurrentPeers(userId, groupId, room.callId, peers)
+		return
+	}
+	if m.busy(userId) {
+		m.mutex.Unlock()
+		sendGroupCallState([]string{userId}, userId, groupId, "in_call", nil)
+		return
+	}
+	started := false
+	if room == nil {
+		room = &groupCallRoom{
+			callId:      fmt.Sprintf("C%s", random.GetNowAndLenRandomString(11)),
+			groupId:     groupId,
+			initiatorId: userId,
+			startedAt:   time.Now(),
+		}
+		m.rooms[groupId] = room
+		started = true
+		timeoutRoom := room
+		room.timer = time.AfterFunc(time.Second*constants.CALL_RING_TIMEOUT, func() {
+			m.groupCallTimeout(timeoutRoom)
+		})
+	} else if len(room.participants) >= constants.GROUP_CALL_MAX_PARTICIPANTS {
+		callId := room.callId
+		m.mutex.Unlock()
+		sendGroupCallState([]string{userId}, userId, groupId, "full", &groupCallInfo{CallId: callId})
+		return
+	} else if !room.joined {
+		room.joined = true
+		room.timer.Stop()
+	}
+	peers := m.peersOf(room, userId)
+	room.participants = append(room.participants, userId)
+	m.roomUsers[userId] = room
+	info := &groupCallInfo{
+		CallId:       room.callId,
+		InitiatorId:  room.initiatorId,
+		Participants: append([]string(nil), room.participants...),
+	}
+	m.mutex.Unlock()
+
+	sendCurrentPeers(userId, groupId, info.CallId, peers)
+	for _, peer := range peers {
+		sendGroupAVMessage([]string{peer}, userId, groupId, groupCallData{
+			MessageId:        "PEER_JOIN",
+			MessageContactId: userId,
+			MessageData:      &groupCallInfo{CallId: info.CallId},
+		})
+	}
+	state := "participants"
+	if started {
+		state = "started"
+	}
+	sendGroupCallState(excludeUsers(members, info.Participants), userId, groupId, state, info)
+}
+
+// peersOf 房间中除userId以外的参与者，调用方持有锁
+func (m *callManager) peersOf(room *groupCallRoom, userId string) []string {
+	peers := []string{}
+	for _, participant := range room.participants {
+		if participant != userId {
+			peers = append(peers, participant)
+		}
+	}
+	return peers
+}
+
+// leaveGroupCall 退出群通话，最后一个参与者退出时群通话结束
+// groupId为空时退出用户所在的群通话
+func (m *callManager) leaveGroupCall(groupId, userId string) {
+	m.mutex.Lock()
+	room, ok := m.roomUsers[userId]
+	if !ok || (groupId != "" && room.groupId != groupId) {
+		m.mutex.Unlock()
+		return
+	}
+	room.remove(userId)
+	delete(m.roomUsers, userId)
+	ended := len(room.participants) == 0
+	if ended {
+		room.timer.Stop()
+		delete(m.rooms, room.groupId)
+	}
+	remaining := append([]string(nil), room.participants...)
+	m.mutex.Unlock()
+
+	for _, participant := range remaining {
+		sendGroupAVMessage([]string{participant}, userId, room.groupId, groupCallData{
+			MessageId:        avPeerLeave,
+			MessageContactId: userId,
+			MessageData:      &groupCallInfo{CallId: room.callId},
+		})
+	}
+	m.broadcastGroupCall(room, userId, ended, remaining)
+}
+
+// broadcastGroupCall 参与者变化后通知群成员，群通话结束时通知结束
+func (m *callManager) broadcastGroupCall(room *groupCallRoom, userId string, ended bool, remaining []string) {
+	members, err := m.groupCallMembers(room.groupId)
+	if err != nil {
+		// 群聊已解散时只能通知参与者
+		zlog.Warn(err.Error())
+		members = remaining
+	}
+	if ended {
+		sendGroupCallState(members, userId, room.groupId, "ended", &groupCallInfo{
+			CallId:   room.callId,
+			Duration: int(time.Since(room.startedAt).Seconds()),
+		})
+		return
+	}
+	sendGroupCallState(excludeUsers(members, remaining), userId, room.groupId, "participants", &groupCallInfo{
+		CallId:       room.callId,
+		InitiatorId:  room.initiatorId,
+		Participants: remaining,
+	})
+}
+
+// groupCallTimeout 发起后无人加入，结束群通话
+func (m *callManager) groupCallTimeout(room *groupCallRoom) {
+	m.mutex.Lock()
+	if m.rooms[room.groupId] != room || room.joined {
+		m.mutex.Unlock()
+		return
+	}
+	participants := room.participants
+	for _, participant := range participants {
+		delete(m.roomUsers, participant)
+	}
+	room.participants = nil
+	delete(m.rooms, room.groupId)
+	m.mutex.Unlock()
+
+	sendGroupCallState(participants, room.initiatorId, room.groupId, "timeout", &groupCallInfo{CallId: room.callId})
+	m.broadcastGroupCall(room, room.initiatorId, true, nil)
+}
+
+// relayGroupSignal 把sdp、candidate转发给同一群通话中的指定参与者
+func (m *callManager) relayGroupSignal(req *request.ChatMessageRequest, targetId string) {
+	if targetId == "" || targetId == req.SendId {
+		return
+	}
+	m.mutex.Lock()
+	room, ok := m.roomUsers[req.SendId]
+	ok = ok && room.groupId == req.ReceiveId && room.has(targetId)
+	m.mutex.Unlock()
+	if !ok {
+		return
+	}
+	if messageBack := avMessageBack(respond.AVMessageRespond{
+		SendId:     req.SendId,
+		SendName:   req.SendName,
+		SendAvatar: normalizePath(req.SendAvatar),
+		ReceiveId:  req.ReceiveId,
+		AVdata:     req.AVdata,
+	}); messageBack != nil {
+		SendMessageToUsers([]string{targetId}, messageBack)
+	}
+}
+
+// LeaveGroupCall 用户退群或被移出群聊后退出该群的群通话
+func LeaveGroupCall(groupId string, userIds ...string) {
+	for _, userId := range userIds {
+		CallManager.leaveGroupCall(groupId, userId)
+	}
+}
+
+// EndGroupCall 群聊解散时结束群通话
+func EndGroupCall(groupId string) {
+	CallManager.mutex.Lock()
+	room, ok := CallManager.rooms[groupId]
+	var participants []string
+	if ok {
+		participants = room.participants
+	}
+	CallManager.mutex.Unlock()
+	LeaveGroupCall(groupId, participants...)
+}
+
+// excludeUsers 从users中去掉excluded
+func excludeUsers(users, excluded []string) []string {
+	set := make(map[string]bool, len(excluded))
+	for _, user := range excluded {
+		set[user] = true
+	}
+	var result []string
+	for _, user := range users {
+		if !set[user] {
+			result = append(result, user)
+		}
+	}
+	return result
+}
+
+// sendGroupAVMessage 推送服务端生成的群通话信令
+func sendGroupAVMessage(to []string, sendId, groupId string, data interface{}) {
+	if len(to) == 0 {
+		return
+	}
+	avData, err := json.Marshal(data)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if messageBack := avMessageBack(respond.AVMessageRespond{SendId: sendId, ReceiveId: groupId, AVdata: string(avData)}); messageBack != nil {
+		SendMessageToUsers(to, messageBack)
+	}
+}
+
+// sendGroupCallState 推送群通话状态
+func sendGroupCallState(to []string, sendId, groupId, state string, info *groupCallInfo) {
+	sendGroupAVMessage(to, sendId, groupId, groupCallData{MessageId: avGroupCall, Type: state, MessageData: info})
+}
+
+// sendCurrentPeers 告诉加入者当前的其他参与者
+func sendCurrentPeers(to, groupId, callId string, peers []string) {
+	var data currentPeersData
+	data.MessageId = "CURRENT_PEERS"
+	data.MessageData.CallId = callId
+	data.MessageData.CurContactList = peers
+	sendGroupAVMessage([]string{to}, to, groupId, data)
+}
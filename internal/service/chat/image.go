@@ -0,0 +1,49 @@
+package chat
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/storage"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ImageThumbnailName 原图对应的缩略图文件名，jpeg的缩略图仍为jpeg，其他格式为png以保留透明
+func ImageThumbnailName(fileName string) string {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	if ext == ".jpg" || ext == ".jpeg" {
+		return base + "_thumb.jpg"
+	}
+	return base + "_thumb.png"
+}
+
+// fillImageMessage 根据服务端保存的图片补全宽高和缩略图，不信任前端传入的尺寸
+func fillImageMessage(message *model.Message) error {
+	key, ok := storage.KeyFromURL(message.Url)
+	if !ok {
+		return messageError(fmt.Sprintf("图片路径不合法: %s", message.Url))
+	}
+	file, _, err := storage.Storage.Open(key)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	imageConfig, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return err
+	}
+	thumbnailKey := storage.FileKey(ImageThumbnailName(path.Base(key)))
+	if _, err := storage.Storage.Stat(thumbnailKey); err != nil {
+		return err
+	}
+	message.Width = imageConfig.Width
+	message.Height = imageConfig.Height
+	message.ThumbnailUrl = storage.Storage.URL(thumbnailKey)
+	return nil
+}
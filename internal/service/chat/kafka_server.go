@@ -96,25 +96,33 @@ func (k *KafkaServer) Start() {
 				}
 				// 对SendAvatar去除前面/static之前的所有内容，防止ip前缀引入
 				message.SendAvatar = normalizePath(message.SendAvatar)
+				SetMessageExpireAt(&message)
 				if res := dao.GormDB.Create(&message); res.Error != nil {
 					zlog.Error(res.Error.Error())
+				} else {
+					RecordSessionActivity(&message)
 				}
 				if message.ReceiveId[0] == 'U' { // 发送给User
 					// 如果能找到ReceiveId，说明在线，可以发送，否则存表后跳过
 					// 因为在线的时候是通过websocket更新消息记录的，离线后通过存表，登录时只调用一次数据库操作
 					// 切换chat对象后，前端的messageList也会改变，获取messageList从第二次就是从redis中获取
 					messageRsp := respond.GetMessageListRespond{
-						SendId:     message.SendId,
-						SendName:   message.SendName,
-						SendAvatar: chatMessageReq.SendAvatar,
-						ReceiveId:  message.ReceiveId,
-						Type:       message.Type,
-						Content:    message.Content,
-						Url:        message.Url,
-						FileSize:   message.FileSize,
-						FileName:   message.FileName,
-						FileType:   message.FileType,
-						CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+						MessageId:    message.Uuid,
+						SendId:       message.SendId,
+						SendName:     message.SendName,
+						SendAvatar:   chatMessageReq.SendAvatar,
+						ReceiveId:    message.ReceiveId,
+						Type:         message.Type,
+						Content:      message.Content,
+						Url:          message.Url,
+						FileSize:     message.FileSize,
+						Duration:     message.Duration,
+						Width:        message.Width,
+						Height:       message.Height,
+						ThumbnailUrl: message.ThumbnailUrl,
+						FileName:     message.FileName,
+						FileType:     message.FileType,
+						CreatedAt:    message.CreatedAt.Format("2006-01-02 15:04:05"),
 					}
 					jsonMessage, err := json.Marshal(messageRsp)
 					if err != nil {
@@ -125,17 +133,19 @@ func (k *KafkaServer) Start() {
 						Message: jsonMessage,
 						Uuid:    message.Uuid,
 					}
+					receiveBack := receiverMessageBack(messageBack, message.SendId)
 					k.mutex.Lock()
 					if receiveClient, ok := k.Clients[message.ReceiveId]; ok {
 						//messageBack.Message = jsonMessage
 						//messageBack.Uuid = message.Uuid
-						receiveClient.SendBack <- messageBack // 向client.Send发送
+						receiveClient.SendBack <- receiveBack(message.ReceiveId) // 向client.Send发送
 					}
-					// 因为send_id肯定在线，所以这里在后端进行在线回显message，其实优化的话前端可以直接回显
+					// 用户自己发送时send_id肯定在线（定时消息发送时可能不在线），这里在后端进行在线回显message，其实优化的话前端可以直接回显
 					// 问题在于前后端的req和rsp结构不同，前端存储message的messageList不能存req，只能存rsp
 					// 所以这里后端进行回显，前端不回显
-					sendClient := k.Clients[message.SendId]
-					sendClient.SendBack <- messageBack
+					if sendClient, ok := k.Clients[message.SendId]; ok {
+						sendClient.SendBack <- messageBack
+					}
 					k.mutex.Unlock()
 
 					// redis
@@ -162,17 +172,22 @@ func (k *KafkaServer) Start() {
 
 				} else if message.ReceiveId[0] == 'G' { // 发送给Group
 					messageRsp := respond.GetGroupMessageListRespond{
-						SendId:     message.SendId,
-						SendName:   message.SendName,
-						SendAvatar: chatMessageReq.SendAvatar,
-						ReceiveId:  message.ReceiveId,
-						Type:       message.Type,
-						Content:    message.Content,
-						Url:        message.Url,
-						FileSize:   message.FileSize,
-						FileName:   message.FileName,
-						FileType:   message.FileType,
-						CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+						MessageId:    message.Uuid,
+						SendId:       message.SendId,
+						SendName:     message.SendName,
+						SendAvatar:   chatMessageReq.SendAvatar,
+						ReceiveId:    message.ReceiveId,
+						Type:         message.Type,
+						Content:      message.Content,
+						Url:          message.Url,
+						FileSize:     message.FileSize,
+						Duration:     message.Duration,
+						Width:        message.Width,
+						Height:       message.Height,
+						ThumbnailUrl: message.ThumbnailUrl,
+						FileName:     message.FileName,
+						FileType:     message.FileType,
+						CreatedAt:    message.CreatedAt.Format("2006-01-02 15:04:05"),
 					}
 					jsonMessage, err := json.Marshal(messageRsp)
 					if err != nil {
@@ -191,15 +206,17 @@ func (k *KafkaServer) Start() {
 					if err := json.Unmarshal(group.Members, &members); err != nil {
 						zlog.Error(err.Error())
 					}
+					receiveBack := receiverMessageBack(messageBack, message.ReceiveId)
 					k.mutex.Lock()
 					for _, member := range members {
 						if member != message.SendId {
 							if receiveClient, ok := k.Clients[member]; ok {
-								receiveClient.SendBack <- messageBack
+								receiveClient.SendBack <- receiveBack(member)
 							}
 						} else {
-							sendClient := k.Clients[message.SendId]
-							sendClient.SendBack <- messageBack
+							if sendClient, ok := k.Clients[message.SendId]; ok {
+								sendClient.SendBack <- messageBack
+							}
 						}
 					}
 					k.mutex.Unlock()
@@ -226,7 +243,8 @@ func (k *KafkaServer) Start() {
 						}
 					}
 				}
-			} else if chatMessageReq.Type == message_type_enum.File {
+			} else if chatMessageReq.Type == message_type_enum.File || chatMessageReq.Type == message_type_enum.Voice || chatMessageReq.Type == message_type_enum.Image ||
+				chatMessageReq.Type == message_type_enum.Location || chatMessageReq.Type == message_type_enum.ContactCard {
 				// 存message
 				message := model.Message{
 					Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
@@ -239,6 +257,7 @@ func (k *KafkaServer) Start() {
 					SendAvatar: chatMessageReq.SendAvatar,
 					ReceiveId:  chatMessageReq.ReceiveId,
 					FileSize:   chatMessageReq.FileSize,
+					Duration:   chatMessageReq.Duration,
 					FileType:   chatMessageReq.FileType,
 					FileName:   chatMessageReq.FileName,
 					Status:     message_status_enum.Unsent,
@@ -247,25 +266,42 @@ func (k *KafkaServer) Start() {
 				}
 				// 对SendAvatar去除前面/static之前的所有内容，防止ip前缀引入
 				message.SendAvatar = normalizePath(message.SendAvatar)
+				if err := prepareMessage(&message, &chatMessageReq); err != nil {
+					zlog.Warn(fmt.Sprintf("丢弃用户%s的消息: %s", message.SendId, err.Error()))
+					sendMessageFailed(&chatMessageReq, err)
+					continue
+				}
+				SetMessageExpireAt(&message)
 				if res := dao.GormDB.Create(&message); res.Error != nil {
 					zlog.Error(res.Error.Error())
+				} else {
+					RecordSessionActivity(&message)
+					// 消息引用上传的文件，文件在消息过期删除前不会被回收
+					AcquireFile(message.Url)
 				}
 				if message.ReceiveId[0] == 'U' { // 发送给User
 					// 如果能找到ReceiveId，说明在线，可以发送，否则存表后跳过
 					// 因为在线的时候是通过websocket更新消息记录的，离线后通过存表，登录时只调用一次数据库操作
 					// 切换chat对象后，前端的messageList也会改变，获取messageList从第二次就是从redis中获取
 					messageRsp := respond.GetMessageListRespond{
-						SendId:     message.SendId,
-						SendName:   message.SendName,
-						SendAvatar: chatMessageReq.SendAvatar,
-						ReceiveId:  message.ReceiveId,
-						Type:       message.Type,
-						Content:    message.Content,
-						Url:        message.Url,
-						FileSize:   message.FileSize,
-						FileName:   message.FileName,
-						FileType:   message.FileType,
-						CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+						MessageId:    message.Uuid,
+						SendId:       message.SendId,
+						SendName:     message.SendName,
+						SendAvatar:   chatMessageReq.SendAvatar,
+						ReceiveId:    message.ReceiveId,
+						Type:         message.Type,
+						Content:      message.Content,
+						Url:          message.Url,
+						FileSize:     message.FileSize,
+						Location:     MessageLocation(&message),
+						ContactCard:  MessageContactCard(&message),
+						Duration:     message.Duration,
+						Width:        message.Width,
+						Height:       message.Height,
+						ThumbnailUrl: message.ThumbnailUrl,
+						FileName:     message.FileName,
+						FileType:     message.FileType,
+						CreatedAt:    message.CreatedAt.Format("2006-01-02 15:04:05"),
 					}
 					jsonMessage, err := json.Marshal(messageRsp)
 					if err != nil {
@@ -276,17 +312,19 @@ func (k *KafkaServer) Start() {
 						Message: jsonMessage,
 						Uuid:    message.Uuid,
 					}
+					receiveBack := receiverMessageBack(messageBack, message.SendId)
 					k.mutex.Lock()
 					if receiveClient, ok := k.Clients[message.ReceiveId]; ok {
 						//messageBack.Message = jsonMessage
 						//messageBack.Uuid = message.Uuid
-						receiveClient.SendBack <- messageBack // 向client.Send发送
+						receiveClient.SendBack <- receiveBack(message.ReceiveId) // 向client.Send发送
 					}
-					// 因为send_id肯定在线，所以这里在后端进行在线回显message，其实优化的话前端可以直接回显
+					// 用户自己发送时send_id肯定在线（定时消息发送时可能不在线），这里在后端进行在线回显message，其实优化的话前端可以直接回显
 					// 问题在于前后端的req和rsp结构不同，前端存储message的messageList不能存req，只能存rsp
 					// 所以这里后端进行回显，前端不回显
-					sendClient := k.Clients[message.SendId]
-					sendClient.SendBack <- messageBack
+					if sendClient, ok := k.Clients[message.SendId]; ok {
+						sendClient.SendBack <- messageBack
+					}
 					k.mutex.Unlock()
 
 					// redis
@@ -312,17 +350,24 @@ func (k *KafkaServer) Start() {
 					}
 				} else {
 					messageRsp := respond.GetGroupMessageListRespond{
-						SendId:     message.SendId,
-						SendName:   message.SendName,
-						SendAvatar: chatMessageReq.SendAvatar,
-						ReceiveId:  message.ReceiveId,
-						Type:       message.Type,
-						Content:    message.Content,
-						Url:        message.Url,
-						FileSize:   message.FileSize,
-						FileName:   message.FileName,
-						FileType:   message.FileType,
-						CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+						MessageId:    message.Uuid,
+						SendId:       message.SendId,
+						SendName:     message.SendName,
+						SendAvatar:   chatMessageReq.SendAvatar,
+						ReceiveId:    message.ReceiveId,
+						Type:         message.Type,
+						Content:      message.Content,
+						Url:          message.Url,
+						FileSize:     message.FileSize,
+						Location:     MessageLocation(&message),
+						ContactCard:  MessageContactCard(&message),
+						Duration:     message.Duration,
+						Width:        message.Width,
+						Height:       message.Height,
+						ThumbnailUrl: message.ThumbnailUrl,
+						FileName:     message.FileName,
+						FileType:     message.FileType,
+						CreatedAt:    message.CreatedAt.Format("2006-01-02 15:04:05"),
 					}
 					jsonMessage, err := json.Marshal(messageRsp)
 					if err != nil {
@@ -341,15 +386,17 @@ func (k *KafkaServer) Start() {
 					if err := json.Unmarshal(group.Members, &members); err != nil {
 						zlog.Error(err.Error())
 					}
+					receiveBack := receiverMessageBack(messageBack, message.ReceiveId)
 					k.mutex.Lock()
 					for _, member := range members {
 						if member != message.SendId {
 							if receiveClient, ok := k.Clients[member]; ok {
-								receiveClient.SendBack <- messageBack
+								receiveClient.SendBack <- receiveBack(member)
 							}
 						} else {
-							sendClient := k.Clients[message.SendId]
-							sendClient.SendBack <- messageBack
+							if sendClient, ok := k.Clients[message.SendId]; ok {
+								sendClient.SendBack <- messageBack
+							}
 						}
 					}
 					k.mutex.Unlock()
@@ -377,76 +424,8 @@ func (k *KafkaServer) Start() {
 					}
 				}
 			} else if chatMessageReq.Type == message_type_enum.AudioOrVideo {
-				var avData request.AVData
-				if err := json.Unmarshal([]byte(chatMessageReq.AVdata), &avData); err != nil {
-					zlog.Error(err.Error())
-				}
-				//log.Println(avData)
-				message := model.Message{
-					Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
-					SessionId:  chatMessageReq.SessionId,
-					Type:       chatMessageReq.Type,
-					Content:    "",
-					Url:        "",
-					SendId:     chatMessageReq.SendId,
-					SendName:   chatMessageReq.SendName,
-					SendAvatar: chatMessageReq.SendAvatar,
-					ReceiveId:  chatMessageReq.ReceiveId,
-					FileSize:   "",
-					FileType:   "",
-					FileName:   "",
-					Status:     message_status_enum.Unsent,
-					CreatedAt:  time.Now(),
-					AVdata:     chatMessageReq.AVdata,
-				}
-				if avData.MessageId == "PROXY" && (avData.Type == "start_call" || avData.Type == "receive_call" || avData.Type == "reject_call") {
-					// 存message
-					// 对SendAvatar去除前面/static之前的所有内容，防止ip前缀引入
-					message.SendAvatar = normalizePath(message.SendAvatar)
-					if res := dao.GormDB.Create(&message); res.Error != nil {
-						zlog.Error(res.Error.Error())
-					}
-				}
-
-				if chatMessageReq.ReceiveId[0] == 'U' { // 发送给User
-					// 如果能找到ReceiveId，说明在线，可以发送，否则存表后跳过
-					// 因为在线的时候是通过websocket更新消息记录的，离线后通过存表，登录时只调用一次数据库操作
-					// 切换chat对象后，前端的messageList也会改变，获取messageList从第二次就是从redis中获取
-					messageRsp := respond.AVMessageRespond{
-						SendId:     message.SendId,
-						SendName:   message.SendName,
-						SendAvatar: message.SendAvatar,
-						ReceiveId:  message.ReceiveId,
-						Type:       message.Type,
-						Content:    message.Content,
-						Url:        message.Url,
-						FileSize:   message.FileSize,
-						FileName:   message.FileName,
-						FileType:   message.FileType,
-						CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
-						AVdata:     message.AVdata,
-					}
-					jsonMessage, err := json.Marshal(messageRsp)
-					if err != nil {
-						zlog.Error(err.Error())
-					}
-					// log.Println("返回的消息为：", messageRsp, "序列化后为：", jsonMessage)
-					log.Println("返回的消息为：", messageRsp)
-					var messageBack = &MessageBack{
-						Message: jsonMessage,
-						Uuid:    message.Uuid,
-					}
-					k.mutex.Lock()
-					if receiveClient, ok := k.Clients[message.ReceiveId]; ok {
-						//messageBack.Message = jsonMessage
-						//messageBack.Uuid = message.Uuid
-						receiveClient.SendBack <- messageBack // 向client.Send发送
-					}
-					// 通话这不能回显，发回去的话就会出现两个start_call。
-					//sendClient := s.Clients[message.SendId]
-					//sendClient.SendBack <- messageBack
-					k.mutex.Unlock()
-				}
+				// 呼叫、接听、拒绝、挂断由通话状态机处理，通话记录在通话结束后落库
+				HandleAVMessage(&chatMessageReq)
 			}
 		}
 	}()
@@ -464,6 +443,11 @@ func (k *KafkaServer) Start() {
 				if err != nil {
 					zlog.Error(err.Error())
 				}
+				// 补发离线期间的通知
+				go sendOfflineNotifications(client)
+				if res := dao.GormDB.Model(&model.UserInfo{}).Where("uuid = ?", client.Uuid).Update("last_online_at", time.Now()); res.Error != nil {
+					zlog.Error(res.Error.Error())
+				}
 			}
 
 		case client := <-k.Logout:
@@ -472,6 +456,10 @@ func (k *KafkaServer) Start() {
 				delete(k.Clients, client.Uuid)
 				k.mutex.Unlock()
 				zlog.Info(fmt.Sprintf("用户%s退出登录\n", client.Uuid))
+				EndUserCall(client.Uuid)
+				if res := dao.GormDB.Model(&model.UserInfo{}).Where("uuid = ?", client.Uuid).Update("last_offline_at", time.Now()); res.Error != nil {
+					zlog.Error(res.Error.Error())
+				}
 				if err := client.Conn.WriteMessage(websocket.TextMessage, []byte("已退出登录")); err != nil {
 					zlog.Error(err.Error())
 				}
@@ -0,0 +1,186 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kama_chat_server/internal/config"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	myKafka "kama_chat_server/internal/service/kafka"
+	myredis "kama_chat_server/internal/service/redis"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/zlog"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/segmentio/kafka-go"
+)
+
+// notificationDao 补发通知和确认送达共用
+var notificationDao = dao.NewNotificationDAO(dao.GormDB)
+
+// SendMessageToUsers 向在线用户推送消息，不在线的用户直接跳过
+// 供service层在业务事件（如入群、退群）发生后主动推送使用
+// 持有server的锁期间只做非阻塞发送，接收方的发送队列已满时丢弃本次推送，不阻塞server的转发循环
+// 需要确认送达的消息和通知仍是未送达状态，用户重新上线后可以再拉取
+func SendMessageToUsers(uuids []string, messageBack *MessageBack) {
+	if messageMode == "channel" {
+		ChatServer.mutex.Lock()
+		defer ChatServer.mutex.Unlock()
+		for _, uuid := range uuids {
+			if client, ok := ChatServer.Clients[uuid]; ok {
+				client.trySend(messageBack)
+			}
+		}
+	} else {
+		KafkaChatServer.mutex.Lock()
+		defer KafkaChatServer.mutex.Unlock()
+		for _, uuid := range uuids {
+			if client, ok := KafkaChatServer.Clients[uuid]; ok {
+				client.trySend(messageBack)
+			}
+		}
+	}
+}
+
+// trySend 非阻塞地放入客户端的发送队列，队列已满时返回false
+func (c *Client) trySend(messageBack *MessageBack) bool {
+	select {
+	case c.SendBack <- messageBack:
+		return true
+	default:
+		zlog.Warn(fmt.Sprintf("用户%s的发送队列已满，丢弃推送", c.Uuid))
+		return false
+	}
+}
+
+// MutedCacheKey 对targetId的会话开启了免打扰的用户列表的缓存key，免打扰设置变化时删除
+func MutedCacheKey(targetId string) string {
+	return "session_muted_" + targetId
+}
+
+// mutedReceivers 对targetId的会话开启了免打扰的用户，优先从redis缓存读取，转发消息时不查数据库
+func mutedReceivers(targetId string) map[string]bool {
+	var mutedIds []string
+	cached, err := myredis.GetKeyNilIsErr(MutedCacheKey(targetId))
+	if err == nil && json.Unmarshal([]byte(cached), &mutedIds) == nil {
+		return toSet(mutedIds)
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		zlog.Error(err.Error())
+	}
+	if err := dao.GormDB.Model(&model.Session{}).Where("receive_id = ? AND is_muted = 1", targetId).
+		Pluck("send_id", &mutedIds).Error; err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	// 没有人免打扰时也缓存空列表
+	if mutedIds == nil {
+		mutedIds = []string{}
+	}
+	if data, err := json.Marshal(mutedIds); err == nil {
+		if err := myredis.SetKeyEx(MutedCacheKey(targetId), string(data), time.Minute*constants.REDIS_TIMEOUT); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	return toSet(mutedIds)
+}
+
+func toSet(ids []string) map[string]bool {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// receiverMessageBack 按接收者的会话免打扰设置准备推送的内容，返回的函数给出推送给某个接收者的消息
+// 免打扰的消息照常推送并计入未读，只在消息上加"muted": true，前端据此只更新角标，不弹出提醒
+// targetId为接收者会话的对象，单聊为发送者，群聊为群聊uuid
+func receiverMessageBack(messageBack *MessageBack, targetId string) func(receiveId string) *MessageBack {
+	muted := mutedReceivers(targetId)
+	if len(muted) == 0 {
+		return func(string) *MessageBack { return messageBack }
+	}
+	mutedBack := messageBack
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(messageBack.Message, &fields); err != nil {
+		zlog.Error(err.Error())
+	} else {
+		fields["muted"] = json.RawMessage("true")
+		if jsonMessage, err := json.Marshal(fields); err != nil {
+			zlog.Error(err.Error())
+		} else {
+			mutedBack = &MessageBack{Message: jsonMessage, Uuid: messageBack.Uuid}
+		}
+	}
+	return func(receiveId string) *MessageBack {
+		if muted[receiveId] {
+			return mutedBack
+		}
+		return messageBack
+	}
+}
+
+// SubmitChatMessage 由服务端代替用户发送一条聊天消息（如定时消息），与用户通过websocket发送的消息走同一条转发链路
+// jsonMessage为序列化后的request.ChatMessageRequest
+func SubmitChatMessage(jsonMessage []byte) error {
+	if messageMode == "channel" {
+		ChatServer.SendMessageToTransmit(jsonMessage)
+		return nil
+	}
+	return myKafka.KafkaService.ChatWriter.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.Itoa(config.GetConfig().KafkaConfig.Partition)),
+		Value: jsonMessage,
+	})
+}
+
+// NewNotificationMessageBack 将通知转换为推送给前端的消息
+func NewNotificationMessageBack(notification *model.Notification) (*MessageBack, error) {
+	notificationRsp := respond.NotificationRespond{
+		NotificationId: notification.Uuid,
+		Type:           message_type_enum.Notification,
+		NotifyType:     notification.Type,
+		FromId:         notification.FromId,
+		FromName:       notification.FromName,
+		FromAvatar:     notification.FromAvatar,
+		TargetId:       notification.TargetId,
+		TargetName:     notification.TargetName,
+		Content:        notification.Content,
+		CreatedAt:      notification.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	jsonMessage, err := json.Marshal(notificationRsp)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageBack{
+		Message: jsonMessage,
+		Uuid:    notification.Uuid,
+	}, nil
+}
+
+// sendOfflineNotifications 用户上线后补发离线期间未送达的通知
+// 由Write成功写入websocket后将通知置为已送达，补发期间连接断开时停止，剩余的通知下次上线再补发
+func sendOfflineNotifications(client *Client) {
+	notifications, err := notificationDao.GetUnsentNotifications(client.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	for _, notification := range notifications {
+		messageBack, err := NewNotificationMessageBack(notification)
+		if err != nil {
+			zlog.Error(err.Error())
+			continue
+		}
+		select {
+		case client.SendBack <- messageBack:
+		case <-client.done:
+			return
+		}
+	}
+}
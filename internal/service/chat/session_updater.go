@@ -0,0 +1,232 @@
+package chat
+
+import (
+	"fmt"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/model"
+	myredis "kama_chat_server/internal/service/redis"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/zlog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sessionActivity 一条已落库消息对会话的影响
+type sessionActivity struct {
+	SendId     string
+	ReceiveId  string
+	Preview    string
+	At         time.Time
+	ClearDraft bool // 是否为发送者自己编辑发出的消息，只有这类消息清除发送者的草稿
+}
+
+// conversationKey 同一个单聊（不区分方向）或同一个群聊的消息对应同一个key
+func (a *sessionActivity) conversationKey() string {
+	if a.ReceiveId[0] == 'G' || a.SendId > a.ReceiveId {
+		return a.ReceiveId + "_" + a.SendId
+	}
+	return a.SendId + "_" + a.ReceiveId
+}
+
+// sessionUpdater 根据落库的消息更新会话的最新消息和最近活跃时间
+// 消息先进入通道，攒批后每个会话只保留最新的一条，一个单聊或群聊只需要一条update，避免群聊扇出成N次同步写
+type sessionUpdater struct {
+	activities chan sessionActivity
+	mutex      sync.RWMutex // 保护stopped，关闭通道和向通道发送不能同时进行
+	stopped    bool
+	done       chan struct{} // Start写完剩余的更新退出后关闭
+}
+
+var SessionUpdater *sessionUpdater
+
+func init() {
+	if SessionUpdater == nil {
+		SessionUpdater = &sessionUpdater{
+			activities: make(chan sessionActivity, constants.CHANNEL_SIZE*10),
+			done:       make(chan struct{}),
+		}
+	}
+}
+
+// messagePreview 会话列表中展示的最新消息
+func messagePreview(message *model.Message) string {
+	switch message.Type {
+	case message_type_enum.Voice:
+		return "[语音]"
+	case message_type_enum.Image:
+		return "[图片]"
+	case message_type_enum.Location:
+		if location := MessageLocation(message); location != nil && location.Name != "" {
+			return fmt.Sprintf("[位置] %s", location.Name)
+		}
+		return "[位置]"
+	case message_type_enum.ContactCard:
+		if card := MessageContactCard(message); card != nil {
+			return fmt.Sprintf("[名片] %s", card.Name)
+		}
+		return "[名片]"
+	case message_type_enum.File:
+		return fmt.Sprintf("[文件] %s", message.FileName)
+	case message_type_enum.AudioOrVideo:
+		if message.Content != "" {
+			return fmt.Sprintf("[通话] %s", message.Content)
+		}
+		return "[通话]"
+	}
+	content := []rune(message.Content)
+	if len(content) > constants.SESSION_PREVIEW_LEN {
+		return string(content[:constants.SESSION_PREVIEW_LEN]) + "..."
+	}
+	return string(content)
+}
+
+// clearsDraft 发送者自己编辑发出的消息类型，系统消息、通话记录等由服务端生成的消息不清除草稿
+func clearsDraft(messageType int8) bool {
+	switch messageType {
+	case message_type_enum.Text, message_type_enum.File, message_type_enum.Image, message_type_enum.Voice,
+		message_type_enum.Location, message_type_enum.ContactCard:
+		return true
+	}
+	return false
+}
+
+// RecordSessionActivity 消息落库后调用，异步更新相关会话
+// 通道满时丢弃并记录日志，不阻塞消息转发，会话的最新消息会在该会话下一条消息时被修正
+// 服务关闭后落库的消息不再更新会话
+func RecordSessionActivity(message *model.Message) {
+	activity := sessionActivity{
+		SendId:     message.SendId,
+		ReceiveId:  message.ReceiveId,
+		Preview:    messagePreview(message),
+		At:         message.CreatedAt,
+		ClearDraft: clearsDraft(message.Type),
+	}
+	SessionUpdater.mutex.RLock()
+	defer SessionUpdater.mutex.RUnlock()
+	if SessionUpdater.stopped {
+		zlog.Warn("会话更新已停止，丢弃消息 " + message.Uuid + " 的会话更新")
+		return
+	}
+	select {
+	case SessionUpdater.activities <- activity:
+	default:
+		zlog.Warn("会话更新通道已满，丢弃消息 " + message.Uuid + " 的会话更新")
+	}
+}
+
+// Start 启动批量更新，由主进程用协程起
+func (u *sessionUpdater) Start() {
+	defer close(u.done)
+	ticker := time.NewTicker(time.Millisecond * constants.SESSION_FLUSH_INTERVAL)
+	defer ticker.Stop()
+	var batch []sessionActivity
+	for {
+		select {
+		case activity, ok := <-u.activities:
+			if !ok {
+				u.flush(batch)
+				return
+			}
+			batch = append(batch, activity)
+			if len(batch) >= constants.SESSION_FLUSH_BATCH {
+				u.flush(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				u.flush(batch)
+				batch = nil
+			}
+		}
+	}
+}
+
+// Close 停止接收新的更新并关闭通道，等待Start写入剩余的更新后返回
+func (u *sessionUpdater) Close() {
+	u.mutex.Lock()
+	if !u.stopped {
+		u.stopped = true
+		close(u.activities)
+	}
+	u.mutex.Unlock()
+	<-u.done
+}
+
+// flush 每个会话只更新最新的一条消息，清除发送者的草稿，并删除受影响用户的会话列表缓存
+// last_message_at的条件保证乱序到达的旧消息不会覆盖新消息
+func (u *sessionUpdater) flush(batch []sessionActivity) {
+	if len(batch) == 0 {
+		return
+	}
+	latest := make(map[string]sessionActivity)
+	// 发送者在该会话最近一次自己发消息的时间，用于清除草稿
+	sent := make(map[[2]string]time.Time)
+	for _, activity := range batch {
+		key := activity.conversationKey()
+		if old, ok := latest[key]; !ok || !activity.At.Before(old.At) {
+			latest[key] = activity
+		}
+		if !activity.ClearDraft {
+			continue
+		}
+		pair := [2]string{activity.SendId, activity.ReceiveId}
+		if activity.At.After(sent[pair]) {
+			sent[pair] = activity.At
+		}
+	}
+
+	var cacheKeys []string
+	err := dao.GormDB.Transaction(func(tx *gorm.DB) error {
+		for _, activity := range latest {
+			query := tx.Model(&model.Session{})
+			if activity.ReceiveId[0] == 'G' {
+				query = query.Where("receive_id = ?", activity.ReceiveId)
+			} else {
+				query = query.Where("(send_id = ? AND receive_id = ?) OR (send_id = ? AND receive_id = ?)",
+					activity.SendId, activity.ReceiveId, activity.ReceiveId, activity.SendId)
+			}
+			if err := query.Where("last_message_at IS NULL OR last_message_at <= ?", activity.At).
+				Updates(map[string]interface{}{
+					"last_message":    activity.Preview,
+					"last_message_at": activity.At,
+				}).Error; err != nil {
+				return err
+			}
+
+			if activity.ReceiveId[0] == 'G' {
+				var owners []string
+				if err := tx.Model(&model.Session{}).Where("receive_id = ?", activity.ReceiveId).
+					Pluck("send_id", &owners).Error; err != nil {
+					return err
+				}
+				for _, owner := range owners {
+					cacheKeys = append(cacheKeys, "group_session_list_"+owner)
+				}
+			} else {
+				cacheKeys = append(cacheKeys, "session_list_"+activity.SendId, "session_list_"+activity.ReceiveId)
+			}
+		}
+		// 发送消息后清除发送者在该会话的草稿，发送之后才保存的草稿保留
+		for pair, at := range sent {
+			if err := tx.Model(&model.Session{}).
+				Where("send_id = ? AND receive_id = ? AND draft_at <= ?", pair[0], pair[1], at).
+				Updates(map[string]interface{}{
+					"draft":    "",
+					"draft_at": nil,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if err := myredis.DelKeys(cacheKeys...); err != nil {
+		zlog.Error(err.Error())
+	}
+}
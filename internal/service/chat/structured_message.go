@@ -0,0 +1,165 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/group_info/group_status_enum"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/enum/user_info/user_status_enum"
+	"kama_chat_server/pkg/enum/user_info/visibility_enum"
+	"kama_chat_server/pkg/util/filename"
+	"kama_chat_server/pkg/zlog"
+	"strings"
+)
+
+// messageError 可以直接告诉发送者的消息检查错误，其他错误（如数据库、存储出错）只告诉发送者系统错误
+type messageError string
+
+func (e messageError) Error() string {
+	return string(e)
+}
+
+// prepareMessage 检查并补全语音、图片、文件、位置、名片消息，返回错误时丢弃该消息并通知发送者
+// 语音、图片和文件先上传再发送url，位置和名片的内容落在payload中
+func prepareMessage(message *model.Message, req *request.ChatMessageRequest) error {
+	var err error
+	if message.Type != message_type_enum.Voice {
+		message.Duration = 0
+	}
+	// 文件名会用于下载时的Content-Disposition，不能带路径
+	if message.FileName != "" {
+		message.FileName = filename.Sanitize(message.FileName)
+	}
+	if message.Type == message_type_enum.Voice || message.Type == message_type_enum.Image || message.Type == message_type_enum.File {
+		if err := checkMessageFile(message); err != nil {
+			return err
+		}
+	}
+	switch message.Type {
+	case message_type_enum.Voice:
+		if msg := CheckVoice(message.Url, message.Duration); msg != "" {
+			return messageError(msg)
+		}
+		return checkVoiceFile(message)
+	case message_type_enum.Image:
+		return fillImageMessage(message)
+	case message_type_enum.Location:
+		message.Url = ""
+		message.Payload, err = locationPayload(req.Location)
+	case message_type_enum.ContactCard:
+		message.Url = ""
+		message.Payload, err = contactCardPayload(req.CardId)
+	}
+	return err
+}
+
+// sendMessageFailed 消息未通过检查时通知发送者，不需要确认送达
+func sendMessageFailed(req *request.ChatMessageRequest, err error) {
+	reason := constants.SYSTEM_ERROR
+	var msgErr messageError
+	if errors.As(err, &msgErr) {
+		reason = msgErr.Error()
+	}
+	jsonMessage, err := json.Marshal(respond.MessageFailedRespond{
+		Type:        message_type_enum.SendFailed,
+		ReceiveId:   req.ReceiveId,
+		MessageType: req.Type,
+		Url:         req.Url,
+		Reason:      reason,
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	SendMessageToUsers([]string{req.SendId}, &MessageBack{Message: jsonMessage})
+}
+
+// locationPayload 检查位置消息，返回落库的内容
+func locationPayload(location *request.LocationPayload) (json.RawMessage, error) {
+	if location == nil {
+		return nil, messageError("位置消息缺少位置")
+	}
+	if location.Latitude < -90 || location.Latitude > 90 || location.Longitude < -180 || location.Longitude > 180 {
+		return nil, messageError("经纬度不合法")
+	}
+	name := strings.TrimSpace(location.Name)
+	address := strings.TrimSpace(location.Address)
+	if len([]rune(name)) > constants.LOCATION_NAME_MAX_LEN || len([]rune(address)) > constants.LOCATION_ADDRESS_MAX_LEN {
+		return nil, messageError("地点名称或地址过长")
+	}
+	return json.Marshal(respond.LocationRespond{
+		Latitude:  location.Latitude,
+		Longitude: location.Longitude,
+		Name:      name,
+		Address:   address,
+	})
+}
+
+// contactCardPayload 检查名片对应的用户或群聊，快照当前的名称和头像
+// 头像不是所有人可见的用户，名片中使用默认头像
+func contactCardPayload(cardId string) (json.RawMessage, error) {
+	card := respond.ContactCardRespond{CardId: cardId}
+	if cardId == "" {
+		return nil, messageError("名片消息缺少用户或群聊")
+	}
+	switch cardId[0] {
+	case 'U':
+		user, err := dao.NewUserDAO(dao.GormDB).GetUserByUUID(cardId)
+		if err != nil {
+			return nil, err
+		}
+		if user.Status == user_status_enum.DISABLE {
+			return nil, messageError(fmt.Sprintf("用户%s已被禁用", cardId))
+		}
+		card.Name = user.Nickname
+		card.Avatar = user.Avatar
+		if user.AvatarVisibility != visibility_enum.EVERYONE {
+			card.Avatar = constants.DEFAULT_AVATAR
+		}
+	case 'G':
+		group, err := dao.NewGroupDAO(dao.GormDB).GetGroupByUUID(cardId)
+		if err != nil {
+			return nil, err
+		}
+		if group.Status != group_status_enum.NORMAL {
+			return nil, messageError(fmt.Sprintf("群聊%s不可用", cardId))
+		}
+		card.Name = group.Name
+		card.Avatar = group.Avatar
+	default:
+		return nil, messageError(fmt.Sprintf("名片id不合法: %s", cardId))
+	}
+	return json.Marshal(card)
+}
+
+// MessageLocation 取出位置消息的内容，其他类型返回nil
+func MessageLocation(message *model.Message) *respond.LocationRespond {
+	if message.Type != message_type_enum.Location || len(message.Payload) == 0 {
+		return nil
+	}
+	var location respond.LocationRespond
+	if err := json.Unmarshal(message.Payload, &location); err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return &location
+}
+
+// MessageContactCard 取出名片消息的内容，其他类型返回nil
+func MessageContactCard(message *model.Message) *respond.ContactCardRespond {
+	if message.Type != message_type_enum.ContactCard || len(message.Payload) == 0 {
+		return nil
+	}
+	var card respond.ContactCardRespond
+	if err := json.Unmarshal(message.Payload, &card); err != nil {
+		zlog.Error(err.Error())
+		return nil
+	}
+	return &card
+}
@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/storage"
+	"kama_chat_server/pkg/constants"
+	"path/filepath"
+	"strings"
+)
+
+// voiceExts 允许的语音格式，覆盖浏览器录音（webm/ogg）和移动端录音（m4a/aac/amr）
+var voiceExts = map[string]bool{
+	".mp3":  true,
+	".wav":  true,
+	".m4a":  true,
+	".aac":  true,
+	".ogg":  true,
+	".webm": true,
+	".amr":  true,
+}
+
+// CheckVoice 检查语音的格式和时长，上传和发送时都会检查，返回空字符串表示通过
+// 时长由前端录音时给出，服务端不解析音频内容，另由CheckVoiceSize按文件大小检查时长是否可信
+func CheckVoice(fileName string, duration int) string {
+	if !voiceExts[strings.ToLower(filepath.Ext(fileName))] {
+		return "不支持的语音格式"
+	}
+	if duration < 1 || duration > constants.VOICE_MAX_DURATION {
+		return fmt.Sprintf("语音时长需要在1到%d秒之间", constants.VOICE_MAX_DURATION)
+	}
+	return ""
+}
+
+// CheckVoiceSize 按语音码率的合理范围检查时长与文件大小是否相符，返回空字符串表示通过
+// 时长按整秒取整，上下限各放宽1秒
+func CheckVoiceSize(size int64, duration int) string {
+	if int64(duration) > size/constants.VOICE_MIN_BYTE_RATE+1 || int64(duration) < size/constants.VOICE_MAX_BYTE_RATE-1 {
+		return "语音时长与文件大小不符"
+	}
+	return ""
+}
+
+// checkVoiceFile 发送时按服务端保存的语音文件大小检查时长
+func checkVoiceFile(message *model.Message) error {
+	key, ok := storage.KeyFromURL(message.Url)
+	if !ok {
+		return messageError(fmt.Sprintf("语音路径不合法: %s", message.Url))
+	}
+	info, err := storage.Storage.Stat(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return messageError("语音文件不存在")
+		}
+		return err
+	}
+	if msg := CheckVoiceSize(info.Size, message.Duration); msg != "" {
+		return messageError(msg)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package filecheck
+
+import (
+	"bytes"
+	"io"
+	"kama_chat_server/internal/config"
+	"kama_chat_server/pkg/util/filename"
+	"path"
+	"strings"
+	"time"
+)
+
+// 上传的种类，每种有各自的类型规则
+const (
+	KindAvatar = "avatar"
+	KindFile   = "file"
+	KindVoice  = "voice"
+	KindImage  = "image"
+)
+
+// RejectError 文件未通过检查，Reason可以直接返回给用户，Detail为只用于记录日志的细节（如病毒名）
+type RejectError struct {
+	Reason string
+	Detail string
+}
+
+func (e *RejectError) Error() string {
+	return e.Reason
+}
+
+// defaultRules 配置中某种上传的规则全部为空时使用
+var defaultRules = map[string]config.UploadRule{
+	KindAvatar: {AllowTypes: []string{"image/jpeg", "image/png", "image/gif"}},
+	KindFile: {
+		DenyTypes: []string{"application/x-msdownload", "application/x-executable", "application/x-mach-binary", "text/x-shellscript"},
+		DenyExts:  []string{".exe", ".bat", ".cmd", ".com", ".scr", ".pif", ".msi", ".vbs", ".ps1"},
+	},
+	KindVoice: {AllowTypes: []string{"audio/*", "application/ogg", "video/webm", "video/mp4"}},
+	KindImage: {AllowTypes: []string{"image/jpeg", "image/png", "image/gif"}},
+}
+
+var scanner Scanner = noopScanner{}
+
+var scanMaxSize int64
+
+// checkConfig 上传检查的规则，由Init传入，包内不读取全局配置
+var checkConfig config.UploadCheckConfig
+
+// Init 按配置初始化规则和扫描器，在主进程启动服务前调用
+func Init(conf config.UploadCheckConfig) {
+	checkConfig = conf
+	switch conf.ScannerType {
+	case "clamd":
+		timeout := time.Duration(conf.ScanTimeout) * time.Second
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		SetScanner(NewClamdScanner(conf.ClamdAddress, timeout))
+	}
+	scanMaxSize = conf.ScanMaxSize * 1024 * 1024
+}
+
+// SetScanner 替换扫描器，可以接入clamd以外的扫描服务
+func SetScanner(s Scanner) {
+	scanner = s
+}
+
+func rule(kind string) config.UploadRule {
+	var r config.UploadRule
+	switch kind {
+	case KindAvatar:
+		r = checkConfig.AvatarRule
+	case KindFile:
+		r = checkConfig.FileRule
+	case KindVoice:
+		r = checkConfig.VoiceRule
+	case KindImage:
+		r = checkConfig.ImageRule
+	}
+	if len(r.AllowTypes) == 0 && len(r.DenyTypes) == 0 && len(r.DenyExts) == 0 {
+		return defaultRules[kind]
+	}
+	return r
+}
+
+// matchType 类型是否命中规则，支持image/*形式的通配
+func matchType(patterns []string, contentType string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == contentType || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, pattern[:len(pattern)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckName 按扩展名检查，分片上传在创建任务时就可以拒绝
+// 按清理后的文件名取扩展名，与保存时一致，结尾的点和空格不能绕过检查
+func CheckName(kind, fileName string) error {
+	ext := strings.ToLower(path.Ext(filename.Sanitize(fileName)))
+	for _, deny := range rule(kind).DenyExts {
+		if strings.ToLower(strings.TrimSpace(deny)) == ext {
+			return &RejectError{Reason: "不允许上传该类型的文件"}
+		}
+	}
+	return nil
+}
+
+// CheckType 检查嗅探出的类型
+func CheckType(kind, contentType string) error {
+	r := rule(kind)
+	if matchType(r.DenyTypes, contentType) {
+		return &RejectError{Reason: "不允许上传该类型的文件"}
+	}
+	if len(r.AllowTypes) > 0 && !matchType(r.AllowTypes, contentType) {
+		return &RejectError{Reason: "不支持的文件格式"}
+	}
+	return nil
+}
+
+// Scan 交给扫描器检查，超过scanMaxSize的文件不扫描
+func Scan(reader io.Reader, size int64) error {
+	if scanMaxSize > 0 && size > scanMaxSize {
+		return nil
+	}
+	return scanner.Scan(reader)
+}
+
+// CheckStream 嗅探类型并扫描只能顺序读取的内容，返回嗅探出的类型，扫描器可能不会读完reader
+func CheckStream(kind string, reader io.Reader, size int64) (string, error) {
+	head := make([]byte, SniffLen)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	contentType := Sniff(head[:n])
+	if err := CheckType(kind, contentType); err != nil {
+		return "", err
+	}
+	if err := Scan(io.MultiReader(bytes.NewReader(head[:n]), reader), size); err != nil {
+		return "", err
+	}
+	return contentType, nil
+}
+
+// Check 检查文件名、嗅探类型并扫描，返回嗅探出的类型，检查后file回到开头
+func Check(kind, fileName string, file io.ReadSeeker, size int64) (string, error) {
+	if err := CheckName(kind, fileName); err != nil {
+		return "", err
+	}
+	contentType, err := CheckStream(kind, file, size)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return contentType, nil
+}
@@ -0,0 +1,107 @@
+package filecheck
+
+import (
+	"errors"
+	"kama_chat_server/internal/config"
+	"testing"
+)
+
+// withUploadRules 测试期间替换上传规则
+func withUploadRules(t *testing.T, conf config.UploadCheckConfig) {
+	t.Helper()
+	old := checkConfig
+	checkConfig = conf
+	t.Cleanup(func() { checkConfig = old })
+}
+
+func isReject(err error) bool {
+	var rejectErr *RejectError
+	return errors.As(err, &rejectErr)
+}
+
+func TestCheckTypeDefaultRules(t *testing.T) {
+	withUploadRules(t, config.UploadCheckConfig{})
+	tests := []struct {
+		kind        string
+		contentType string
+		reject      bool
+	}{
+		{KindAvatar, "image/png", false},
+		{KindAvatar, "image/jpeg", false},
+		{KindAvatar, "image/webp", true},
+		{KindAvatar, "text/html", true},
+		{KindImage, "image/gif", false},
+		{KindImage, "image/svg+xml", true},
+		{KindVoice, "audio/amr", false},
+		{KindVoice, "audio/mpeg", false},
+		{KindVoice, "application/ogg", false},
+		{KindVoice, "video/webm", false},
+		{KindVoice, "image/png", true},
+		{KindFile, "application/pdf", false},
+		{KindFile, "text/plain", false},
+		{KindFile, "application/x-msdownload", true},
+		{KindFile, "application/x-executable", true},
+		{KindFile, "application/x-mach-binary", true},
+		{KindFile, "text/x-shellscript", true},
+	}
+	for _, tt := range tests {
+		if got := isReject(CheckType(tt.kind, tt.contentType)); got != tt.reject {
+			t.Errorf("CheckType(%s, %s) reject = %v, want %v", tt.kind, tt.contentType, got, tt.reject)
+		}
+	}
+}
+
+func TestCheckTypeConfiguredRules(t *testing.T) {
+	withUploadRules(t, config.UploadCheckConfig{
+		FileRule: config.UploadRule{
+			AllowTypes: []string{" Image/* ", "application/pdf"},
+			DenyTypes:  []string{"image/gif"},
+		},
+	})
+	tests := []struct {
+		kind        string
+		contentType string
+		reject      bool
+	}{
+		{KindFile, "image/png", false},
+		{KindFile, "application/pdf", false},
+		{KindFile, "image/gif", true}, // DenyTypes优先于AllowTypes
+		{KindFile, "text/plain", true},
+		{KindFile, "imagex/png", true}, // 通配只匹配完整的主类型
+		// 其他种类没有配置，仍使用默认规则
+		{KindAvatar, "image/webp", true},
+		{KindVoice, "audio/aac", false},
+	}
+	for _, tt := range tests {
+		if got := isReject(CheckType(tt.kind, tt.contentType)); got != tt.reject {
+			t.Errorf("CheckType(%s, %s) reject = %v, want %v", tt.kind, tt.contentType, got, tt.reject)
+		}
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	withUploadRules(t, config.UploadCheckConfig{})
+	tests := []struct {
+		kind     string
+		fileName string
+		reject   bool
+	}{
+		{KindFile, "report.pdf", false},
+		{KindFile, "setup.exe", true},
+		{KindFile, "SETUP.EXE", true},
+		{KindFile, "run.ps1", true},
+		// 保存时会去掉结尾的点和空格，检查时也要按清理后的扩展名
+		{KindFile, "evil.bat.", true},
+		{KindFile, "evil.ps1 ", true},
+		{KindFile, "evil.cmd . .", true},
+		{KindFile, `C:	mp\evil.exe`, true},
+		{KindFile, "archive.exe.zip", false},
+		{KindFile, "noext", false},
+		{KindImage, "a.exe", false}, // 图片没有扩展名限制，按内容检查
+	}
+	for _, tt := range tests {
+		if got := isReject(CheckName(tt.kind, tt.fileName)); got != tt.reject {
+			t.Errorf("CheckName(%s, %s) reject = %v, want %v", tt.kind, tt.fileName, got, tt.reject)
+		}
+	}
+}
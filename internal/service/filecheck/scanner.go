@@ -0,0 +1,117 @@
+package filecheck
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Scanner 在上传的文件对其他用户可见之前扫描，发现问题时返回*RejectError，扫描器本身出错时返回其他错误
+type Scanner interface {
+	Scan(reader io.Reader) error
+}
+
+// noopScanner 未配置扫描器时使用，所有文件都通过
+type noopScanner struct{}
+
+func (noopScanner) Scan(io.Reader) error {
+	return nil
+}
+
+// clamdChunkSize INSTREAM每次发送的数据块大小
+const clamdChunkSize = 32 * 1024
+
+// ClamdScanner 通过clamd的INSTREAM命令扫描，文件内容按块发给clamd，不需要与clamd共享文件系统
+type ClamdScanner struct {
+	Network string // tcp或unix
+	Address string
+	Timeout time.Duration // 连接以及每次读写的超时
+}
+
+// NewClamdScanner address为host:port，或unix:加socket路径
+func NewClamdScanner(address string, timeout time.Duration) *ClamdScanner {
+	if strings.HasPrefix(address, "unix:") {
+		return &ClamdScanner{Network: "unix", Address: strings.TrimPrefix(address, "unix:"), Timeout: timeout}
+	}
+	return &ClamdScanner{Network: "tcp", Address: address, Timeout: timeout}
+}
+
+func (s *ClamdScanner) Scan(reader io.Reader) error {
+	conn, err := net.DialTimeout(s.Network, s.Address, s.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := s.write(conn, []byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+	// 每块数据前是4字节大端长度，长度为0的块表示结束
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := io.ReadFull(reader, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if err := s.write(conn, buf[:4+n]); err != nil {
+				// 超过StreamMaxLength时clamd会先回复错误再断开，优先返回clamd的回复
+				if reply, replyErr := s.readReply(conn); replyErr == nil {
+					return parseClamdReply(reply)
+				}
+				return err
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	if err := s.write(conn, []byte{0, 0, 0, 0}); err != nil {
+		return err
+	}
+	reply, err := s.readReply(conn)
+	if err != nil {
+		return err
+	}
+	return parseClamdReply(reply)
+}
+
+func (s *ClamdScanner) write(conn net.Conn, data []byte) error {
+	if s.Timeout > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(s.Timeout)); err != nil {
+			return err
+		}
+	}
+	_, err := conn.Write(data)
+	return err
+}
+
+// readReply 读取以\0结尾的回复
+func (s *ClamdScanner) readReply(conn net.Conn) (string, error) {
+	if s.Timeout > 0 {
+		if err := conn.SetReadDeadline(time.Now().Add(s.Timeout)); err != nil {
+			return "", err
+		}
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(err == io.EOF && reply != "") {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+// parseClamdReply 解析回复：stream: OK、stream: <病毒名> FOUND、<原因> ERROR
+func parseClamdReply(reply string) error {
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return &RejectError{Reason: "文件未通过安全检查", Detail: signature}
+	}
+	return fmt.Errorf("clamd扫描失败: %s", reply)
+}
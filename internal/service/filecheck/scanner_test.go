@@ -0,0 +1,200 @@
+package filecheck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClamd 按zINSTREAM协议接收文件内容的clamd，内容包含EICAR时回复FOUND，包含BROKEN时回复ERROR
+// limit大于0时模拟StreamMaxLength：收到的内容超过limit后回复错误并断开连接
+type fakeClamd struct {
+	listener net.Listener
+	limit    int
+	received chan []byte
+}
+
+func startFakeClamd(t *testing.T, network, address string, limit int) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	clamd := &fakeClamd{listener: listener, limit: limit, received: make(chan []byte, 10)}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go clamd.handle(conn)
+		}
+	}()
+	return clamd
+}
+
+// address 客户端使用的地址，unix socket加上unix:前缀
+func (f *fakeClamd) address() string {
+	if f.listener.Addr().Network() == "unix" {
+		return "unix:" + f.listener.Addr().String()
+	}
+	return f.listener.Addr().String()
+}
+
+func (f *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	command := make([]byte, len("zINSTREAM\x00"))
+	if _, err := io.ReadFull(conn, command); err != nil {
+		return
+	}
+	if string(command) != "zINSTREAM\x00" {
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+	var content []byte
+	length := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint32(length)
+		if n == 0 {
+			break
+		}
+		chunk := make([]byte, n)
+		if _, err := io.ReadFull(conn, chunk); err != nil {
+			return
+		}
+		content = append(content, chunk...)
+		if f.limit > 0 && len(content) > f.limit {
+			// 与clamd一致，先回复再断开，不再读取剩余的数据
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			return
+		}
+	}
+	f.received <- content
+	switch {
+	case bytes.Contains(content, []byte("EICAR")):
+		conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+	case bytes.Contains(content, []byte("BROKEN")):
+		conn.Write([]byte("Can't allocate memory ERROR\x00"))
+	default:
+		conn.Write([]byte("stream: OK\x00"))
+	}
+}
+
+func TestClamdScanner(t *testing.T) {
+	clamd := startFakeClamd(t, "tcp", "127.0.0.1:0", 0)
+	scanner := NewClamdScanner(clamd.address(), 5*time.Second)
+	if scanner.Network != "tcp" {
+		t.Fatalf("Network = %q, want tcp", scanner.Network)
+	}
+
+	// 跨多个数据块的内容需要原样送达
+	large := bytes.Repeat([]byte("0123456789abcdef"), clamdChunkSize/16*3+7)
+	tests := []struct {
+		name    string
+		content []byte
+		reject  bool
+		failed  bool
+	}{
+		{"空文件", nil, false, false},
+		{"正常", []byte("hello"), false, false},
+		{"多个数据块", large, false, false},
+		{"发现病毒", []byte("X5O!P%@AP EICAR-STANDARD-ANTIVIRUS-TEST-FILE"), true, false},
+		{"clamd出错", []byte("BROKEN"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := scanner.Scan(bytes.NewReader(tt.content))
+			var rejectErr *RejectError
+			switch {
+			case tt.reject:
+				if !errors.As(err, &rejectErr) {
+					t.Fatalf("Scan() = %v, want *RejectError", err)
+				}
+			case tt.failed:
+				if err == nil || errors.As(err, &rejectErr) {
+					t.Fatalf("Scan() = %v, want a scanner error", err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("Scan() = %v, want nil", err)
+				}
+			}
+			select {
+			case received := <-clamd.received:
+				if !bytes.Equal(received, tt.content) {
+					t.Errorf("clamd received %d bytes, want %d", len(received), len(tt.content))
+				}
+			case <-time.After(time.Second):
+				t.Error("clamd did not receive the stream")
+			}
+		})
+	}
+}
+
+func TestClamdScannerSizeLimit(t *testing.T) {
+	// unix socket在对端断开后仍可以读到断开前的回复，与clamd的行为一致
+	clamd := startFakeClamd(t, "unix", filepath.Join(t.TempDir(), "clamd.sock"), 64*1024)
+	scanner := NewClamdScanner(clamd.address(), 5*time.Second)
+	if scanner.Network != "unix" {
+		t.Fatalf("Network = %q, want unix", scanner.Network)
+	}
+	err := scanner.Scan(bytes.NewReader(make([]byte, 8*1024*1024)))
+	if err == nil {
+		t.Fatal("Scan() = nil, want size limit error")
+	}
+	var rejectErr *RejectError
+	if errors.As(err, &rejectErr) {
+		t.Fatalf("Scan() = %v, size limit is a scanner error, not a rejection", err)
+	}
+	if !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Errorf("Scan() = %v, want clamd's reply", err)
+	}
+}
+
+func TestClamdScannerUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	err = NewClamdScanner(address, time.Second).Scan(strings.NewReader("hello"))
+	var rejectErr *RejectError
+	if err == nil || errors.As(err, &rejectErr) {
+		t.Fatalf("Scan() = %v, want a connection error", err)
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	tests := []struct {
+		reply  string
+		reject bool
+		failed bool
+	}{
+		{"stream: OK", false, false},
+		{"stream: Win.Test.EICAR_HDB-1 FOUND", true, false},
+		{"INSTREAM size limit exceeded. ERROR", false, true},
+		{"", false, true},
+		{"UNKNOWN COMMAND", false, true},
+	}
+	for _, tt := range tests {
+		err := parseClamdReply(tt.reply)
+		var rejectErr *RejectError
+		if got := errors.As(err, &rejectErr); got != tt.reject {
+			t.Errorf("parseClamdReply(%q) = %v, reject %v, want %v", tt.reply, err, got, tt.reject)
+		}
+		if got := err != nil && !tt.reject; got != tt.failed {
+			t.Errorf("parseClamdReply(%q) = %v, want failed %v", tt.reply, err, tt.failed)
+		}
+	}
+}
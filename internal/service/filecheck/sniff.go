@@ -0,0 +1,38 @@
+package filecheck
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// SniffLen 嗅探类型需要读取的字节数
+const SniffLen = 512
+
+// Sniff 根据文件开头的内容判断MIME类型，不参考文件名和客户端给出的Content-Type
+// 先识别http.DetectContentType不认识的可执行文件和音频格式，其余交给标准库
+func Sniff(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("MZ")):
+		return "application/x-msdownload"
+	case bytes.HasPrefix(head, []byte("\x7fELF")):
+		return "application/x-executable"
+	case bytes.HasPrefix(head, []byte{0xFE, 0xED, 0xFA, 0xCE}), bytes.HasPrefix(head, []byte{0xFE, 0xED, 0xFA, 0xCF}),
+		bytes.HasPrefix(head, []byte{0xCE, 0xFA, 0xED, 0xFE}), bytes.HasPrefix(head, []byte{0xCF, 0xFA, 0xED, 0xFE}):
+		return "application/x-mach-binary"
+	case bytes.HasPrefix(head, []byte("#!AMR")):
+		return "audio/amr"
+	case bytes.HasPrefix(head, []byte("#!")):
+		return "text/x-shellscript"
+	case len(head) >= 12 && string(head[4:8]) == "ftyp" && (string(head[8:11]) == "M4A" || string(head[8:11]) == "M4B"):
+		return "audio/mp4"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xF6 == 0xF0:
+		// ADTS封装的aac
+		return "audio/aac"
+	}
+	contentType := http.DetectContentType(head)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return contentType
+}
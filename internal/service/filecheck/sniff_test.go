@@ -0,0 +1,41 @@
+package filecheck
+
+import "testing"
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"空内容", nil, "text/plain"},
+		{"文本", []byte("hello, 世界"), "text/plain"},
+		{"Windows可执行文件", []byte("MZ\x90\x00\x03\x00"), "application/x-msdownload"},
+		{"ELF", []byte("\x7fELF\x02\x01\x01"), "application/x-executable"},
+		{"Mach-O 32位", []byte{0xFE, 0xED, 0xFA, 0xCE, 0, 0}, "application/x-mach-binary"},
+		{"Mach-O 64位", []byte{0xFE, 0xED, 0xFA, 0xCF, 0, 0}, "application/x-mach-binary"},
+		{"Mach-O 小端32位", []byte{0xCE, 0xFA, 0xED, 0xFE, 0, 0}, "application/x-mach-binary"},
+		{"Mach-O 小端64位", []byte{0xCF, 0xFA, 0xED, 0xFE, 0, 0}, "application/x-mach-binary"},
+		{"AMR不是脚本", []byte("#!AMR\n\x3c"), "audio/amr"},
+		{"shell脚本", []byte("#!/bin/sh\nrm -rf /\n"), "text/x-shellscript"},
+		{"m4a", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00"), "audio/mp4"},
+		{"m4b", []byte("\x00\x00\x00\x20ftypM4B \x00\x00\x00\x00"), "audio/mp4"},
+		{"ADTS aac", []byte{0xFF, 0xF1, 0x50, 0x80}, "audio/aac"},
+		{"jpeg不是aac", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00}, "image/jpeg"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), "image/png"},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), "image/gif"},
+		{"pdf", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"ogg", []byte("OggS\x00\x02\x00\x00"), "application/ogg"},
+		{"webm", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81}, "video/webm"},
+		{"mp3", []byte("ID3\x03\x00\x00\x00\x00\x00\x00"), "audio/mpeg"},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wave"},
+		{"html不带charset", []byte("<html><body>hi</body></html>"), "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sniff(tt.head); got != tt.want {
+				t.Errorf("Sniff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
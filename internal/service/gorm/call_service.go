@@ -0,0 +1,148 @@
+package gorm
+
+import (
+	"errors"
+	"kama_chat_server/internal/config"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/chat"
+	myredis "kama_chat_server/internal/service/redis"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/user_info/user_status_enum"
+	"kama_chat_server/pkg/util/turn"
+	"kama_chat_server/pkg/zlog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type callService struct {
+	callRecordDao dao.CallRecordDAO
+	userDao       dao.UserDAO
+}
+
+var CallService *callService
+
+func InitCallService(callRecordDao dao.CallRecordDAO, userDao dao.UserDAO) {
+	CallService = &callService{
+		callRecordDao: callRecordDao,
+		userDao:       userDao,
+	}
+}
+
+// GetCallHistory 分页获取通话记录，按呼叫时间倒序，可以只看与某个联系人之间的通话
+func (c *callService) GetCallHistory(req request.GetCallHistoryRequest) (string, *respond.CallHistoryRespond, int) {
+	page := req.Page
+	if page < 1 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = constants.CALL_HISTORY_PAGE_SIZE
+	}
+	if pageSize > constants.CALL_HISTORY_MAX_PAGE_SIZE {
+		pageSize = constants.CALL_HISTORY_MAX_PAGE_SIZE
+	}
+	records, total, err := c.callRecordDao.GetCallHistory(req.OwnerId, req.ContactId, (page-1)*pageSize, pageSize)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rsp := &respond.CallHistoryRespond{
+		Total: total,
+		Calls: []respond.CallRecordRespond{},
+	}
+	peers := make(map[string]*model.UserInfo)
+	for _, record := range records {
+		callRsp := respond.CallRecordRespond{
+			CallId:    record.Uuid,
+			PeerId:    record.CallerId,
+			Outgoing:  record.CallerId == req.OwnerId,
+			Status:    record.Status,
+			Duration:  record.Duration,
+			CreatedAt: record.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if callRsp.Outgoing {
+			callRsp.PeerId = record.CalleeId
+		}
+		if record.AcceptedAt.Valid {
+			callRsp.AcceptedAt = record.AcceptedAt.Time.Format("2006-01-02 15:04:05")
+		}
+		if record.EndedAt.Valid {
+			callRsp.EndedAt = record.EndedAt.Time.Format("2006-01-02 15:04:05")
+		}
+		peer, ok := peers[callRsp.PeerId]
+		if !ok {
+			peer, err = c.userDao.GetUserByUUID(callRsp.PeerId)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				zlog.Error(err.Error())
+				return constants.SYSTEM_ERROR, nil, -1
+			}
+			peers[callRsp.PeerId] = peer
+		}
+		if peer != nil {
+			callRsp.PeerName = peer.Nickname
+			callRsp.PeerAvatar = peer.Avatar
+		}
+		rsp.Calls = append(rsp.Calls, callRsp)
+	}
+	return "获取通话记录成功", rsp, 0
+}
+
+// GetIceServers 返回配置的STUN/TURN服务器，TURN附带该用户的临时凭证
+// 接口没有登录态，IsOnline只说明本实例上有该uuid的websocket连接，不是身份认证，知道别人uuid的人同样能领取凭证，
+// kafka模式下连接在其他实例的用户会被当作不在线。凭证能否被滥用只靠较短的有效期和按ip限流来限制
+func (c *callService) GetIceServers(req request.GetIceServersRequest, clientIp string) (string, *respond.IceServersRespond, int) {
+	count, err := myredis.IncrKeyEx(myredis.PersistentKeyPrefix+"ice_servers_limit_ip_"+clientIp, time.Minute)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if count > constants.ICE_SERVERS_LIMIT_PER_MINUTE {
+		return "获取过于频繁，请稍后再试", nil, -2
+	}
+	if !chat.IsOnline(req.OwnerId) {
+		return "用户不在线", nil, -2
+	}
+	user, err := c.userDao.GetUserByUUID(req.OwnerId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "用户不存在", nil, -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if user.Status == user_status_enum.DISABLE {
+		return "用户已被禁用", nil, -2
+	}
+
+	iceConfig := config.GetConfig().IceConfig
+	ttl := iceConfig.CredentialTtl
+	if ttl <= 0 {
+		ttl = constants.ICE_CREDENTIAL_TTL
+	}
+	expireAt := time.Now().Add(time.Duration(ttl) * time.Second)
+	rsp := &respond.IceServersRespond{
+		IceServers: []respond.IceServerRespond{},
+		Ttl:        ttl,
+		ExpireAt:   expireAt.Unix(),
+	}
+	if len(iceConfig.StunUrls) > 0 {
+		rsp.IceServers = append(rsp.IceServers, respond.IceServerRespond{Urls: iceConfig.StunUrls})
+	}
+	if len(iceConfig.TurnUrls) > 0 {
+		if iceConfig.TurnSecret == "" {
+			zlog.Warn("未配置turnSecret，不返回TURN服务器")
+		} else {
+			username, credential := turn.Credential(iceConfig.TurnSecret, user.Uuid, expireAt)
+			rsp.IceServers = append(rsp.IceServers, respond.IceServerRespond{
+				Urls:       iceConfig.TurnUrls,
+				Username:   username,
+				Credential: credential,
+			})
+		}
+	}
+	return "获取ICE服务器成功", rsp, 0
+}
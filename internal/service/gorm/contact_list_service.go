@@ -0,0 +1,184 @@
+package gorm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/contact/contact_status_enum"
+	"kama_chat_server/pkg/util/random"
+	"kama_chat_server/pkg/zlog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type contactListService struct {
+	contactListDao dao.ContactListDAO
+	userContactDao dao.UserContactDAO
+}
+
+var ContactListService *contactListService
+
+func InitContactListService(contactListDao dao.ContactListDAO, userContactDao dao.UserContactDAO) {
+	ContactListService = &contactListService{
+		contactListDao: contactListDao,
+		userContactDao: userContactDao,
+	}
+}
+
+// checkMembers 检查分组成员是否都是自己的联系人（用户或已加入的群聊）
+func (c *contactListService) checkMembers(ownerId string, members []string) (string, int) {
+	for _, member := range members {
+		contact, err := c.userContactDao.GetUserContact(ownerId, member)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "分组中存在非联系人", -2
+			}
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		if contact.Status == contact_status_enum.DELETE || contact.Status == contact_status_enum.QUIT_GROUP {
+			return "分组中存在非联系人", -2
+		}
+	}
+	return "", 0
+}
+
+// getOwnContactList 获取属于ownerId的分组
+func (c *contactListService) getOwnContactList(ownerId, listId string) (*model.ContactList, string, int) {
+	contactList, err := c.contactListDao.GetContactListByUUID(listId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "分组不存在", -2
+		}
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if contactList.OwnerId != ownerId {
+		return nil, "分组不存在", -2
+	}
+	return contactList, "", 0
+}
+
+// CreateContactList 创建联系人分组
+func (c *contactListService) CreateContactList(req request.CreateContactListRequest) (string, string, int) {
+	if req.Name == "" {
+		return "分组名称不能为空", "", -2
+	}
+	if message, ret := c.checkMembers(req.OwnerId, req.Members); ret != 0 {
+		return message, "", ret
+	}
+	members := req.Members
+	if members == nil {
+		members = []string{}
+	}
+	contactList := model.ContactList{
+		Uuid:      fmt.Sprintf("L%s", random.GetNowAndLenRandomString(11)),
+		OwnerId:   req.OwnerId,
+		Name:      req.Name,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	var err error
+	contactList.Members, err = json.Marshal(members)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	if err := c.contactListDao.CreateContactList(&contactList); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	return "创建分组成功", contactList.Uuid, 0
+}
+
+// GetContactLists 获取我的联系人分组
+func (c *contactListService) GetContactLists(ownerId string) (string, []respond.ContactListRespond, int) {
+	contactLists, err := c.contactListDao.GetContactListsByOwner(ownerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	var rsp []respond.ContactListRespond
+	for _, contactList := range contactLists {
+		var members []string
+		if err := json.Unmarshal(contactList.Members, &members); err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		rsp = append(rsp, respond.ContactListRespond{
+			ListId:    contactList.Uuid,
+			Name:      contactList.Name,
+			Members:   members,
+			MemberCnt: len(members),
+		})
+	}
+	return "获取分组成功", rsp, 0
+}
+
+// UpdateContactList 修改分组名称或成员
+func (c *contactListService) UpdateContactList(req request.UpdateContactListRequest) (string, int) {
+	contactList, message, ret := c.getOwnContactList(req.OwnerId, req.ListId)
+	if ret != 0 {
+		return message, ret
+	}
+	if req.Name != "" {
+		contactList.Name = req.Name
+	}
+	if req.Members != nil {
+		if message, ret := c.checkMembers(req.OwnerId, req.Members); ret != 0 {
+			return message, ret
+		}
+		data, err := json.Marshal(req.Members)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		contactList.Members = data
+	}
+	contactList.UpdatedAt = time.Now()
+	if err := c.contactListDao.SaveContactList(contactList); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	return "修改分组成功", 0
+}
+
+// DeleteContactList 删除分组，分组中的联系人不受影响
+func (c *contactListService) DeleteContactList(ownerId, listId string) (string, int) {
+	if _, message, ret := c.getOwnContactList(ownerId, listId); ret != 0 {
+		return message, ret
+	}
+	if err := c.contactListDao.DeleteContactList(listId); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	return "删除分组成功", 0
+}
+
+// GetMemberSet 获取分组成员集合，供联系人列表、会话列表按分组过滤
+// listId为空时返回nil，表示不过滤
+func (c *contactListService) GetMemberSet(ownerId, listId string) (map[string]bool, string, int) {
+	if listId == "" {
+		return nil, "", 0
+	}
+	contactList, message, ret := c.getOwnContactList(ownerId, listId)
+	if ret != 0 {
+		return nil, message, ret
+	}
+	var members []string
+	if err := json.Unmarshal(contactList.Members, &members); err != nil {
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	memberSet := make(map[string]bool, len(members))
+	for _, member := range members {
+		memberSet[member] = true
+	}
+	return memberSet, "", 0
+}
@@ -0,0 +1,147 @@
+package gorm
+
+import (
+	"errors"
+	"io"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/service/chat"
+	"kama_chat_server/internal/service/storage"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/zlog"
+	"net/url"
+	"path"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type downloadService struct {
+	messageDao     dao.MessageDAO
+	favoriteDao    dao.FavoriteDAO
+	userContactDao dao.UserContactDAO
+}
+
+var DownloadService *downloadService
+
+func InitDownloadService(messageDao dao.MessageDAO, favoriteDao dao.FavoriteDAO, userContactDao dao.UserContactDAO) {
+	DownloadService = &downloadService{
+		messageDao:     messageDao,
+		favoriteDao:    favoriteDao,
+		userContactDao: userContactDao,
+	}
+}
+
+// downloadTarget 需要签发下载地址的文件
+type downloadTarget struct {
+	messageType  int8
+	url          string
+	thumbnailUrl string
+	fileName     string
+}
+
+// getMessageTarget 会话参与者才能下载消息中的文件
+func (d *downloadService) getMessageTarget(ownerId, messageId string) (*downloadTarget, string, int) {
+	message, err := d.messageDao.GetMessageByUUID(messageId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "消息不存在", -2
+		}
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if message, ret := checkMessageAccess(d.userContactDao, ownerId, message); ret != 0 {
+		return nil, message, ret
+	}
+	return &downloadTarget{
+		messageType:  message.Type,
+		url:          message.Url,
+		thumbnailUrl: message.ThumbnailUrl,
+		fileName:     message.FileName,
+	}, "", 0
+}
+
+// getFavoriteTarget 收藏只有本人可以下载，原消息过期后仍然可以下载
+func (d *downloadService) getFavoriteTarget(ownerId, favoriteId string) (*downloadTarget, string, int) {
+	favorite, err := d.favoriteDao.GetFavoriteByUUID(favoriteId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "收藏不存在", -2
+		}
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if favorite.OwnerId != ownerId {
+		return nil, "收藏不存在", -2
+	}
+	target := &downloadTarget{
+		messageType: favorite.MessageType,
+		url:         favorite.Url,
+		fileName:    favorite.FileName,
+	}
+	// 收藏不单独保存缩略图，按原图推出缩略图地址
+	if key, ok := storage.KeyFromURL(favorite.Url); ok && favorite.MessageType == message_type_enum.Image {
+		target.thumbnailUrl = storage.Storage.URL(storage.FileKey(chat.ImageThumbnailName(path.Base(key))))
+	}
+	return target, "", 0
+}
+
+// GetDownloadUrl 校验权限后签发有时效的下载地址
+func (d *downloadService) GetDownloadUrl(req request.GetDownloadUrlRequest) (string, *respond.DownloadUrlRespond, int) {
+	var target *downloadTarget
+	var message string
+	var ret int
+	switch {
+	case req.MessageId != "":
+		target, message, ret = d.getMessageTarget(req.OwnerId, req.MessageId)
+	case req.FavoriteId != "":
+		target, message, ret = d.getFavoriteTarget(req.OwnerId, req.FavoriteId)
+	default:
+		return "请指定消息或收藏", nil, -2
+	}
+	if ret != 0 {
+		return message, nil, ret
+	}
+	key, ok := storage.KeyFromURL(target.url)
+	if !ok || storage.IsAvatarKey(key) {
+		return "该消息没有文件", nil, -2
+	}
+
+	// 图片和语音在页面中直接展示，其他文件作为附件下载
+	inline := target.messageType == message_type_enum.Image || target.messageType == message_type_enum.Voice
+	fileName := target.fileName
+	if fileName == "" {
+		fileName = path.Base(key)
+	}
+	expireAt := time.Now().Add(time.Second * constants.DOWNLOAD_URL_EXPIRE)
+	rsp := &respond.DownloadUrlRespond{
+		Url:      storage.SignDownload(key, fileName, inline, expireAt),
+		ExpireAt: expireAt.Format("2006-01-02 15:04:05"),
+	}
+	if thumbnailKey, ok := storage.KeyFromURL(target.thumbnailUrl); ok && !storage.IsAvatarKey(thumbnailKey) {
+		rsp.ThumbnailUrl = storage.SignDownload(thumbnailKey, path.Base(thumbnailKey), true, expireAt)
+	}
+	return "获取下载地址成功", rsp, 0
+}
+
+// OpenDownload 校验签名地址并打开文件，签名无效、过期或文件不存在都返回-2
+func (d *downloadService) OpenDownload(key string, query url.Values) (io.ReadSeekCloser, *storage.ObjectInfo, string, int) {
+	key, ok := storage.CleanKey(key)
+	if !ok || storage.IsAvatarKey(key) {
+		return nil, nil, "文件不存在", -2
+	}
+	if !storage.VerifyDownload(key, query) {
+		return nil, nil, "下载地址无效或已过期", -2
+	}
+	file, info, err := storage.Storage.Open(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil, nil, "文件不存在", -2
+		}
+		zlog.Error(err.Error())
+		return nil, nil, constants.SYSTEM_ERROR, -1
+	}
+	return file, info, "", 0
+}
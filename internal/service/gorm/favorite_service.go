@@ -0,0 +1,313 @@
+package gorm
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/chat"
+	"kama_chat_server/internal/service/storage"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/contact/contact_status_enum"
+	"kama_chat_server/pkg/enum/favorite/favorite_type_enum"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/util/random"
+	"kama_chat_server/pkg/zlog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type favoriteService struct {
+	favoriteDao    dao.FavoriteDAO
+	messageDao     dao.MessageDAO
+	userContactDao dao.UserContactDAO
+}
+
+var FavoriteService *favoriteService
+
+func InitFavoriteService(favoriteDao dao.FavoriteDAO, messageDao dao.MessageDAO, userContactDao dao.UserContactDAO) {
+	FavoriteService = &favoriteService{
+		favoriteDao:    favoriteDao,
+		messageDao:     messageDao,
+		userContactDao: userContactDao,
+	}
+}
+
+// normalizeTags 去除首尾空格、空标签和重复标签，并检查数量和长度
+func normalizeTags(tags []string) ([]string, string, int) {
+	normalized := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > constants.FAVORITE_TAG_MAX_LEN {
+			return nil, fmt.Sprintf("标签不能超过%d个字符", constants.FAVORITE_TAG_MAX_LEN), -2
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > constants.FAVORITE_MAX_TAGS {
+		return nil, fmt.Sprintf("标签不能超过%d个", constants.FAVORITE_MAX_TAGS), -2
+	}
+	return normalized, "", 0
+}
+
+// checkMessageAccess 检查用户是否能看到该消息：单聊的发送方或接收方，群聊的当前成员
+func checkMessageAccess(userContactDao dao.UserContactDAO, ownerId string, message *model.Message) (string, int) {
+	if message.ReceiveId[0] == 'U' {
+		if message.SendId == ownerId || message.ReceiveId == ownerId {
+			return "", 0
+		}
+		return "消息不存在", -2
+	}
+	contact, err := userContactDao.GetUserContact(ownerId, message.ReceiveId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "消息不存在", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if contact.Status != contact_status_enum.NORMAL {
+		return "消息不存在", -2
+	}
+	return "", 0
+}
+
+// acquireMessageFile 收藏引用消息中的文件，与原消息共用同一个文件
+// 按内容存储的文件增加引用计数；去重之前上传的旧文件不会被ReleaseFile删除，只检查文件仍然存在
+func acquireMessageFile(url string) (string, error) {
+	if chat.AcquireFile(url) {
+		return url, nil
+	}
+	key, ok := storage.KeyFromURL(url)
+	if !ok {
+		return "", fmt.Errorf("文件路径不合法: %s", url)
+	}
+	if _, err := storage.Storage.Stat(key); err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// getOwnFavorite 获取属于ownerId的收藏
+func (f *favoriteService) getOwnFavorite(ownerId, favoriteId string) (*model.Favorite, string, int) {
+	favorite, err := f.favoriteDao.GetFavoriteByUUID(favoriteId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "收藏不存在", -2
+		}
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if favorite.OwnerId != ownerId {
+		return nil, "收藏不存在", -2
+	}
+	return favorite, "", 0
+}
+
+// AddMessageFavorite 收藏一条自己能看到的消息
+func (f *favoriteService) AddMessageFavorite(req request.AddMessageFavoriteRequest) (string, string, int) {
+	tags, message, ret := normalizeTags(req.Tags)
+	if ret != 0 {
+		return message, "", ret
+	}
+	msg, err := f.messageDao.GetMessageByUUID(req.MessageId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "消息不存在", "", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	if message, ret := checkMessageAccess(f.userContactDao, req.OwnerId, msg); ret != 0 {
+		return message, "", ret
+	}
+	if msg.Type == message_type_enum.AudioOrVideo {
+		return "该消息不支持收藏", "", -2
+	}
+	if existed, err := f.favoriteDao.GetFavoriteByMessage(req.OwnerId, msg.Uuid); err == nil {
+		return "已收藏该消息", existed.Uuid, 0
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+
+	// 单聊时来源为对方，群聊时来源为群聊
+	sourceId := msg.ReceiveId
+	if msg.ReceiveId == req.OwnerId {
+		sourceId = msg.SendId
+	}
+	favorite := model.Favorite{
+		Uuid:             fmt.Sprintf("F%s", random.GetNowAndLenRandomString(11)),
+		OwnerId:          req.OwnerId,
+		Type:             favorite_type_enum.MESSAGE,
+		MessageId:        msg.Uuid,
+		MessageType:      msg.Type,
+		SourceId:         sourceId,
+		SendId:           msg.SendId,
+		SendName:         msg.SendName,
+		SendAvatar:       msg.SendAvatar,
+		Content:          msg.Content,
+		FileType:         msg.FileType,
+		FileName:         msg.FileName,
+		FileSize:         msg.FileSize,
+		Payload:          msg.Payload,
+		MessageCreatedAt: sql.NullTime{Time: msg.CreatedAt, Valid: true},
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+	if msg.Url != "" {
+		favorite.Url, err = acquireMessageFile(msg.Url)
+		if err != nil {
+			zlog.Error(err.Error())
+			return "文件已失效，无法收藏", "", -2
+		}
+	}
+	favorite.Tags, err = json.Marshal(tags)
+	if err != nil {
+		zlog.Error(err.Error())
+		chat.ReleaseFile(favorite.Url)
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	if err := f.favoriteDao.CreateFavorite(&favorite); err != nil {
+		zlog.Error(err.Error())
+		chat.ReleaseFile(favorite.Url)
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	return "收藏成功", favorite.Uuid, 0
+}
+
+// AddNoteFavorite 添加笔记到收藏
+func (f *favoriteService) AddNoteFavorite(req request.AddNoteFavoriteRequest) (string, string, int) {
+	if strings.TrimSpace(req.Content) == "" {
+		return "笔记内容不能为空", "", -2
+	}
+	tags, message, ret := normalizeTags(req.Tags)
+	if ret != 0 {
+		return message, "", ret
+	}
+	favorite := model.Favorite{
+		Uuid:        fmt.Sprintf("F%s", random.GetNowAndLenRandomString(11)),
+		OwnerId:     req.OwnerId,
+		Type:        favorite_type_enum.NOTE,
+		MessageType: message_type_enum.Text,
+		Content:     req.Content,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	var err error
+	favorite.Tags, err = json.Marshal(tags)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	if err := f.favoriteDao.CreateFavorite(&favorite); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	return "收藏成功", favorite.Uuid, 0
+}
+
+// GetFavoriteList 分页获取收藏，支持关键字搜索和按标签过滤
+func (f *favoriteService) GetFavoriteList(req request.GetFavoriteListRequest) (string, *respond.FavoriteListRespond, int) {
+	page := req.Page
+	if page < 1 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = constants.FAVORITE_PAGE_SIZE
+	}
+	if pageSize > constants.FAVORITE_MAX_PAGE_SIZE {
+		pageSize = constants.FAVORITE_MAX_PAGE_SIZE
+	}
+	favorites, total, err := f.favoriteDao.SearchFavorites(req.OwnerId, strings.TrimSpace(req.Keyword), strings.TrimSpace(req.Tag), (page-1)*pageSize, pageSize)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rsp := &respond.FavoriteListRespond{
+		Total:     total,
+		Favorites: []respond.FavoriteRespond{},
+	}
+	for _, favorite := range favorites {
+		var tags []string
+		if err := json.Unmarshal(favorite.Tags, &tags); err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		favoriteRsp := respond.FavoriteRespond{
+			FavoriteId:  favorite.Uuid,
+			Type:        favorite.Type,
+			MessageId:   favorite.MessageId,
+			MessageType: favorite.MessageType,
+			SourceId:    favorite.SourceId,
+			SendId:      favorite.SendId,
+			SendName:    favorite.SendName,
+			SendAvatar:  favorite.SendAvatar,
+			Content:     favorite.Content,
+			Url:         favorite.Url,
+			FileType:    favorite.FileType,
+			FileName:    favorite.FileName,
+			FileSize:    favorite.FileSize,
+			Tags:        tags,
+			CreatedAt:   favorite.CreatedAt.Format("2006-01-02 15:04:05"),
+		}
+		// 位置和名片按原消息类型解析
+		payloadMessage := &model.Message{Type: favorite.MessageType, Payload: favorite.Payload}
+		favoriteRsp.Location = chat.MessageLocation(payloadMessage)
+		favoriteRsp.ContactCard = chat.MessageContactCard(payloadMessage)
+		if favorite.MessageCreatedAt.Valid {
+			favoriteRsp.MessageCreatedAt = favorite.MessageCreatedAt.Time.Format("2006-01-02 15:04:05")
+		}
+		rsp.Favorites = append(rsp.Favorites, favoriteRsp)
+	}
+	return "获取收藏成功", rsp, 0
+}
+
+// UpdateFavoriteTags 修改收藏的标签
+func (f *favoriteService) UpdateFavoriteTags(req request.UpdateFavoriteTagsRequest) (string, int) {
+	tags, message, ret := normalizeTags(req.Tags)
+	if ret != 0 {
+		return message, ret
+	}
+	favorite, message, ret := f.getOwnFavorite(req.OwnerId, req.FavoriteId)
+	if ret != 0 {
+		return message, ret
+	}
+	var err error
+	favorite.Tags, err = json.Marshal(tags)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	favorite.UpdatedAt = time.Now()
+	if err := f.favoriteDao.SaveFavorite(favorite); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	return "修改标签成功", 0
+}
+
+// DeleteFavorite 取消收藏，同时释放收藏引用的文件
+func (f *favoriteService) DeleteFavorite(ownerId, favoriteId string) (string, int) {
+	favorite, message, ret := f.getOwnFavorite(ownerId, favoriteId)
+	if ret != 0 {
+		return message, ret
+	}
+	if err := f.favoriteDao.DeleteFavorite(favoriteId); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	chat.ReleaseFile(favorite.Url)
+	return "已取消收藏", 0
+}
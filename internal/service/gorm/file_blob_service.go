@@ -0,0 +1,99 @@
+package gorm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/chat"
+	"kama_chat_server/internal/service/storage"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/zlog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// existingBlobKey 按sha256查找已存储的文件，找到时刷新更新时间避免被回收
+func existingBlobKey(fileBlobDao dao.FileBlobDAO, sum string) (string, bool, error) {
+	blob, err := fileBlobDao.GetBlobBySha256(sum)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	// 刷新失败说明刚好被回收，按新文件重新存储
+	ok, err := fileBlobDao.TouchBlob(blob.FileKey, time.Now())
+	if err != nil {
+		return "", false, err
+	}
+	return blob.FileKey, ok, nil
+}
+
+// putContentFile 按内容存储文件，key为files/<sha256><ext>，相同内容的文件只存一份
+// beforePut在写入新文件之前调用，用于生成缩略图等附属文件，文件已存在时不调用
+func putContentFile(fileBlobDao dao.FileBlobDAO, reader io.ReadSeeker, size int64, ext, contentType string, beforePut func(fileKey string) error) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if fileKey, ok, err := existingBlobKey(fileBlobDao, sum); err != nil {
+		return "", err
+	} else if ok {
+		return fileKey, nil
+	}
+
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	fileKey := storage.FileKey(sum + ext)
+	if beforePut != nil {
+		if err := beforePut(fileKey); err != nil {
+			return "", err
+		}
+	}
+	if err := storage.Storage.Put(fileKey, reader, size, contentType); err != nil {
+		return "", err
+	}
+	if err := registerBlob(fileBlobDao, sum, fileKey, size, contentType); err != nil {
+		return "", err
+	}
+	return fileKey, nil
+}
+
+// registerBlob 登记新存储的文件，引用计数从0开始，发送消息时才增加
+func registerBlob(fileBlobDao dao.FileBlobDAO, sum, fileKey string, size int64, contentType string) error {
+	return fileBlobDao.CreateOrTouchBlob(&model.FileBlob{
+		Sha256:      sum,
+		FileKey:     fileKey,
+		Size:        size,
+		ContentType: contentType,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+}
+
+// collectGarbageBlobs 回收没有消息和收藏引用、且超过保留期没有再被上传的文件
+// 保留期不短于定时消息的最长等待时间，上传后定时发送的文件不会被提前回收
+func collectGarbageBlobs(fileBlobDao dao.FileBlobDAO) {
+	before := time.Now().AddDate(0, 0, -constants.FILE_BLOB_GC_GRACE_DAYS)
+	blobs, err := fileBlobDao.GetGarbageBlobs(before, constants.FILE_BLOB_GC_BATCH)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	for _, blob := range blobs {
+		ok, err := fileBlobDao.DeleteGarbageBlob(blob.Id, before)
+		if err != nil {
+			zlog.Error(err.Error())
+			continue
+		}
+		if ok {
+			chat.DeleteFileAndThumbnail(blob.FileKey)
+		}
+	}
+}
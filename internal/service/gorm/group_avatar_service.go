@@ -0,0 +1,169 @@
+package gorm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"io"
+	myredis "kama_chat_server/internal/service/redis"
+	"kama_chat_server/internal/service/storage"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/util/imaging"
+	"kama_chat_server/pkg/zlog"
+	"path"
+	"sync"
+	"time"
+)
+
+// groupAvatarMu 串行生成群头像，同一个群连续的成员变动按顺序生效
+var groupAvatarMu sync.Mutex
+
+// groupAvatarBackground 群头像宫格的底色
+var groupAvatarBackground = color.NRGBA{R: 0xE5, G: 0xE5, B: 0xE5, A: 0xFF}
+
+// memberAvatarColors 成员没有可用头像时按uuid选择的占位颜色
+var memberAvatarColors = []color.NRGBA{
+	{R: 0x5B, G: 0x8F, B: 0xF9, A: 0xFF},
+	{R: 0x5A, G: 0xD8, B: 0xA6, A: 0xFF},
+	{R: 0xF6, G: 0xBD, B: 0x16, A: 0xFF},
+	{R: 0xE8, G: 0x68, B: 0x4A, A: 0xFF},
+	{R: 0x6D, G: 0xC8, B: 0xEC, A: 0xFF},
+	{R: 0x94, G: 0x67, B: 0xBD, A: 0xFF},
+}
+
+// groupAvatarKey 生成的群头像固定保存在该位置，每次覆盖，地址带版本号避免客户端缓存旧图
+// 旧的群信息被整体保存回去时，旧地址也仍然可以访问
+// 使用group_前缀，与用户上传的头像（服务端生成的数字文件名）区分开，上传不会覆盖群头像
+func groupAvatarKey(groupId string) string {
+	return storage.AvatarKey("group_" + groupId + ".png")
+}
+
+// loadMemberAvatar 读取成员头像，优先使用中等尺寸，不在存储中的头像（如默认头像）或读取失败时返回nil
+func loadMemberAvatar(avatar string) image.Image {
+	key, ok := storage.KeyFromURL(avatar)
+	if !ok || !storage.IsAvatarKey(key) {
+		return nil
+	}
+	for _, candidate := range []string{storage.AvatarSizeKey(path.Base(key), constants.AVATAR_MEDIUM_SIZE), key} {
+		img, err := decodeStoredImage(candidate)
+		if err == nil {
+			return img
+		}
+		if !errors.Is(err, storage.ErrNotExist) {
+			zlog.Warn(fmt.Sprintf("读取成员头像 %s 失败: %v", candidate, err))
+		}
+	}
+	return nil
+}
+
+// decodeStoredImage 从存储中读取并解码图片，早期未经处理的头像先检查尺寸
+func decodeStoredImage(key string) (image.Image, error) {
+	file, _, err := storage.Storage.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, constants.AVATAR_MAX_SIZE))
+	if err != nil {
+		return nil, err
+	}
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if imageConfig.Width*imageConfig.Height > constants.IMAGE_MAX_PIXELS {
+		return nil, fmt.Errorf("图片尺寸过大: %dx%d", imageConfig.Width, imageConfig.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// memberPlaceholder 按uuid选择颜色的纯色占位图
+func memberPlaceholder(userId string) image.Image {
+	h := fnv.New32a()
+	h.Write([]byte(userId))
+	return imaging.Solid(constants.AVATAR_MEDIUM_SIZE, memberAvatarColors[h.Sum32()%uint32(len(memberAvatarColors))])
+}
+
+// RefreshMemberGroupAvatars 成员修改头像或头像可见范围后，重新生成其所在群聊的群头像
+func (g *groupInfoService) RefreshMemberGroupAvatars(userId string) {
+	groupIds, err := g.groupDao.GetGeneratedAvatarGroupIds(userId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	for _, groupId := range groupIds {
+		g.RefreshGroupAvatar(groupId)
+	}
+}
+
+// RefreshGroupAvatar 用前几位成员的头像重新生成群头像，成员变动后用协程调用
+// 群主设置过自定义头像的群不处理
+func (g *groupInfoService) RefreshGroupAvatar(groupId string) {
+	groupAvatarMu.Lock()
+	defer groupAvatarMu.Unlock()
+
+	group, err := g.groupDao.GetGroupByUUID(groupId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if group.AvatarGenerated == 0 && group.Avatar != "" && group.Avatar != constants.DEFAULT_AVATAR {
+		return
+	}
+	var members []string
+	if err := json.Unmarshal(group.Members, &members); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if len(members) > constants.GROUP_AVATAR_MAX_MEMBERS {
+		members = members[:constants.GROUP_AVATAR_MAX_MEMBERS]
+	}
+	tiles := make([]image.Image, 0, len(members))
+	for _, member := range members {
+		var tile image.Image
+		// 群头像对所有能看到群的人可见，只使用对所有人公开的成员头像
+		if user, err := g.userDao.GetUserByUUID(member); err != nil {
+			zlog.Error(err.Error())
+		} else if checkVisible(user.AvatarVisibility, false, false) {
+			tile = loadMemberAvatar(user.Avatar)
+		}
+		if tile == nil {
+			tile = memberPlaceholder(member)
+		}
+		tiles = append(tiles, tile)
+	}
+
+	avatarKey := groupAvatarKey(groupId)
+	if err := putImage(avatarKey, imaging.Composite(tiles, constants.GROUP_AVATAR_SIZE, groupAvatarBackground), "png"); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	avatar := fmt.Sprintf("%s?v=%d", storage.Storage.URL(avatarKey), time.Now().UnixNano())
+	// 生成期间群主改了头像时不覆盖
+	updated, err := g.groupDao.UpdateGroupAvatar(groupId, group.Avatar, avatar, 1)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if !updated {
+		return
+	}
+
+	if err := myredis.DelKeysWithPattern("group_info_" + groupId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.DelKeysWithPrefix("group_session_list"); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.DelKeysWithPrefix("my_joined_group_list"); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.DelKeysWithPrefix("contact_mygroup_list"); err != nil {
+		zlog.Error(err.Error())
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"kama_chat_server/internal/dto/request"
 	"kama_chat_server/internal/dto/respond"
 	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/chat"
 	myredis "kama_chat_server/internal/service/redis"
 	"kama_chat_server/pkg/constants"
 	"kama_chat_server/pkg/enum/contact/contact_status_enum"
@@ -15,6 +16,7 @@ import (
 	"kama_chat_server/pkg/enum/group_info/group_status_enum"
 	"kama_chat_server/pkg/util/random"
 	"kama_chat_server/pkg/zlog"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -78,6 +80,7 @@ func (g *groupInfoService) CreateGroup(groupReq request.CreateGroupRequest) (str
 	if err := myredis.DelKeysWithPattern("contact_mygroup_list_" + groupReq.OwnerId); err != nil {
 		zlog.Error(err.Error())
 	}
+	go g.RefreshGroupAvatar(group.Uuid)
 
 	return "创建成功", 0
 }
@@ -133,14 +136,15 @@ func (g *groupInfoService) GetGroupInfo(groupId string) (string, *respond.GetGro
 				return constants.SYSTEM_ERROR, nil, -1
 			}
 			rsp := &respond.GetGroupInfoRespond{
-				Uuid:      group.Uuid,
-				Name:      group.Name,
-				Notice:    group.Notice,
-				Avatar:    group.Avatar,
-				MemberCnt: group.MemberCnt,
-				OwnerId:   group.OwnerId,
-				AddMode:   group.AddMode,
-				Status:    group.Status,
+				Uuid:       group.Uuid,
+				Name:       group.Name,
+				Notice:     group.Notice,
+				Avatar:     group.Avatar,
+				MemberCnt:  group.MemberCnt,
+				OwnerId:    group.OwnerId,
+				AddMode:    group.AddMode,
+				Status:     group.Status,
+				MessageTtl: group.MessageTtl,
 			}
 			if group.DeletedAt.Valid {
 				rsp.IsDeleted = true
@@ -238,11 +242,32 @@ func (g *groupInfoService) LeaveGroup(userId string, groupId string) (string, in
 	if err := myredis.DelKeysWithPattern("my_joined_group_list_ " + userId); err != nil {
 		zlog.Error(err.Error())
 	}
+	delRecommendListCache(userId)
+	go g.RefreshGroupAvatar(groupId)
+	chat.LeaveGroupCall(groupId, userId)
+
+	// 系统消息，退群者也需要收到
+	if user, err := g.userDao.GetUserByUUID(userId); err != nil {
+		zlog.Error(err.Error())
+	} else {
+		MessageService.SendGroupSystemMessage(group, user, fmt.Sprintf("%s 退出了群聊", user.Nickname), append(members, userId))
+	}
 	return "退群成功", 0
 }
 
 // DismissGroup 解散群聊
 func (g *groupInfoService) DismissGroup(ownerId, groupId string) (string, int) {
+	group, err := g.groupDao.GetGroupByUUID(groupId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	var members []string
+	if err := json.Unmarshal(group.Members, &members); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+
 	// 调用 DAO 事务
 	if err := g.groupDao.DismissGroup(groupId); err != nil {
 		zlog.Error(err.Error())
@@ -259,6 +284,13 @@ func (g *groupInfoService) DismissGroup(ownerId, groupId string) (string, int) {
 	if err := myredis.DelKeysWithPrefix("my_joined_group_list"); err != nil {
 		zlog.Error(err.Error())
 	}
+	chat.EndGroupCall(groupId)
+
+	if owner, err := g.userDao.GetUserByUUID(ownerId); err != nil {
+		zlog.Error(err.Error())
+	} else {
+		MessageService.SendGroupSystemMessage(group, owner, fmt.Sprintf("%s 解散了群聊", owner.Nickname), members)
+	}
 	return "解散群聊成功", 0
 }
 
@@ -277,6 +309,9 @@ func (g *groupInfoService) DeleteGroups(uuidList []string) (string, int) {
 	if err := myredis.DelKeysWithPrefix("group_session_list"); err != nil {
 		zlog.Error(err.Error())
 	}
+	for _, groupId := range uuidList {
+		chat.EndGroupCall(groupId)
+	}
 	return "解散/删除群聊成功", 0
 }
 
@@ -346,6 +381,14 @@ func (g *groupInfoService) EnterGroupDirectly(ownerId, contactId string) (string
 	if err := myredis.DelKeysWithPattern("my_joined_group_list_" + ownerId); err != nil {
 		zlog.Error(err.Error())
 	}
+	delRecommendListCache(contactId)
+	go g.RefreshGroupAvatar(ownerId)
+
+	if user, err := g.userDao.GetUserByUUID(contactId); err != nil {
+		zlog.Error(err.Error())
+	} else {
+		MessageService.SendGroupSystemMessage(group, user, fmt.Sprintf("%s 加入了群聊", user.Nickname), members)
+	}
 	return "进群成功", 0
 }
 
@@ -356,6 +399,11 @@ func (g *groupInfoService) SetGroupsStatus(uuidList []string, status int8) (stri
 		zlog.Error(err.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	if status == group_status_enum.DISABLE {
+		for _, groupId := range uuidList {
+			chat.EndGroupCall(groupId)
+		}
+	}
 	return "设置成功", 0
 }
 
@@ -373,11 +421,16 @@ func (g *groupInfoService) UpdateGroupInfo(req request.UpdateGroupInfoRequest) (
 	if req.AddMode != -1 {
 		group.AddMode = req.AddMode
 	}
-	if req.Notice != "" {
+	noticeChanged := false
+	if req.Notice != "" && req.Notice != group.Notice {
 		group.Notice = req.Notice
+		noticeChanged = true
 	}
-	if req.Avatar != "" {
+	// 改回默认头像时重新生成，其他头像视为自定义头像，之后成员变动不再覆盖
+	resetAvatar := req.Avatar == constants.DEFAULT_AVATAR
+	if req.Avatar != "" && req.Avatar != group.Avatar {
 		group.Avatar = req.Avatar
+		group.AvatarGenerated = 0
 	}
 
 	// 调用 DAO 事务
@@ -386,6 +439,19 @@ func (g *groupInfoService) UpdateGroupInfo(req request.UpdateGroupInfoRequest) (
 		return constants.SYSTEM_ERROR, -1
 	}
 
+	if resetAvatar {
+		go g.RefreshGroupAvatar(group.Uuid)
+	}
+	if noticeChanged {
+		var members []string
+		if err := json.Unmarshal(group.Members, &members); err != nil {
+			zlog.Error(err.Error())
+		} else if operator, err := g.userDao.GetUserByUUID(req.OwnerId); err != nil {
+			zlog.Error(err.Error())
+		} else {
+			MessageService.SendGroupSystemMessage(group, operator, fmt.Sprintf("%s 修改了群公告：%s", operator.Nickname, group.Notice), members)
+		}
+	}
 	return "更新成功", 0
 }
 
@@ -483,5 +549,87 @@ func (g *groupInfoService) RemoveGroupMembers(req request.RemoveGroupMembersRequ
 	if err := myredis.DelKeysWithPrefix("my_joined_group_list"); err != nil {
 		zlog.Error(err.Error())
 	}
+
+	if len(removedUUIDs) > 0 {
+		delRecommendListCache(removedUUIDs...)
+		go g.RefreshGroupAvatar(group.Uuid)
+		chat.LeaveGroupCall(group.Uuid, removedUUIDs...)
+		var removedNames []string
+		for _, uuid := range removedUUIDs {
+			user, err := g.userDao.GetUserByUUID(uuid)
+			if err != nil {
+				zlog.Error(err.Error())
+				continue
+			}
+			removedNames = append(removedNames, user.Nickname)
+		}
+		if operator, err := g.userDao.GetUserByUUID(req.OwnerId); err != nil {
+			zlog.Error(err.Error())
+		} else {
+			// 被移除的成员也需要收到
+			MessageService.SendGroupSystemMessage(group, operator, fmt.Sprintf("%s 将 %s 移出了群聊", operator.Nickname, strings.Join(removedNames, "、")), append(members, removedUUIDs...))
+		}
+	}
 	return "移除群聊成员成功", 0
 }
+
+// TransferGroupOwner 转让群主
+func (g *groupInfoService) TransferGroupOwner(req request.TransferGroupOwnerRequest) (string, int) {
+	group, err := g.groupDao.GetGroupByUUID(req.GroupId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if group.OwnerId != req.OwnerId {
+		return "只有群主才能转让群聊", -2
+	}
+	if req.NewOwnerId == req.OwnerId {
+		return "不能转让给自己", -2
+	}
+
+	var members []string
+	if err := json.Unmarshal(group.Members, &members); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	found := false
+	for _, member := range members {
+		if member == req.NewOwnerId {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "该用户不在群组中", -2
+	}
+
+	group.OwnerId = req.NewOwnerId
+	group.UpdatedAt = time.Now()
+	if err := g.groupDao.SaveGroup(group); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+
+	// 我创建的群、我加入的群都发生了变化
+	for _, uuid := range []string{req.OwnerId, req.NewOwnerId} {
+		if err := myredis.DelKeysWithPattern("contact_mygroup_list_" + uuid); err != nil {
+			zlog.Error(err.Error())
+		}
+		if err := myredis.DelKeysWithPattern("my_joined_group_list_" + uuid); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+
+	owner, err := g.userDao.GetUserByUUID(req.OwnerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return "转让群主成功", 0
+	}
+	newOwner, err := g.userDao.GetUserByUUID(req.NewOwnerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return "转让群主成功", 0
+	}
+	MessageService.SendGroupSystemMessage(group, owner, fmt.Sprintf("%s 将群主转让给了 %s", owner.Nickname, newOwner.Nickname), members)
+	return "转让群主成功", 0
+}
@@ -0,0 +1,252 @@
+package gorm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/service/chat"
+	myredis "kama_chat_server/internal/service/redis"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/contact/contact_status_enum"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/zlog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type messageExpireService struct {
+	messageDao     dao.MessageDAO
+	sessionDao     dao.SessionDAO
+	groupDao       dao.GroupDAO
+	userDao        dao.UserDAO
+	userContactDao dao.UserContactDAO
+	stop           chan struct{}
+}
+
+var MessageExpireService *messageExpireService
+
+func InitMessageExpireService(messageDao dao.MessageDAO, sessionDao dao.SessionDAO, groupDao dao.GroupDAO, userDao dao.UserDAO, userContactDao dao.UserContactDAO) {
+	MessageExpireService = &messageExpireService{
+		messageDao:     messageDao,
+		sessionDao:     sessionDao,
+		groupDao:       groupDao,
+		userDao:        userDao,
+		userContactDao: userContactDao,
+		stop:           make(chan struct{}),
+	}
+}
+
+// formatTtl 将过期时长转换为系统消息中展示的文字
+func formatTtl(ttl int) string {
+	switch {
+	case ttl%86400 == 0:
+		return fmt.Sprintf("%d天", ttl/86400)
+	case ttl%3600 == 0:
+		return fmt.Sprintf("%d小时", ttl/3600)
+	case ttl%60 == 0:
+		return fmt.Sprintf("%d分钟", ttl/60)
+	}
+	return fmt.Sprintf("%d秒", ttl)
+}
+
+// SetMessageTtl 设置消息过期时长，只影响设置之后发送的消息
+// 单聊任意一方都可以设置，双方同时生效；群聊只有群主可以设置
+func (m *messageExpireService) SetMessageTtl(req request.SetMessageTtlRequest) (string, int) {
+	if req.Ttl != 0 && (req.Ttl < constants.MESSAGE_TTL_MIN || req.Ttl > constants.MESSAGE_TTL_MAX) {
+		return fmt.Sprintf("过期时长需要在%d秒到%d天之间", constants.MESSAGE_TTL_MIN, constants.MESSAGE_TTL_MAX/86400), -2
+	}
+	if req.ContactId[0] == 'G' {
+		group, err := m.groupDao.GetGroupByUUID(req.ContactId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "群聊不存在", -2
+			}
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		if group.OwnerId != req.OwnerId {
+			return "只有群主可以设置消息过期", -2
+		}
+		group.MessageTtl = req.Ttl
+		group.UpdatedAt = time.Now()
+		if err := m.groupDao.SaveGroup(group); err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		if err := myredis.DelKeysWithPattern("group_info_" + group.Uuid); err != nil {
+			zlog.Error(err.Error())
+		}
+		if owner, err := m.userDao.GetUserByUUID(req.OwnerId); err != nil {
+			zlog.Error(err.Error())
+		} else {
+			content := fmt.Sprintf("%s 关闭了消息自动删除", owner.Nickname)
+			if req.Ttl > 0 {
+				content = fmt.Sprintf("%s 设置了消息在发送%s后自动删除", owner.Nickname, formatTtl(req.Ttl))
+			}
+			var members []string
+			if err := json.Unmarshal(group.Members, &members); err != nil {
+				zlog.Error(err.Error())
+			}
+			MessageService.SendGroupSystemMessage(group, owner, content, members)
+		}
+		return "设置成功", 0
+	}
+
+	contact, err := m.userContactDao.GetUserContact(req.OwnerId, req.ContactId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "该联系人不存在", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if contact.Status != contact_status_enum.NORMAL {
+		return "该联系人不存在", -2
+	}
+	if err := m.userContactDao.SetContactMessageTtl(req.OwnerId, req.ContactId, req.Ttl, time.Now()); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	return "设置成功", 0
+}
+
+// Start 启动过期消息清理，由主进程用协程起
+// 过期时间落在消息表中，服务停机期间过期的消息会在重启后被清理
+func (m *messageExpireService) Start() {
+	ticker := time.NewTicker(time.Second * constants.MESSAGE_EXPIRE_SWEEP_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.sweepExpiredMessages()
+		}
+	}
+}
+
+// Close 停止过期消息清理
+func (m *messageExpireService) Close() {
+	close(m.stop)
+}
+
+// expiredConversation 同一个单聊或群聊中本次过期的消息
+type expiredConversation struct {
+	sendId     string // 群聊为空
+	receiveId  string
+	messageIds []string
+	latestAt   time.Time // 过期消息中最新的发送时间
+}
+
+// sweepExpiredMessages 删除已过期的消息及其文件，清理缓存和会话预览，并通知在线的用户
+func (m *messageExpireService) sweepExpiredMessages() {
+	for {
+		messages, err := m.messageDao.GetExpiredMessages(time.Now(), constants.MESSAGE_EXPIRE_BATCH)
+		if err != nil {
+			zlog.Error(err.Error())
+			return
+		}
+		if len(messages) == 0 {
+			return
+		}
+		uuids := make([]string, 0, len(messages))
+		for _, message := range messages {
+			uuids = append(uuids, message.Uuid)
+		}
+		if err := m.messageDao.DeleteMessages(uuids); err != nil {
+			zlog.Error(err.Error())
+			return
+		}
+
+		conversations := make(map[string]*expiredConversation)
+		for i := range messages {
+			message := &messages[i]
+			// 缩略图随原图一起回收
+			chat.ReleaseFile(message.Url)
+			key := message.ReceiveId
+			sendId := ""
+			if message.ReceiveId[0] == 'U' {
+				sendId = message.SendId
+				if message.SendId < message.ReceiveId {
+					key = message.SendId + "_" + message.ReceiveId
+				} else {
+					key = message.ReceiveId + "_" + message.SendId
+				}
+			}
+			conversation, ok := conversations[key]
+			if !ok {
+				conversation = &expiredConversation{sendId: sendId, receiveId: message.ReceiveId}
+				conversations[key] = conversation
+			}
+			conversation.messageIds = append(conversation.messageIds, message.Uuid)
+			if message.CreatedAt.After(conversation.latestAt) {
+				conversation.latestAt = message.CreatedAt
+			}
+		}
+		for _, conversation := range conversations {
+			m.cleanExpiredConversation(conversation)
+		}
+
+		if len(messages) < constants.MESSAGE_EXPIRE_BATCH {
+			return
+		}
+	}
+}
+
+// cleanExpiredConversation 消息删除后，清理该会话的消息缓存、会话列表预览，并通知在线的用户删除
+func (m *messageExpireService) cleanExpiredConversation(conversation *expiredConversation) {
+	var receivers []string
+	if conversation.sendId == "" {
+		if err := myredis.DelKeys("group_messagelist_" + conversation.receiveId); err != nil {
+			zlog.Error(err.Error())
+		}
+		owners, err := m.sessionDao.ClearGroupLastMessageBefore(conversation.receiveId, conversation.latestAt)
+		if err != nil {
+			zlog.Error(err.Error())
+		}
+		var sessionKeys []string
+		for _, owner := range owners {
+			sessionKeys = append(sessionKeys, "group_session_list_"+owner)
+		}
+		if err := myredis.DelKeys(sessionKeys...); err != nil {
+			zlog.Error(err.Error())
+		}
+		group, err := m.groupDao.GetGroupByUUID(conversation.receiveId)
+		if err != nil {
+			zlog.Error(err.Error())
+			return
+		}
+		if err := json.Unmarshal(group.Members, &receivers); err != nil {
+			zlog.Error(err.Error())
+			return
+		}
+	} else {
+		userOne, userTwo := conversation.sendId, conversation.receiveId
+		if err := myredis.DelKeys("message_list_"+userOne+"_"+userTwo, "message_list_"+userTwo+"_"+userOne); err != nil {
+			zlog.Error(err.Error())
+		}
+		if err := m.sessionDao.ClearLastMessageBefore(userOne, userTwo, conversation.latestAt); err != nil {
+			zlog.Error(err.Error())
+		}
+		if err := myredis.DelKeys("session_list_"+userOne, "session_list_"+userTwo); err != nil {
+			zlog.Error(err.Error())
+		}
+		receivers = []string{userOne, userTwo}
+	}
+
+	jsonMessage, err := json.Marshal(respond.MessageExpiredRespond{
+		Type:       message_type_enum.Expire,
+		SendId:     conversation.sendId,
+		ReceiveId:  conversation.receiveId,
+		MessageIds: conversation.messageIds,
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	chat.SendMessageToUsers(receivers, &chat.MessageBack{Message: jsonMessage})
+}
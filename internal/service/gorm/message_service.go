@@ -1,34 +1,49 @@
 package gorm
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
 	"io"
-	"kama_chat_server/internal/config"
 	"kama_chat_server/internal/dao"
 	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/chat"
+	"kama_chat_server/internal/service/filecheck"
 	myredis "kama_chat_server/internal/service/redis"
+	"kama_chat_server/internal/service/storage"
 	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/message/message_status_enum"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/util/filename"
+	"kama_chat_server/pkg/util/imaging"
 	"kama_chat_server/pkg/util/random"
 	"kama_chat_server/pkg/zlog"
-	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 )
 
 type messageService struct {
-	messageDao dao.MessageDAO
+	messageDao  dao.MessageDAO
+	fileBlobDao dao.FileBlobDAO
 }
 
 var MessageService *messageService
 
-func InitMessageService(messageDao dao.MessageDAO) {
+func InitMessageService(messageDao dao.MessageDAO, fileBlobDao dao.FileBlobDAO) {
 	MessageService = &messageService{
-		messageDao: messageDao,
+		messageDao:  messageDao,
+		fileBlobDao: fileBlobDao,
 	}
 }
 
@@ -47,17 +62,24 @@ func (m *messageService) GetMessageList(userOneId, userTwoId string) (string, []
 			var rspList []respond.GetMessageListRespond
 			for _, message := range messageList {
 				rspList = append(rspList, respond.GetMessageListRespond{
-					SendId:     message.SendId,
-					SendName:   message.SendName,
-					SendAvatar: message.SendAvatar,
-					ReceiveId:  message.ReceiveId,
-					Content:    message.Content,
-					Url:        message.Url,
-					Type:       message.Type,
-					FileType:   message.FileType,
-					FileName:   message.FileName,
-					FileSize:   message.FileSize,
-					CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+					MessageId:    message.Uuid,
+					SendId:       message.SendId,
+					SendName:     message.SendName,
+					SendAvatar:   message.SendAvatar,
+					ReceiveId:    message.ReceiveId,
+					Content:      message.Content,
+					Url:          message.Url,
+					Type:         message.Type,
+					FileType:     message.FileType,
+					FileName:     message.FileName,
+					FileSize:     message.FileSize,
+					Location:     chat.MessageLocation(message),
+					ContactCard:  chat.MessageContactCard(message),
+					Duration:     message.Duration,
+					Width:        message.Width,
+					Height:       message.Height,
+					ThumbnailUrl: message.ThumbnailUrl,
+					CreatedAt:    message.CreatedAt.Format("2006-01-02 15:04:05"),
 				})
 			}
 			//rspString, err := json.Marshal(rspList)
@@ -98,17 +120,24 @@ func (m *messageService) GetGroupMessageList(groupId string) (string, []respond.
 			var rspList []respond.GetGroupMessageListRespond
 			for _, message := range messageList {
 				rsp := respond.GetGroupMessageListRespond{
-					SendId:     message.SendId,
-					SendName:   message.SendName,
-					SendAvatar: message.SendAvatar,
-					ReceiveId:  message.ReceiveId,
-					Content:    message.Content,
-					Url:        message.Url,
-					Type:       message.Type,
-					FileType:   message.FileType,
-					FileName:   message.FileName,
-					FileSize:   message.FileSize,
-					CreatedAt:  message.CreatedAt.Format("2006-01-02 15:04:05"),
+					MessageId:    message.Uuid,
+					SendId:       message.SendId,
+					SendName:     message.SendName,
+					SendAvatar:   message.SendAvatar,
+					ReceiveId:    message.ReceiveId,
+					Content:      message.Content,
+					Url:          message.Url,
+					Type:         message.Type,
+					FileType:     message.FileType,
+					FileName:     message.FileName,
+					FileSize:     message.FileSize,
+					Location:     chat.MessageLocation(message),
+					ContactCard:  chat.MessageContactCard(message),
+					Duration:     message.Duration,
+					Width:        message.Width,
+					Height:       message.Height,
+					ThumbnailUrl: message.ThumbnailUrl,
+					CreatedAt:    message.CreatedAt.Format("2006-01-02 15:04:05"),
 				}
 				rspList = append(rspList, rsp)
 			}
@@ -132,89 +161,425 @@ func (m *messageService) GetGroupMessageList(groupId string) (string, []respond.
 	return "获取聊天记录成功", rsp, 0
 }
 
+// uploadCheckFailed 文件未通过检查时把原因返回给用户，检查本身出错时为系统错误
+func uploadCheckFailed(err error) (string, int) {
+	var rejectErr *filecheck.RejectError
+	if errors.As(err, &rejectErr) {
+		if rejectErr.Detail != "" {
+			zlog.Warn(fmt.Sprintf("上传的文件未通过检查: %s, %s", rejectErr.Reason, rejectErr.Detail))
+		}
+		return rejectErr.Reason, -2
+	}
+	zlog.Error(err.Error())
+	return constants.SYSTEM_ERROR, -1
+}
+
+// avatarFormats 头像按扩展名确定访问时的类型，只允许图片扩展名，按扩展名对应的格式重新编码
+var avatarFormats = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+}
+
+// avatarSizes 头像保存的缩小尺寸，原尺寸保存在原文件名下
+var avatarSizes = []int{constants.AVATAR_MEDIUM_SIZE, constants.AVATAR_SMALL_SIZE}
+
+// putImage 编码后写入存储
+func putImage(key string, img image.Image, format string) error {
+	encoded, err := encodeToBuffer(func(w io.Writer) error {
+		return imaging.Encode(w, img, format)
+	})
+	if err != nil {
+		return err
+	}
+	return storage.Storage.Put(key, encoded, encoded.Size(), "image/"+format)
+}
+
 // UploadAvatar 上传头像
-func (m *messageService) UploadAvatar(c *gin.Context) (string, int) {
+// 头像按服务端生成的文件名保存，不同用户上传同名文件不会互相覆盖，前端使用返回的url
+// 头像居中裁剪为正方形，最大边长AVATAR_SIZE，另外保存中、小两个尺寸，gif只保留第一帧
+func (m *messageService) UploadAvatar(c *gin.Context) (string, *respond.UploadAvatarRespond, int) {
 	if err := c.Request.ParseMultipartForm(constants.FILE_MAX_SIZE); err != nil {
 		zlog.Error(err.Error())
-		return constants.SYSTEM_ERROR, -1
+		return constants.SYSTEM_ERROR, nil, -1
 	}
 	mForm := c.Request.MultipartForm
 	var newFileName string
+	var rsp *respond.UploadAvatarRespond
 
 	for key, _ := range mForm.File {
 		file, fileHeader, err := c.Request.FormFile(key)
 		if err != nil {
 			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+			return constants.SYSTEM_ERROR, nil, -1
 		}
 		defer file.Close()
 		zlog.Info(fmt.Sprintf("文件名:%s,文件大小:%d", fileHeader.Filename, fileHeader.Size))
 
-		ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-		allowExts := map[string]bool{
-			".jpg":  true,
-			".jpeg": true,
-			".png":  true,
-			".gif":  true,
+		avatarName := filename.Sanitize(fileHeader.Filename)
+		ext := strings.ToLower(filepath.Ext(avatarName))
+		format, ok := avatarFormats[ext]
+		if !ok {
+			return "不支持的文件格式", nil, -2
 		}
-		if !allowExts[ext] {
-			zlog.Error("不支持的文件类型")
-			return constants.SYSTEM_ERROR, -1
+		if fileHeader.Size > constants.AVATAR_MAX_SIZE {
+			return fmt.Sprintf("头像不能超过%dMB", constants.AVATAR_MAX_SIZE/1024/1024), nil, -2
 		}
-
-		newFileName = random.GetNowAndLenRandomString(20) + ext
-
-		localFileName := config.GetConfig().StaticAvatarPath + "/" + fileHeader.Filename
-		out, err := os.Create(localFileName)
+		data, err := io.ReadAll(io.LimitReader(file, constants.AVATAR_MAX_SIZE))
 		if err != nil {
 			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+			return constants.SYSTEM_ERROR, nil, -1
 		}
-		defer out.Close()
-		if _, err := io.Copy(out, file); err != nil {
+		if _, err := filecheck.Check(filecheck.KindAvatar, avatarName, bytes.NewReader(data), int64(len(data))); err != nil {
+			message, ret := uploadCheckFailed(err)
+			return message, nil, ret
+		}
+		imageConfig, realFormat, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || imageExts[realFormat] == "" {
+			return "不支持的图片格式", nil, -2
+		}
+		if imageConfig.Width*imageConfig.Height > constants.IMAGE_MAX_PIXELS {
+			return "图片尺寸过大", nil, -2
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return "图片已损坏", nil, -2
+		}
+		if realFormat == "jpeg" {
+			if orientation := imaging.Orientation(data); orientation != 1 {
+				img = imaging.ApplyOrientation(img, orientation)
+			}
+		}
+		avatar := imaging.Fit(imaging.CropSquare(img), constants.AVATAR_SIZE)
+
+		newFileName = random.GetNowAndLenRandomString(20) + ext
+		// 先写缩小的尺寸，原文件名可以访问时其他尺寸一定已经存在
+		rsp = &respond.UploadAvatarRespond{
+			FileName: newFileName,
+			SizeUrls: make(map[int]string, len(avatarSizes)),
+		}
+		for _, size := range avatarSizes {
+			sizeKey := storage.AvatarSizeKey(newFileName, size)
+			if err := putImage(sizeKey, imaging.Resize(avatar, size, size), format); err != nil {
+				zlog.Error(err.Error())
+				return constants.SYSTEM_ERROR, nil, -1
+			}
+			rsp.SizeUrls[size] = storage.Storage.URL(sizeKey)
+		}
+		avatarKey := storage.AvatarKey(newFileName)
+		if err := putImage(avatarKey, avatar, format); err != nil {
 			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+			return constants.SYSTEM_ERROR, nil, -1
 		}
+		rsp.Url = storage.Storage.URL(avatarKey)
 		zlog.Info("完成头像上传")
 	}
-	return newFileName, 0
+	return newFileName, rsp, 0
 }
 
-// UploadFile 上传文件
-func (m *messageService) UploadFile(c *gin.Context) (string, int) {
+// UploadFile 上传文件，表单中的owner_id和receive_id用于检查上传者和群聊的存储配额
+func (m *messageService) UploadFile(c *gin.Context) (string, *respond.UploadFileRespond, int) {
 	if err := c.Request.ParseMultipartForm(constants.FILE_MAX_SIZE); err != nil {
 		zlog.Error(err.Error())
-		return constants.SYSTEM_ERROR, -1
+		return constants.SYSTEM_ERROR, nil, -1
 	}
+	ownerId := c.Request.FormValue("owner_id")
+	groupId := quotaGroupId(c.Request.FormValue("receive_id"))
 	mForm := c.Request.MultipartForm
 	var newFileName string
+	var rsp *respond.UploadFileRespond
 
 	for key, _ := range mForm.File {
 		file, fileHeader, err := c.Request.FormFile(key)
 		if err != nil {
 			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+			return constants.SYSTEM_ERROR, nil, -1
 		}
 		defer file.Close()
 		zlog.Info(fmt.Sprintf("文件名:%s,文件大小:%d", fileHeader.Filename, fileHeader.Size))
+		if message, ret := StorageQuotaService.CheckQuota(ownerId, groupId, fileHeader.Size); ret != 0 {
+			return message, nil, ret
+		}
 
-		// 获取后缀
-		ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
-		newFileName = random.GetNowAndLenRandomString(20) + ext
+		// 先清理文件名，检查的扩展名与保存的扩展名一致
+		fileName := filename.Sanitize(fileHeader.Filename)
+		contentType, err := filecheck.Check(filecheck.KindFile, fileName, file, fileHeader.Size)
+		if err != nil {
+			message, ret := uploadCheckFailed(err)
+			return message, nil, ret
+		}
 
-		localFileName := config.GetConfig().StaticFilePath + "/" + newFileName
-		out, err := os.Create(localFileName)
+		// 获取后缀，按内容存储，相同文件只存一份
+		ext := strings.ToLower(filepath.Ext(fileName))
+		fileKey, err := putContentFile(m.fileBlobDao, file, fileHeader.Size, ext, contentType, nil)
 		if err != nil {
 			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+			return constants.SYSTEM_ERROR, nil, -1
 		}
-		defer out.Close()
-		if _, err := io.Copy(out, file); err != nil {
-			zlog.Error(err.Error())
-			return constants.SYSTEM_ERROR, -1
+		StorageQuotaService.RecordUsage(ownerId, groupId, fileKey, fileHeader.Size, message_type_enum.File)
+		newFileName = path.Base(fileKey)
+		rsp = &respond.UploadFileRespond{
+			FileName: newFileName,
+			Url:      storage.Storage.URL(fileKey),
 		}
 		zlog.Info("完成文件上传: " + newFileName)
 	}
 	// 【关键修正】返回新生成的文件名
-	return newFileName, 0
+	return newFileName, rsp, 0
+}
+
+// UploadVoice 上传语音，和文件一样存到files下，额外检查格式、大小和表单中的duration
+func (m *messageService) UploadVoice(c *gin.Context) (string, *respond.UploadFileRespond, int) {
+	if err := c.Request.ParseMultipartForm(constants.FILE_MAX_SIZE); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	duration, err := strconv.Atoi(c.Request.FormValue("duration"))
+	if err != nil {
+		return "语音时长不合法", nil, -2
+	}
+	file, fileHeader, err := c.Request.FormFile("file")
+	if err != nil {
+		zlog.Error(err.Error())
+		return "请选择语音文件", nil, -2
+	}
+	defer file.Close()
+	zlog.Info(fmt.Sprintf("语音文件名:%s,文件大小:%d,时长:%d", fileHeader.Filename, fileHeader.Size, duration))
+	fileName := filename.Sanitize(fileHeader.Filename)
+	if msg := chat.CheckVoice(fileName, duration); msg != "" {
+		return msg, nil, -2
+	}
+	if fileHeader.Size > constants.VOICE_MAX_SIZE {
+		return fmt.Sprintf("语音文件不能超过%dMB", constants.VOICE_MAX_SIZE/1024/1024), nil, -2
+	}
+	if msg := chat.CheckVoiceSize(fileHeader.Size, duration); msg != "" {
+		return msg, nil, -2
+	}
+	ownerId := c.Request.FormValue("owner_id")
+	groupId := quotaGroupId(c.Request.FormValue("receive_id"))
+	if message, ret := StorageQuotaService.CheckQuota(ownerId, groupId, fileHeader.Size); ret != 0 {
+		return message, nil, ret
+	}
+
+	contentType, err := filecheck.Check(filecheck.KindVoice, fileName, file, fileHeader.Size)
+	if err != nil {
+		message, ret := uploadCheckFailed(err)
+		return message, nil, ret
+	}
+	fileKey, err := putContentFile(m.fileBlobDao, file, fileHeader.Size, strings.ToLower(filepath.Ext(fileName)), contentType, nil)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	StorageQuotaService.RecordUsage(ownerId, groupId, fileKey, fileHeader.Size, message_type_enum.Voice)
+	newFileName := path.Base(fileKey)
+	zlog.Info("完成语音上传: " + newFileName)
+	return newFileName, &respond.UploadFileRespond{
+		FileName: newFileName,
+		Url:      storage.Storage.URL(fileKey),
+	}, 0
+}
+
+// imageExts 按图片真实格式决定保存的后缀，不使用上传的文件名后缀
+var imageExts = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+	"gif":  ".gif",
+}
+
+// encodeToBuffer 编码到内存中，用于计算sha256后按内容存储
+func encodeToBuffer(encode func(w io.Writer) error) (*bytes.Reader, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+// UploadImage 上传图片
+// 根据文件内容识别真实格式，重新编码去掉EXIF等元数据（jpeg先按EXIF方向转正），并生成缩略图
+func (m *messageService) UploadImage(c *gin.Context) (string, *respond.UploadImageRespond, int) {
+	if err := c.Request.ParseMultipartForm(constants.FILE_MAX_SIZE); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	file, fileHeader, err := c.Request.FormFile("file")
+	if err != nil {
+		zlog.Error(err.Error())
+		return "请选择图片", nil, -2
+	}
+	defer file.Close()
+	zlog.Info(fmt.Sprintf("图片文件名:%s,文件大小:%d", fileHeader.Filename, fileHeader.Size))
+	if fileHeader.Size > constants.IMAGE_MAX_SIZE {
+		return fmt.Sprintf("图片不能超过%dMB", constants.IMAGE_MAX_SIZE/1024/1024), nil, -2
+	}
+	ownerId := c.Request.FormValue("owner_id")
+	groupId := quotaGroupId(c.Request.FormValue("receive_id"))
+	if message, ret := StorageQuotaService.CheckQuota(ownerId, groupId, fileHeader.Size); ret != 0 {
+		return message, nil, ret
+	}
+	data, err := io.ReadAll(io.LimitReader(file, constants.IMAGE_MAX_SIZE))
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	imageConfig, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || imageExts[format] == "" {
+		return "不支持的图片格式", nil, -2
+	}
+	if imageConfig.Width*imageConfig.Height > constants.IMAGE_MAX_PIXELS {
+		return "图片尺寸过大", nil, -2
+	}
+	if _, err := filecheck.Check(filecheck.KindImage, filename.Sanitize(fileHeader.Filename), bytes.NewReader(data), int64(len(data))); err != nil {
+		message, ret := uploadCheckFailed(err)
+		return message, nil, ret
+	}
+
+	var encoded *bytes.Reader
+	var cover image.Image
+	if format == "gif" {
+		// gif可能是动图，逐帧重新编码，缩略图取第一帧
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(g.Image) == 0 {
+			return "图片已损坏", nil, -2
+		}
+		encoded, err = encodeToBuffer(func(w io.Writer) error {
+			return gif.EncodeAll(w, g)
+		})
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+		draw.Draw(canvas, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+		cover = canvas
+	} else {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return "图片已损坏", nil, -2
+		}
+		if format == "jpeg" {
+			if orientation := imaging.Orientation(data); orientation != 1 {
+				img = imaging.ApplyOrientation(img, orientation)
+			}
+		}
+		encoded, err = encodeToBuffer(func(w io.Writer) error {
+			return imaging.Encode(w, img, format)
+		})
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		cover = img
+	}
+
+	// 重新编码的结果相同时按内容去重，已存在的图片不再生成缩略图
+	thumbnailFormat := "png"
+	if format == "jpeg" {
+		thumbnailFormat = "jpeg"
+	}
+	fileKey, err := putContentFile(m.fileBlobDao, encoded, encoded.Size(), imageExts[format], "image/"+format, func(fileKey string) error {
+		thumbnail, err := encodeToBuffer(func(w io.Writer) error {
+			return imaging.Encode(w, imaging.Fit(cover, constants.IMAGE_THUMBNAIL_SIDE), thumbnailFormat)
+		})
+		if err != nil {
+			return err
+		}
+		return storage.Storage.Put(storage.FileKey(chat.ImageThumbnailName(path.Base(fileKey))), thumbnail, thumbnail.Size(), "image/"+thumbnailFormat)
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	StorageQuotaService.RecordUsage(ownerId, groupId, fileKey, encoded.Size(), message_type_enum.Image)
+	newFileName := path.Base(fileKey)
+	rsp := &respond.UploadImageRespond{
+		FileName:      newFileName,
+		ThumbnailName: chat.ImageThumbnailName(newFileName),
+		Width:         cover.Bounds().Dx(),
+		Height:        cover.Bounds().Dy(),
+		FileSize:      encoded.Size(),
+	}
+	rsp.Url = storage.Storage.URL(fileKey)
+	rsp.ThumbnailUrl = storage.Storage.URL(storage.FileKey(rsp.ThumbnailName))
+	zlog.Info("完成图片上传: " + newFileName)
+	return "上传图片成功", rsp, 0
+}
+
+// OpenStaticFile 打开存储中的头像用于下载，聊天文件需要通过签名地址下载
+func (m *messageService) OpenStaticFile(key string) (io.ReadSeekCloser, *storage.ObjectInfo, string, int) {
+	key, ok := storage.CleanKey(key)
+	if !ok || !storage.IsAvatarKey(key) {
+		return nil, nil, "文件不存在", -2
+	}
+	file, info, err := storage.Storage.Open(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return nil, nil, "文件不存在", -2
+		}
+		zlog.Error(err.Error())
+		return nil, nil, constants.SYSTEM_ERROR, -1
+	}
+	return file, info, "", 0
+}
+
+// SendGroupSystemMessage 发送群聊系统消息
+// 入群、退群、踢人、修改群公告、解散、转让群主等事件发生后调用，消息落库进入群聊记录，并推送给在线的receivers
+// 事件本身已经成功，这里失败只记录日志，不影响调用方的返回
+func (m *messageService) SendGroupSystemMessage(group *model.GroupInfo, operator *model.UserInfo, content string, receivers []string) {
+	message := model.Message{
+		Uuid:       fmt.Sprintf("M%s", random.GetNowAndLenRandomString(11)),
+		SessionId:  "",
+		Type:       message_type_enum.System,
+		Content:    content,
+		Url:        "",
+		SendId:     operator.Uuid,
+		SendName:   operator.Nickname,
+		SendAvatar: operator.Avatar,
+		ReceiveId:  group.Uuid,
+		FileSize:   "0B",
+		FileType:   "",
+		FileName:   "",
+		Status:     message_status_enum.Unsent,
+		CreatedAt:  time.Now(),
+		AVdata:     "",
+	}
+	chat.SetMessageExpireAt(&message)
+	if err := m.messageDao.CreateMessage(&message); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	chat.RecordSessionActivity(&message)
+	if err := myredis.DelKeysWithPattern("group_messagelist_" + group.Uuid); err != nil {
+		zlog.Error(err.Error())
+	}
+
+	messageRsp := respond.GetGroupMessageListRespond{
+		MessageId:    message.Uuid,
+		SendId:       message.SendId,
+		SendName:     message.SendName,
+		SendAvatar:   message.SendAvatar,
+		ReceiveId:    message.ReceiveId,
+		Type:         message.Type,
+		Content:      message.Content,
+		Url:          message.Url,
+		FileSize:     message.FileSize,
+		Duration:     message.Duration,
+		Width:        message.Width,
+		Height:       message.Height,
+		ThumbnailUrl: message.ThumbnailUrl,
+		FileName:     message.FileName,
+		FileType:     message.FileType,
+		CreatedAt:    message.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	jsonMessage, err := json.Marshal(messageRsp)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	chat.SendMessageToUsers(receivers, &chat.MessageBack{
+		Message: jsonMessage,
+		Uuid:    message.Uuid,
+	})
 }
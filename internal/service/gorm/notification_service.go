@@ -0,0 +1,71 @@
+package gorm
+
+import (
+	"fmt"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/chat"
+	"kama_chat_server/pkg/enum/notification/notification_status_enum"
+	"kama_chat_server/pkg/util/random"
+	"kama_chat_server/pkg/zlog"
+	"time"
+)
+
+type notificationService struct {
+	notificationDao dao.NotificationDAO
+	userDao         dao.UserDAO
+	groupDao        dao.GroupDAO
+}
+
+var NotificationService *notificationService
+
+func InitNotificationService(notificationDao dao.NotificationDAO, userDao dao.UserDAO, groupDao dao.GroupDAO) {
+	NotificationService = &notificationService{
+		notificationDao: notificationDao,
+		userDao:         userDao,
+		groupDao:        groupDao,
+	}
+}
+
+// Notify 给用户发送通知
+// 通知先落库为未送达，在线则立即推送，离线则在下次ws登录时补发，送达后由client.Write置为已送达
+// targetId为相关群聊uuid，没有则传空
+// 业务本身已经成功，这里失败只记录日志，不影响调用方的返回
+func (n *notificationService) Notify(receiveId string, notifyType int8, fromId string, targetId string, content string) {
+	from, err := n.userDao.GetUserByUUID(fromId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	notification := model.Notification{
+		Uuid:       fmt.Sprintf("N%s", random.GetNowAndLenRandomString(11)),
+		UserId:     receiveId,
+		Type:       notifyType,
+		FromId:     from.Uuid,
+		FromName:   from.Nickname,
+		FromAvatar: from.Avatar,
+		TargetId:   targetId,
+		Content:    content,
+		Status:     notification_status_enum.UNSENT,
+		CreatedAt:  time.Now(),
+	}
+	if targetId != "" && targetId[0] == 'G' {
+		group, err := n.groupDao.GetGroupByUUID(targetId)
+		if err != nil {
+			zlog.Error(err.Error())
+			return
+		}
+		notification.TargetName = group.Name
+	}
+	if err := n.notificationDao.CreateNotification(&notification); err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+
+	messageBack, err := chat.NewNotificationMessageBack(&notification)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	chat.SendMessageToUsers([]string{receiveId}, messageBack)
+}
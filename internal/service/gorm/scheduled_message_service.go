@@ -0,0 +1,386 @@
+package gorm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/chat"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/contact/contact_status_enum"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/enum/notification/notification_type_enum"
+	"kama_chat_server/pkg/enum/scheduled_message/scheduled_message_status_enum"
+	"kama_chat_server/pkg/enum/user_info/user_status_enum"
+	"kama_chat_server/pkg/util/random"
+	"kama_chat_server/pkg/zlog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type scheduledMessageService struct {
+	scheduledMessageDao dao.ScheduledMessageDAO
+	sessionDao          dao.SessionDAO
+	userDao             dao.UserDAO
+	userContactDao      dao.UserContactDAO
+	stop                chan struct{}
+	done                chan struct{} // 调度协程退出后关闭
+}
+
+var ScheduledMessageService *scheduledMessageService
+
+func InitScheduledMessageService(scheduledMessageDao dao.ScheduledMessageDAO, sessionDao dao.SessionDAO, userDao dao.UserDAO, userContactDao dao.UserContactDAO) {
+	ScheduledMessageService = &scheduledMessageService{
+		scheduledMessageDao: scheduledMessageDao,
+		sessionDao:          sessionDao,
+		userDao:             userDao,
+		userContactDao:      userContactDao,
+		stop:                make(chan struct{}),
+		done:                make(chan struct{}),
+	}
+}
+
+// parseSendAt 解析计划发送时间，必须晚于当前时间且不超过最大定时天数
+func parseSendAt(sendAt string) (time.Time, string, int) {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", sendAt, time.Local)
+	if err != nil {
+		return time.Time{}, "发送时间格式错误", -2
+	}
+	if !t.After(time.Now()) {
+		return time.Time{}, "发送时间必须晚于当前时间", -2
+	}
+	if t.After(time.Now().AddDate(0, 0, constants.SCHEDULED_MESSAGE_MAX_DAYS)) {
+		return time.Time{}, fmt.Sprintf("最多只能定时到%d天后", constants.SCHEDULED_MESSAGE_MAX_DAYS), -2
+	}
+	return t, "", 0
+}
+
+// checkSendAllowed 检查sendId当前是否可以向receiveId发送消息
+// 创建定时消息时检查一次，到期发送时再检查一次，期间可能已退群、被拉黑或被禁用
+func (s *scheduledMessageService) checkSendAllowed(sendId, receiveId string) (string, int) {
+	sender, err := s.userDao.GetUserByUUID(sendId)
+	if err != nil {
+		// 账号已被删除时不能再发送，到期的消息记为失败，不能一直等待重试
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "账号不存在", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if sender.Status == user_status_enum.DISABLE {
+		return "账号已被禁用", -2
+	}
+	contact, err := s.userContactDao.GetUserContact(sendId, receiveId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if receiveId[0] == 'G' {
+				return "已不在该群聊中", -2
+			}
+			return "对方不是你的联系人", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	switch contact.Status {
+	case contact_status_enum.NORMAL, contact_status_enum.BLACK, contact_status_enum.BE_BLACK:
+	case contact_status_enum.SILENCE:
+		return "已被禁言", -2
+	case contact_status_enum.QUIT_GROUP, contact_status_enum.KICK_OUT_GROUP:
+		return "已不在该群聊中", -2
+	default:
+		return "对方不是你的联系人", -2
+	}
+	// 拉黑关系和对方的禁用状态
+	if message, allowed, ret := SessionService.CheckOpenSessionAllowed(sendId, receiveId); !allowed {
+		return message, ret
+	}
+	return "", 0
+}
+
+// getOwnPendingMessage 获取属于ownerId且仍在等待发送的定时消息
+func (s *scheduledMessageService) getOwnPendingMessage(ownerId, scheduledMessageId string) (*model.ScheduledMessage, string, int) {
+	scheduledMessage, err := s.scheduledMessageDao.GetScheduledMessageByUUID(scheduledMessageId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "定时消息不存在", -2
+		}
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if scheduledMessage.SendId != ownerId {
+		return nil, "定时消息不存在", -2
+	}
+	if scheduledMessage.Status != scheduled_message_status_enum.PENDING {
+		return nil, "定时消息已发送或已取消", -2
+	}
+	return scheduledMessage, "", 0
+}
+
+// ScheduleMessage 创建定时消息
+func (s *scheduledMessageService) ScheduleMessage(req request.ScheduleMessageRequest) (string, string, int) {
+	switch req.Type {
+	case message_type_enum.Text:
+		if strings.TrimSpace(req.Content) == "" {
+			return "消息内容不能为空", "", -2
+		}
+	case message_type_enum.File:
+		if req.Url == "" {
+			return "请先上传文件", "", -2
+		}
+	default:
+		return "定时消息只支持文本和文件", "", -2
+	}
+	sendAt, message, ret := parseSendAt(req.SendAt)
+	if ret != 0 {
+		return message, "", ret
+	}
+	session, err := s.sessionDao.GetSessionByUUID(req.SessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "会话不存在", "", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	if session.SendId != req.OwnerId || session.ReceiveId != req.ReceiveId {
+		return "会话不存在", "", -2
+	}
+	if message, ret := s.checkSendAllowed(req.OwnerId, req.ReceiveId); ret != 0 {
+		return message, "", ret
+	}
+	count, err := s.scheduledMessageDao.CountPendingScheduledMessages(req.OwnerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	if count >= constants.SCHEDULED_MESSAGE_MAX_PENDING {
+		return fmt.Sprintf("最多只能有%d条等待发送的定时消息", constants.SCHEDULED_MESSAGE_MAX_PENDING), "", -2
+	}
+
+	scheduledMessage := model.ScheduledMessage{
+		Uuid:      fmt.Sprintf("T%s", random.GetNowAndLenRandomString(11)),
+		SessionId: req.SessionId,
+		SendId:    req.OwnerId,
+		ReceiveId: req.ReceiveId,
+		Type:      req.Type,
+		Content:   req.Content,
+		Url:       req.Url,
+		FileType:  req.FileType,
+		FileName:  req.FileName,
+		FileSize:  req.FileSize,
+		SendAt:    sendAt,
+		Status:    scheduled_message_status_enum.PENDING,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.scheduledMessageDao.CreateScheduledMessage(&scheduledMessage); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, "", -1
+	}
+	return "定时消息创建成功", scheduledMessage.Uuid, 0
+}
+
+// GetScheduledMessageList 获取等待发送的定时消息，按发送时间排序
+func (s *scheduledMessageService) GetScheduledMessageList(ownerId string) (string, []respond.ScheduledMessageRespond, int) {
+	scheduledMessages, err := s.scheduledMessageDao.GetPendingScheduledMessages(ownerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	var rsp []respond.ScheduledMessageRespond
+	for _, scheduledMessage := range scheduledMessages {
+		rsp = append(rsp, respond.ScheduledMessageRespond{
+			ScheduledMessageId: scheduledMessage.Uuid,
+			SessionId:          scheduledMessage.SessionId,
+			ReceiveId:          scheduledMessage.ReceiveId,
+			Type:               scheduledMessage.Type,
+			Content:            scheduledMessage.Content,
+			Url:                scheduledMessage.Url,
+			FileType:           scheduledMessage.FileType,
+			FileName:           scheduledMessage.FileName,
+			FileSize:           scheduledMessage.FileSize,
+			SendAt:             scheduledMessage.SendAt.Format("2006-01-02 15:04:05"),
+			CreatedAt:          scheduledMessage.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return "获取定时消息成功", rsp, 0
+}
+
+// UpdateScheduledMessage 修改等待发送的定时消息的内容或发送时间
+func (s *scheduledMessageService) UpdateScheduledMessage(req request.UpdateScheduledMessageRequest) (string, int) {
+	scheduledMessage, message, ret := s.getOwnPendingMessage(req.OwnerId, req.ScheduledMessageId)
+	if ret != 0 {
+		return message, ret
+	}
+	updates := map[string]interface{}{
+		"updated_at": time.Now(),
+	}
+	if req.Content != "" {
+		if scheduledMessage.Type != message_type_enum.Text {
+			return "只能修改文本消息的内容", -2
+		}
+		if strings.TrimSpace(req.Content) == "" {
+			return "消息内容不能为空", -2
+		}
+		updates["content"] = req.Content
+	}
+	if req.SendAt != "" {
+		sendAt, message, ret := parseSendAt(req.SendAt)
+		if ret != 0 {
+			return message, ret
+		}
+		updates["send_at"] = sendAt
+	}
+	updated, err := s.scheduledMessageDao.UpdatePendingScheduledMessage(req.ScheduledMessageId, updates)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if !updated {
+		return "定时消息已发送或已取消", -2
+	}
+	return "修改定时消息成功", 0
+}
+
+// CancelScheduledMessage 取消等待发送的定时消息
+func (s *scheduledMessageService) CancelScheduledMessage(ownerId, scheduledMessageId string) (string, int) {
+	if _, message, ret := s.getOwnPendingMessage(ownerId, scheduledMessageId); ret != 0 {
+		return message, ret
+	}
+	canceled, err := s.scheduledMessageDao.UpdatePendingScheduledMessage(scheduledMessageId, map[string]interface{}{
+		"status":     scheduled_message_status_enum.CANCELED,
+		"updated_at": time.Now(),
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if !canceled {
+		return "定时消息已发送或已取消", -2
+	}
+	return "已取消定时消息", 0
+}
+
+// Start 启动定时消息调度，由主进程用协程起
+// 定时消息以数据库为准，每次扫描所有已到期的消息，服务停机期间到期的消息会在重启后立即发送
+func (s *scheduledMessageService) Start() {
+	defer close(s.done)
+	ticker := time.NewTicker(time.Second * constants.SCHEDULED_MESSAGE_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.sendDueMessages()
+		}
+	}
+}
+
+// Close 停止定时消息调度，等待正在投递的消息完成，之后才能关闭聊天服务
+func (s *scheduledMessageService) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+// sendDueMessages 发送所有已到期的定时消息
+// 按发送时间分页往后取，系统错误时留在等待发送的消息下次扫描再重试，不会在本次扫描中被反复取到
+func (s *scheduledMessageService) sendDueMessages() {
+	now := time.Now()
+	var after *model.ScheduledMessage
+	for {
+		scheduledMessages, err := s.scheduledMessageDao.GetDueScheduledMessages(now, after, constants.SCHEDULED_MESSAGE_BATCH)
+		if err != nil {
+			zlog.Error(err.Error())
+			return
+		}
+		for i := range scheduledMessages {
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
+			s.sendScheduledMessage(&scheduledMessages[i])
+		}
+		if len(scheduledMessages) < constants.SCHEDULED_MESSAGE_BATCH {
+			return
+		}
+		after = &scheduledMessages[len(scheduledMessages)-1]
+	}
+}
+
+// sendScheduledMessage 重新校验权限后，以发送者的身份将消息投递到正常的转发链路
+func (s *scheduledMessageService) sendScheduledMessage(scheduledMessage *model.ScheduledMessage) {
+	if message, ret := s.checkSendAllowed(scheduledMessage.SendId, scheduledMessage.ReceiveId); ret != 0 {
+		if ret == -1 {
+			// 系统错误，保持等待发送，下次扫描重试
+			return
+		}
+		failed, err := s.scheduledMessageDao.UpdatePendingScheduledMessage(scheduledMessage.Uuid, map[string]interface{}{
+			"status":      scheduled_message_status_enum.FAILED,
+			"fail_reason": message,
+			"updated_at":  time.Now(),
+		})
+		if err != nil {
+			zlog.Error(err.Error())
+			return
+		}
+		if failed {
+			targetId := ""
+			if scheduledMessage.ReceiveId[0] == 'G' {
+				targetId = scheduledMessage.ReceiveId
+			}
+			NotificationService.Notify(scheduledMessage.SendId, notification_type_enum.SCHEDULED_MESSAGE_FAILED, scheduledMessage.SendId, targetId, message)
+		}
+		return
+	}
+	sender, err := s.userDao.GetUserByUUID(scheduledMessage.SendId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	// 先抢占为已发送，保证同一条消息只发送一次，与用户的编辑、取消互斥
+	claimed, err := s.scheduledMessageDao.ClaimDueScheduledMessage(scheduledMessage.Uuid, time.Now())
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if !claimed {
+		return
+	}
+	// 扫描之后、抢占之前用户可能修改过内容，以抢占后的数据为准
+	scheduledMessage, err = s.scheduledMessageDao.GetScheduledMessageByUUID(scheduledMessage.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	jsonMessage, err := json.Marshal(request.ChatMessageRequest{
+		SessionId:  scheduledMessage.SessionId,
+		Type:       scheduledMessage.Type,
+		Content:    scheduledMessage.Content,
+		Url:        scheduledMessage.Url,
+		SendId:     sender.Uuid,
+		SendName:   sender.Nickname,
+		SendAvatar: sender.Avatar,
+		ReceiveId:  scheduledMessage.ReceiveId,
+		FileSize:   scheduledMessage.FileSize,
+		FileType:   scheduledMessage.FileType,
+		FileName:   scheduledMessage.FileName,
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	if err := chat.SubmitChatMessage(jsonMessage); err != nil {
+		zlog.Error(err.Error())
+		if err := s.scheduledMessageDao.UpdateScheduledMessageStatus(scheduledMessage.Uuid, scheduled_message_status_enum.FAILED, "消息投递失败"); err != nil {
+			zlog.Error(err.Error())
+		}
+		NotificationService.Notify(scheduledMessage.SendId, notification_type_enum.SCHEDULED_MESSAGE_FAILED, scheduledMessage.SendId, "", "消息投递失败")
+	}
+}
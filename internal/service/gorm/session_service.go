@@ -1,6 +1,7 @@
 package gorm
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"kama_chat_server/internal/dto/request"
 	"kama_chat_server/internal/dto/respond"
 	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/chat"
 	myredis "kama_chat_server/internal/service/redis"
 	"kama_chat_server/pkg/constants"
 	"kama_chat_server/pkg/enum/contact/contact_status_enum"
@@ -22,21 +24,38 @@ import (
 )
 
 type sessionService struct {
-	sessionDAO dao.SessionDAO
-	userDAO    dao.UserDAO
-	groupDAO   dao.GroupDAO
+	sessionDAO     dao.SessionDAO
+	userDAO        dao.UserDAO
+	groupDAO       dao.GroupDAO
+	userContactDAO dao.UserContactDAO
 }
 
 var SessionService *sessionService
 
-func InitSessionService(sessionDao dao.SessionDAO, userDao dao.UserDAO, groupDao dao.GroupDAO) {
+func InitSessionService(sessionDao dao.SessionDAO, userDao dao.UserDAO, groupDao dao.GroupDAO, userContactDao dao.UserContactDAO) {
 	SessionService = &sessionService{
-		sessionDAO: sessionDao,
-		userDAO:    userDao,
-		groupDAO:   groupDao,
+		sessionDAO:     sessionDao,
+		userDAO:        userDao,
+		groupDAO:       groupDao,
+		userContactDAO: userContactDao,
 	}
 }
 
+// getContactRemarks 获取ownerId对联系人的备注，key为联系人uuid
+func (s *sessionService) getContactRemarks(ownerId string) (map[string]string, error) {
+	contactList, err := s.userContactDAO.GetUserContacts(ownerId)
+	if err != nil {
+		return nil, err
+	}
+	remarks := make(map[string]string)
+	for _, contact := range contactList {
+		if contact.Remark != "" {
+			remarks[contact.ContactId] = contact.Remark
+		}
+	}
+	return remarks, nil
+}
+
 // CreateSession 创建会话
 func (s *sessionService) CreateSession(req request.CreateSessionRequest) (string, string, int) {
 	_, err := s.userDAO.GetUserByUUID(req.SendId)
@@ -59,6 +78,12 @@ func (s *sessionService) CreateSession(req request.CreateSessionRequest) (string
 			zlog.Error("该用户被禁用了")
 			return "该用户被禁用了", "", -2
 		} else {
+			isSelf, isContact, err := getViewerRelation(s.userDAO, req.SendId, req.ReceiveId)
+			if err != nil {
+				zlog.Error(err.Error())
+				return constants.SYSTEM_ERROR, "", -1
+			}
+			applyUserPrivacy(receiveUser, isSelf, isContact)
 			session.ReceiveName = receiveUser.Nickname
 			session.Avatar = receiveUser.Avatar
 		}
@@ -163,8 +188,30 @@ func (s *sessionService) OpenSession(req request.OpenSessionRequest) (string, st
 	return "会话创建成功", session.Uuid, 0
 }
 
-// GetUserSessionList 获取用户会话列表
-func (s *sessionService) GetUserSessionList(ownerId string) (string, []respond.UserSessionListRespond, int) {
+// GetUserSessionList 获取用户会话列表，不包含已归档的会话，listId不为空时只返回该分组中联系人的会话
+func (s *sessionService) GetUserSessionList(ownerId string, listId string) (string, []respond.UserSessionListRespond, int) {
+	memberSet, message, ret := ContactListService.GetMemberSet(ownerId, listId)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	message, sessionList, ret := s.getUserSessionList(ownerId)
+	if ret != 0 {
+		return message, sessionList, ret
+	}
+	var rsp []respond.UserSessionListRespond
+	for _, session := range sessionList {
+		if session.IsArchived {
+			continue
+		}
+		if memberSet == nil || memberSet[session.UserId] {
+			rsp = append(rsp, session)
+		}
+	}
+	return message, rsp, ret
+}
+
+// getUserSessionList 获取全部用户会话，有备注的联系人以备注名展示
+func (s *sessionService) getUserSessionList(ownerId string) (string, []respond.UserSessionListRespond, int) {
 	rspString, err := myredis.GetKeyNilIsErr("session_list_" + ownerId)
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
@@ -177,14 +224,33 @@ func (s *sessionService) GetUserSessionList(ownerId string) (string, []respond.U
 				zlog.Info("未创建用户会话")
 				return "未创建用户会话", nil, 0
 			}
+			remarks, err := s.getContactRemarks(ownerId)
+			if err != nil {
+				zlog.Error(err.Error())
+				return constants.SYSTEM_ERROR, nil, -1
+			}
 			var sessionListRsp []respond.UserSessionListRespond
 			for _, session := range sessionList {
-				sessionListRsp = append(sessionListRsp, respond.UserSessionListRespond{
-					SessionId: session.Uuid,
-					Avatar:    session.Avatar,
-					UserId:    session.ReceiveId,
-					Username:  session.ReceiveName,
-				})
+				username := session.ReceiveName
+				if remark, ok := remarks[session.ReceiveId]; ok {
+					username = remark
+				}
+				sessionRsp := respond.UserSessionListRespond{
+					SessionId:  session.Uuid,
+					Avatar:     session.Avatar,
+					UserId:     session.ReceiveId,
+					Username:   username,
+					Remark:     remarks[session.ReceiveId],
+					IsPinned:   session.IsPinned == 1,
+					IsMuted:    session.IsMuted == 1,
+					IsArchived: session.IsArchived == 1,
+					Draft:      session.Draft,
+				}
+				if session.LastMessageAt.Valid {
+					sessionRsp.LastMessage = session.LastMessage
+					sessionRsp.LastMessageAt = session.LastMessageAt.Time.Format("2006-01-02 15:04:05")
+				}
+				sessionListRsp = append(sessionListRsp, sessionRsp)
 			}
 			rspString, err := json.Marshal(sessionListRsp)
 			if err != nil {
@@ -206,8 +272,30 @@ func (s *sessionService) GetUserSessionList(ownerId string) (string, []respond.U
 	return "获取成功", rsp, 0
 }
 
-// GetGroupSessionList 获取群聊会话列表
-func (s *sessionService) GetGroupSessionList(ownerId string) (string, []respond.GroupSessionListRespond, int) {
+// GetGroupSessionList 获取群聊会话列表，不包含已归档的会话，listId不为空时只返回该分组中群聊的会话
+func (s *sessionService) GetGroupSessionList(ownerId string, listId string) (string, []respond.GroupSessionListRespond, int) {
+	memberSet, message, ret := ContactListService.GetMemberSet(ownerId, listId)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	message, sessionList, ret := s.getGroupSessionList(ownerId)
+	if ret != 0 {
+		return message, sessionList, ret
+	}
+	var rsp []respond.GroupSessionListRespond
+	for _, session := range sessionList {
+		if session.IsArchived {
+			continue
+		}
+		if memberSet == nil || memberSet[session.GroupId] {
+			rsp = append(rsp, session)
+		}
+	}
+	return message, rsp, ret
+}
+
+// getGroupSessionList 获取全部群聊会话，有备注的群聊以备注名展示
+func (s *sessionService) getGroupSessionList(ownerId string) (string, []respond.GroupSessionListRespond, int) {
 	rspString, err := myredis.GetKeyNilIsErr("group_session_list_" + ownerId)
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
@@ -222,15 +310,34 @@ func (s *sessionService) GetGroupSessionList(ownerId string) (string, []respond.
 				return "未创建群聊会话", nil, 0
 			}
 
+			remarks, err := s.getContactRemarks(ownerId)
+			if err != nil {
+				zlog.Error(err.Error())
+				return constants.SYSTEM_ERROR, nil, -1
+			}
 			var sessionListRsp []respond.GroupSessionListRespond
 			// 不需要在内存中 if sessionList[i].ReceiveId[0] == 'G'
 			for _, session := range sessionList {
-				sessionListRsp = append(sessionListRsp, respond.GroupSessionListRespond{
-					SessionId: session.Uuid,
-					Avatar:    session.Avatar,
-					GroupId:   session.ReceiveId,
-					GroupName: session.ReceiveName,
-				})
+				groupName := session.ReceiveName
+				if remark, ok := remarks[session.ReceiveId]; ok {
+					groupName = remark
+				}
+				sessionRsp := respond.GroupSessionListRespond{
+					SessionId:  session.Uuid,
+					Avatar:     session.Avatar,
+					GroupId:    session.ReceiveId,
+					GroupName:  groupName,
+					Remark:     remarks[session.ReceiveId],
+					IsPinned:   session.IsPinned == 1,
+					IsMuted:    session.IsMuted == 1,
+					IsArchived: session.IsArchived == 1,
+					Draft:      session.Draft,
+				}
+				if session.LastMessageAt.Valid {
+					sessionRsp.LastMessage = session.LastMessage
+					sessionRsp.LastMessageAt = session.LastMessageAt.Time.Format("2006-01-02 15:04:05")
+				}
+				sessionListRsp = append(sessionListRsp, sessionRsp)
 			}
 			rspString, err := json.Marshal(sessionListRsp)
 			if err != nil {
@@ -269,6 +376,11 @@ func (s *sessionService) DeleteSession(ownerId, sessionId string) (string, int)
 	}
 
 	// 缓存清理
+	if session.IsMuted == 1 {
+		if err := myredis.DelKeys(chat.MutedCacheKey(session.ReceiveId)); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
 	if err := myredis.DelKeysWithPattern("group_session_list_" + ownerId); err != nil {
 		zlog.Error(err.Error())
 	}
@@ -277,3 +389,126 @@ func (s *sessionService) DeleteSession(ownerId, sessionId string) (string, int)
 	}
 	return "删除成功", 0
 }
+
+// GetArchivedSessionList 获取已归档的用户会话和群聊会话
+func (s *sessionService) GetArchivedSessionList(ownerId string) (string, *respond.ArchivedSessionListRespond, int) {
+	message, userSessionList, ret := s.getUserSessionList(ownerId)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	message, groupSessionList, ret := s.getGroupSessionList(ownerId)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	rsp := &respond.ArchivedSessionListRespond{}
+	for _, session := range userSessionList {
+		if session.IsArchived {
+			rsp.UserSessions = append(rsp.UserSessions, session)
+		}
+	}
+	for _, session := range groupSessionList {
+		if session.IsArchived {
+			rsp.GroupSessions = append(rsp.GroupSessions, session)
+		}
+	}
+	return "获取成功", rsp, 0
+}
+
+// updateSessionFlag 修改ownerId自己的会话（置顶、免打扰、归档、草稿），并清除会话列表缓存
+// 只更新updates中的列，后台更新最新消息、清除草稿与这里互不覆盖
+func (s *sessionService) updateSessionFlag(ownerId, sessionId string, updates map[string]interface{}) (string, int) {
+	session, err := s.sessionDAO.GetSessionByUUID(sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "会话不存在", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if session.SendId != ownerId {
+		return "会话不存在", -2
+	}
+	if err := s.sessionDAO.UpdateSessionColumns(session.Uuid, updates); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if _, ok := updates["is_muted"]; ok {
+		if err := myredis.DelKeys(chat.MutedCacheKey(session.ReceiveId)); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	if err := myredis.DelKeysWithPattern("group_session_list_" + ownerId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.DelKeysWithPattern("session_list_" + ownerId); err != nil {
+		zlog.Error(err.Error())
+	}
+	return "", 0
+}
+
+// boolToFlag 将开关转换为会话表中的0/1
+func boolToFlag(enable bool) int8 {
+	if enable {
+		return 1
+	}
+	return 0
+}
+
+// SetSessionPinned 置顶/取消置顶会话
+func (s *sessionService) SetSessionPinned(req request.SetSessionFlagRequest) (string, int) {
+	message, ret := s.updateSessionFlag(req.OwnerId, req.SessionId, map[string]interface{}{
+		"is_pinned": boolToFlag(req.Enable),
+	})
+	if ret != 0 {
+		return message, ret
+	}
+	if req.Enable {
+		return "已置顶会话", 0
+	}
+	return "已取消置顶", 0
+}
+
+// SetSessionMuted 开启/关闭会话免打扰
+// 免打扰的会话仍然正常推送消息以更新角标，由前端根据is_muted决定是否弹出提醒
+func (s *sessionService) SetSessionMuted(req request.SetSessionFlagRequest) (string, int) {
+	message, ret := s.updateSessionFlag(req.OwnerId, req.SessionId, map[string]interface{}{
+		"is_muted": boolToFlag(req.Enable),
+	})
+	if ret != 0 {
+		return message, ret
+	}
+	if req.Enable {
+		return "已开启免打扰", 0
+	}
+	return "已关闭免打扰", 0
+}
+
+// SetSessionArchived 归档/取消归档会话，归档的会话不出现在会话列表中
+func (s *sessionService) SetSessionArchived(req request.SetSessionFlagRequest) (string, int) {
+	message, ret := s.updateSessionFlag(req.OwnerId, req.SessionId, map[string]interface{}{
+		"is_archived": boolToFlag(req.Enable),
+	})
+	if ret != 0 {
+		return message, ret
+	}
+	if req.Enable {
+		return "已归档会话", 0
+	}
+	return "已取消归档", 0
+}
+
+// SaveDraft 保存会话草稿，草稿为空表示清除
+// 草稿随会话列表返回，用户在其他设备上拉取会话列表即可看到；在该会话发送消息后草稿自动清除
+func (s *sessionService) SaveDraft(req request.SaveDraftRequest) (string, int) {
+	if len([]rune(req.Draft)) > constants.DRAFT_MAX_LEN {
+		return fmt.Sprintf("草稿不能超过%d个字符", constants.DRAFT_MAX_LEN), -2
+	}
+	message, ret := s.updateSessionFlag(req.OwnerId, req.SessionId, map[string]interface{}{
+		"draft":    req.Draft,
+		"draft_at": sql.NullTime{Time: time.Now(), Valid: req.Draft != ""},
+	})
+	if ret != 0 {
+		return message, ret
+	}
+	return "保存草稿成功", 0
+}
@@ -0,0 +1,351 @@
+package gorm
+
+import (
+	"errors"
+	"fmt"
+	"kama_chat_server/internal/config"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/contact/contact_status_enum"
+	"kama_chat_server/pkg/zlog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type storageQuotaService struct {
+	fileUsageDao   dao.FileUsageDAO
+	uploadDao      dao.UploadDAO
+	userDao        dao.UserDAO
+	groupDao       dao.GroupDAO
+	userContactDao dao.UserContactDAO
+}
+
+var StorageQuotaService *storageQuotaService
+
+func InitStorageQuotaService(fileUsageDao dao.FileUsageDAO, uploadDao dao.UploadDAO, userDao dao.UserDAO, groupDao dao.GroupDAO, userContactDao dao.UserContactDAO) {
+	StorageQuotaService = &storageQuotaService{
+		fileUsageDao:   fileUsageDao,
+		uploadDao:      uploadDao,
+		userDao:        userDao,
+		groupDao:       groupDao,
+		userContactDao: userContactDao,
+	}
+}
+
+// effectiveQuota 单独设置的配额优先，否则使用配置的默认配额（MB），返回0表示不限制
+func effectiveQuota(quota, defaultMB int64) int64 {
+	if quota > 0 {
+		return quota
+	}
+	return defaultMB * 1024 * 1024
+}
+
+// quotaGroupId 文件发送到群聊时占用群聊配额，单聊返回空
+func quotaGroupId(receiveId string) string {
+	if receiveId != "" && receiveId[0] == 'G' {
+		return receiveId
+	}
+	return ""
+}
+
+// formatSize 配额提示中展示的大小
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024*1024:
+		return fmt.Sprintf("%.1fGB", float64(size)/1024/1024/1024)
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/1024/1024)
+	case size >= 1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// getUserUsed 已上传的文件加上未完成的分片上传
+func (s *storageQuotaService) getUserUsed(ownerId string) (int64, int64, error) {
+	used, err := s.fileUsageDao.GetUserUsed(ownerId)
+	if err != nil {
+		return 0, 0, err
+	}
+	uploading, err := s.uploadDao.GetUploadingSize(ownerId)
+	if err != nil {
+		return 0, 0, err
+	}
+	return used + uploading, uploading, nil
+}
+
+func (s *storageQuotaService) getGroupUsed(groupId string) (int64, int64, error) {
+	used, err := s.fileUsageDao.GetGroupUsed(groupId)
+	if err != nil {
+		return 0, 0, err
+	}
+	uploading, err := s.uploadDao.GetGroupUploadingSize(groupId)
+	if err != nil {
+		return 0, 0, err
+	}
+	return used + uploading, uploading, nil
+}
+
+// getMemberGroup 获取ownerId所在的群聊
+func (s *storageQuotaService) getMemberGroup(ownerId, groupId string) (*model.GroupInfo, string, int) {
+	contact, err := s.userContactDao.GetUserContact(ownerId, groupId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "不是该群成员", -2
+		}
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if contact.Status != contact_status_enum.NORMAL {
+		return nil, "不是该群成员", -2
+	}
+	group, err := s.groupDao.GetGroupByUUID(groupId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "群聊不存在", -2
+		}
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	return group, "", 0
+}
+
+// CheckQuota 上传前检查用户配额，文件发送到群聊时同时检查群聊配额
+func (s *storageQuotaService) CheckQuota(ownerId, groupId string, size int64) (string, int) {
+	if ownerId == "" {
+		return "请先登录", -2
+	}
+	user, err := s.userDao.GetUserByUUID(ownerId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "用户不存在", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	conf := config.GetConfig().QuotaConfig
+	if quota := effectiveQuota(user.StorageQuota, conf.UserStorageQuota); quota > 0 {
+		used, _, err := s.getUserUsed(ownerId)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		if used+size > quota {
+			return fmt.Sprintf("存储空间不足，已使用%s，配额%s", formatSize(used), formatSize(quota)), -2
+		}
+	}
+	if groupId == "" {
+		return "", 0
+	}
+	group, message, ret := s.getMemberGroup(ownerId, groupId)
+	if ret != 0 {
+		return message, ret
+	}
+	if quota := effectiveQuota(group.StorageQuota, conf.GroupStorageQuota); quota > 0 {
+		used, _, err := s.getGroupUsed(groupId)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		if used+size > quota {
+			return fmt.Sprintf("群聊存储空间不足，已使用%s，配额%s", formatSize(used), formatSize(quota)), -2
+		}
+	}
+	return "", 0
+}
+
+// RecordUsage 上传成功后记录占用，文件已经写入，这里失败只记录日志
+func (s *storageQuotaService) RecordUsage(ownerId, groupId, fileKey string, size int64, fileType int8) {
+	if err := s.fileUsageDao.CreateFileUsage(&model.FileUsage{
+		OwnerId:   ownerId,
+		GroupId:   groupId,
+		FileKey:   fileKey,
+		Size:      size,
+		Type:      fileType,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// toTypeUsage 转换按文件用途汇总的占用
+func toTypeUsage(stats []dao.UsageStat) []respond.StorageTypeUsageRespond {
+	types := []respond.StorageTypeUsageRespond{}
+	for _, stat := range stats {
+		types = append(types, respond.StorageTypeUsageRespond{Type: stat.Type, Used: stat.Used})
+	}
+	return types
+}
+
+// GetStorageUsage 查看自己或所在群聊的存储占用明细
+func (s *storageQuotaService) GetStorageUsage(req request.GetStorageUsageRequest) (string, *respond.StorageUsageRespond, int) {
+	conf := config.GetConfig().QuotaConfig
+	if req.GroupId != "" {
+		group, message, ret := s.getMemberGroup(req.OwnerId, req.GroupId)
+		if ret != 0 {
+			return message, nil, ret
+		}
+		used, uploading, err := s.getGroupUsed(group.Uuid)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		stats, err := s.fileUsageDao.GetGroupUsageByType(group.Uuid)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		return "获取存储占用成功", &respond.StorageUsageRespond{
+			Used:      used,
+			Uploading: uploading,
+			Quota:     effectiveQuota(group.StorageQuota, conf.GroupStorageQuota),
+			Types:     toTypeUsage(stats),
+		}, 0
+	}
+
+	user, err := s.userDao.GetUserByUUID(req.OwnerId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "用户不存在", nil, -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	used, uploading, err := s.getUserUsed(user.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	stats, err := s.fileUsageDao.GetUserUsageByType(user.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	groupStats, err := s.fileUsageDao.GetUserUsageByGroup(user.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rsp := &respond.StorageUsageRespond{
+		Used:      used,
+		Uploading: uploading,
+		Quota:     effectiveQuota(user.StorageQuota, conf.UserStorageQuota),
+		Types:     toTypeUsage(stats),
+		Groups:    []respond.GroupStorageUsageRespond{},
+	}
+	for _, stat := range groupStats {
+		groupRsp := respond.GroupStorageUsageRespond{GroupId: stat.Id, Used: stat.Used}
+		if group, err := s.groupDao.GetGroupByUUID(stat.Id); err == nil {
+			groupRsp.GroupName = group.Name
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			zlog.Error(err.Error())
+		}
+		rsp.Groups = append(rsp.Groups, groupRsp)
+	}
+	return "获取存储占用成功", rsp, 0
+}
+
+// checkAdmin 只有管理员可以调整配额和查看全站占用
+func (s *storageQuotaService) checkAdmin(ownerId string) (string, int) {
+	user, err := s.userDao.GetUserByUUID(ownerId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "没有权限", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if user.IsAdmin != 1 {
+		return "没有权限", -2
+	}
+	return "", 0
+}
+
+// SetStorageQuota 管理员为用户或群聊设置配额
+func (s *storageQuotaService) SetStorageQuota(req request.SetStorageQuotaRequest) (string, int) {
+	if message, ret := s.checkAdmin(req.OwnerId); ret != 0 {
+		return message, ret
+	}
+	if req.Quota < 0 {
+		return "配额不能为负数", -2
+	}
+	var userIds, groupIds []string
+	for _, uuid := range req.UuidList {
+		switch {
+		case uuid != "" && uuid[0] == 'U':
+			userIds = append(userIds, uuid)
+		case uuid != "" && uuid[0] == 'G':
+			groupIds = append(groupIds, uuid)
+		default:
+			return "用户或群聊id不合法", -2
+		}
+	}
+	if len(userIds) > 0 {
+		if err := s.userDao.BatchSetStorageQuota(userIds, req.Quota); err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+	}
+	if len(groupIds) > 0 {
+		if err := s.groupDao.BatchSetStorageQuota(groupIds, req.Quota); err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+	}
+	return "设置配额成功", 0
+}
+
+// GetTopStorageConsumers 管理员查看存储占用最多的用户和群聊
+func (s *storageQuotaService) GetTopStorageConsumers(req request.GetTopStorageConsumersRequest) (string, *respond.TopStorageConsumersRespond, int) {
+	if message, ret := s.checkAdmin(req.OwnerId); ret != 0 {
+		return message, nil, ret
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = constants.STORAGE_TOP_LIMIT
+	}
+	if limit > constants.STORAGE_TOP_MAX_LIMIT {
+		limit = constants.STORAGE_TOP_MAX_LIMIT
+	}
+	userStats, err := s.fileUsageDao.GetTopUsers(limit)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	groupStats, err := s.fileUsageDao.GetTopGroups(limit)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+
+	conf := config.GetConfig().QuotaConfig
+	rsp := &respond.TopStorageConsumersRespond{
+		Users:  []respond.StorageConsumerRespond{},
+		Groups: []respond.StorageConsumerRespond{},
+	}
+	for _, stat := range userStats {
+		consumer := respond.StorageConsumerRespond{Uuid: stat.Id, Used: stat.Used, Quota: effectiveQuota(0, conf.UserStorageQuota)}
+		if user, err := s.userDao.GetUserByUUID(stat.Id); err == nil {
+			consumer.Name = user.Nickname
+			consumer.Quota = effectiveQuota(user.StorageQuota, conf.UserStorageQuota)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			zlog.Error(err.Error())
+		}
+		rsp.Users = append(rsp.Users, consumer)
+	}
+	for _, stat := range groupStats {
+		consumer := respond.StorageConsumerRespond{Uuid: stat.Id, Used: stat.Used, Quota: effectiveQuota(0, conf.GroupStorageQuota)}
+		if group, err := s.groupDao.GetGroupByUUID(stat.Id); err == nil {
+			consumer.Name = group.Name
+			consumer.Quota = effectiveQuota(group.StorageQuota, conf.GroupStorageQuota)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			zlog.Error(err.Error())
+		}
+		rsp.Groups = append(rsp.Groups, consumer)
+	}
+	return "获取存储占用排行成功", rsp, 0
+}
@@ -0,0 +1,613 @@
+package gorm
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kama_chat_server/internal/dao"
+	"kama_chat_server/internal/dto/request"
+	"kama_chat_server/internal/dto/respond"
+	"kama_chat_server/internal/model"
+	"kama_chat_server/internal/service/filecheck"
+	myredis "kama_chat_server/internal/service/redis"
+	"kama_chat_server/internal/service/storage"
+	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/message/message_type_enum"
+	"kama_chat_server/pkg/enum/upload/upload_status_enum"
+	"kama_chat_server/pkg/util/filename"
+	"kama_chat_server/pkg/util/random"
+	"kama_chat_server/pkg/zlog"
+	"math/big"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+type uploadService struct {
+	uploadDao   dao.UploadDAO
+	fileBlobDao dao.FileBlobDAO
+	stop        chan struct{}
+}
+
+var UploadService *uploadService
+
+func InitUploadService(uploadDao dao.UploadDAO, fileBlobDao dao.FileBlobDAO) {
+	UploadService = &uploadService{
+		uploadDao:   uploadDao,
+		fileBlobDao: fileBlobDao,
+		stop:        make(chan struct{}),
+	}
+}
+
+// checkSha256 检查是否为十六进制的sha256，返回小写形式
+func checkSha256(sum string) (string, bool) {
+	sum = strings.ToLower(strings.TrimSpace(sum))
+	if len(sum) != sha256.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		return "", false
+	}
+	return sum, true
+}
+
+// getOwnUpload 获取属于ownerId的上传任务
+func (u *uploadService) getOwnUpload(ownerId, uploadId string) (*model.Upload, string, int) {
+	upload, err := u.uploadDao.GetUploadByUUID(uploadId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "上传任务不存在", -2
+		}
+		zlog.Error(err.Error())
+		return nil, constants.SYSTEM_ERROR, -1
+	}
+	if upload.OwnerId != ownerId {
+		return nil, "上传任务不存在", -2
+	}
+	return upload, "", 0
+}
+
+// partSize 第partIndex个分片应有的大小，只有最后一片可以比分片大小小
+func partSize(upload *model.Upload, partIndex int) int64 {
+	if partIndex < upload.ChunkCount-1 {
+		return upload.ChunkSize
+	}
+	return upload.FileSize - upload.ChunkSize*int64(upload.ChunkCount-1)
+}
+
+// removeUploadParts 删除任务的所有分片和分片记录
+func (u *uploadService) removeUploadParts(upload *model.Upload) {
+	for i := 0; i < upload.ChunkCount; i++ {
+		if err := storage.Storage.Delete(storage.PartKey(upload.Uuid, i)); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+	if err := u.uploadDao.DeleteUploadParts(upload.Uuid); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// InitUpload 创建分片上传任务
+func (u *uploadService) InitUpload(req request.InitUploadRequest) (string, *respond.UploadStatusRespond, int) {
+	fileName := filename.Sanitize(req.FileName)
+	if err := filecheck.CheckName(filecheck.KindFile, fileName); err != nil {
+		message, ret := uploadCheckFailed(err)
+		return message, nil, ret
+	}
+	if req.FileSize <= 0 || req.FileSize > constants.UPLOAD_MAX_FILE_SIZE {
+		return fmt.Sprintf("文件大小需要在1B到%dGB之间", constants.UPLOAD_MAX_FILE_SIZE/1024/1024/1024), nil, -2
+	}
+	sum, ok := checkSha256(req.Sha256)
+	if !ok {
+		return "文件校验值不合法", nil, -2
+	}
+	groupId := quotaGroupId(req.ReceiveId)
+	if message, ret := StorageQuotaService.CheckQuota(req.OwnerId, groupId, req.FileSize); ret != 0 {
+		return message, nil, ret
+	}
+	chunkSize := req.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = constants.UPLOAD_CHUNK_SIZE
+	}
+	if chunkSize < constants.UPLOAD_MIN_CHUNK_SIZE || chunkSize > constants.UPLOAD_MAX_CHUNK_SIZE {
+		return fmt.Sprintf("分片大小需要在%dKB到%dMB之间", constants.UPLOAD_MIN_CHUNK_SIZE/1024, constants.UPLOAD_MAX_CHUNK_SIZE/1024/1024), nil, -2
+	}
+	upload := model.Upload{
+		Uuid:       fmt.Sprintf("P%s", random.GetNowAndLenRandomString(11)),
+		OwnerId:    req.OwnerId,
+		GroupId:    groupId,
+		FileName:   fileName,
+		FileSize:   req.FileSize,
+		ChunkSize:  chunkSize,
+		ChunkCount: int((req.FileSize + chunkSize - 1) / chunkSize),
+		Sha256:     sum,
+		Status:     upload_status_enum.UPLOADING,
+		ExpireAt:   time.Now().Add(time.Hour * constants.UPLOAD_EXPIRE_HOURS),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if err := u.uploadDao.CreateUpload(&upload); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	return "创建上传任务成功", &respond.UploadStatusRespond{
+		UploadId:      upload.Uuid,
+		FileName:      upload.FileName,
+		FileSize:      upload.FileSize,
+		ChunkSize:     upload.ChunkSize,
+		ChunkCount:    upload.ChunkCount,
+		Status:        upload.Status,
+		UploadedParts: []int{},
+	}, 0
+}
+
+// UploadPart 上传一个分片，表单字段为owner_id、upload_id、part_index、sha256和file
+// 分片可以乱序、并发上传，同一分片重复上传时覆盖
+func (u *uploadService) UploadPart(c *gin.Context) (string, int) {
+	if err := c.Request.ParseMultipartForm(constants.FILE_MAX_SIZE); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	upload, message, ret := u.getOwnUpload(c.Request.FormValue("owner_id"), c.Request.FormValue("upload_id"))
+	if ret != 0 {
+		return message, ret
+	}
+	if upload.Status != upload_status_enum.UPLOADING || time.Now().After(upload.ExpireAt) {
+		return "上传任务已结束", -2
+	}
+	partIndex, err := strconv.Atoi(c.Request.FormValue("part_index"))
+	if err != nil || partIndex < 0 || partIndex >= upload.ChunkCount {
+		return "分片序号不合法", -2
+	}
+	sum, ok := checkSha256(c.Request.FormValue("sha256"))
+	if !ok {
+		return "分片校验值不合法", -2
+	}
+	file, fileHeader, err := c.Request.FormFile("file")
+	if err != nil {
+		zlog.Error(err.Error())
+		return "请选择分片", -2
+	}
+	defer file.Close()
+	if fileHeader.Size != partSize(upload, partIndex) {
+		return "分片大小不正确", -2
+	}
+
+	partKey := storage.PartKey(upload.Uuid, partIndex)
+	hash := sha256.New()
+	if err := storage.Storage.Put(partKey, io.TeeReader(file, hash), fileHeader.Size, "application/octet-stream"); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != sum {
+		if err := storage.Storage.Delete(partKey); err != nil {
+			zlog.Error(err.Error())
+		}
+		return "分片校验失败，请重新上传该分片", -2
+	}
+	if err := u.uploadDao.SaveUploadPart(&model.UploadPart{
+		UploadId:  upload.Uuid,
+		PartIndex: partIndex,
+		Size:      fileHeader.Size,
+		Sha256:    sum,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	return "上传分片成功", 0
+}
+
+// GetUploadStatus 查询上传任务已收到的分片，用于断点续传
+func (u *uploadService) GetUploadStatus(req request.UploadIdRequest) (string, *respond.UploadStatusRespond, int) {
+	upload, message, ret := u.getOwnUpload(req.OwnerId, req.UploadId)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	parts, err := u.uploadDao.GetUploadParts(upload.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	rsp := &respond.UploadStatusRespond{
+		UploadId:      upload.Uuid,
+		FileName:      upload.FileName,
+		FileSize:      upload.FileSize,
+		ChunkSize:     upload.ChunkSize,
+		ChunkCount:    upload.ChunkCount,
+		Status:        upload.Status,
+		UploadedParts: []int{},
+	}
+	for _, part := range parts {
+		rsp.UploadedParts = append(rsp.UploadedParts, part.PartIndex)
+	}
+	return "获取上传状态成功", rsp, 0
+}
+
+// partsReader 按序号依次读取所有分片，读完一个分片再打开下一个
+type partsReader struct {
+	uploadId string
+	count    int
+	index    int
+	current  io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if r.index >= r.count {
+				return 0, io.EOF
+			}
+			file, _, err := storage.Storage.Open(storage.PartKey(r.uploadId, r.index))
+			if err != nil {
+				return 0, err
+			}
+			r.current = file
+			r.index++
+		}
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+// CompleteUpload 所有分片上传完后合并为一个文件，校验整个文件的sha256
+// 返回的url可以直接用于发送文件消息，重复调用返回同一个文件
+func (u *uploadService) CompleteUpload(req request.UploadIdRequest) (string, *respond.UploadFileRespond, int) {
+	upload, message, ret := u.getOwnUpload(req.OwnerId, req.UploadId)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	switch upload.Status {
+	case upload_status_enum.COMPLETED:
+		return "上传完成", &respond.UploadFileRespond{
+			FileName: filepath.Base(upload.FileKey),
+			Url:      storage.Storage.URL(upload.FileKey),
+		}, 0
+	case upload_status_enum.COMPLETING:
+		return "文件正在合并，请稍后", nil, -2
+	case upload_status_enum.UPLOADING:
+	default:
+		return "上传任务已结束", nil, -2
+	}
+	parts, err := u.uploadDao.GetUploadParts(upload.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if len(parts) != upload.ChunkCount {
+		return fmt.Sprintf("还有%d个分片未上传", upload.ChunkCount-len(parts)), nil, -2
+	}
+	if ok, err := u.uploadDao.UpdateUploadStatus(upload.Uuid, upload_status_enum.UPLOADING, map[string]interface{}{
+		"status":     upload_status_enum.COMPLETING,
+		"updated_at": time.Now(),
+	}); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	} else if !ok {
+		return "文件正在合并，请稍后", nil, -2
+	}
+
+	// 先读一遍分片：嗅探类型、扫描并校验整个文件，全部通过后再写入
+	// 文件在通过检查前不会出现在files下，按内容存储的文件也不会被错误的内容覆盖
+	ext := strings.ToLower(filepath.Ext(upload.FileName))
+	hash := sha256.New()
+	reader := &partsReader{uploadId: upload.Uuid, count: upload.ChunkCount}
+	contentType, err := filecheck.CheckStream(filecheck.KindFile, io.TeeReader(reader, hash), upload.FileSize)
+	if err != nil {
+		reader.Close()
+		var rejectErr *filecheck.RejectError
+		if !errors.As(err, &rejectErr) {
+			zlog.Error(err.Error())
+			u.resetCompleting(upload)
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		if rejectErr.Detail != "" {
+			zlog.Warn(fmt.Sprintf("上传的文件未通过检查: %s, %s", rejectErr.Reason, rejectErr.Detail))
+		}
+		u.failUpload(upload)
+		return rejectErr.Reason, nil, -2
+	}
+	// 超过扫描大小限制时扫描器不会读完，剩下的内容也要计入sha256
+	_, err = io.Copy(hash, reader)
+	reader.Close()
+	if err != nil {
+		zlog.Error(err.Error())
+		u.resetCompleting(upload)
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != upload.Sha256 {
+		u.failUpload(upload)
+		return "文件校验失败，请重新上传", nil, -2
+	}
+
+	// 相同内容的文件已存在时不再合并
+	fileKey, exists, err := existingBlobKey(u.fileBlobDao, upload.Sha256)
+	if err != nil {
+		zlog.Error(err.Error())
+		u.resetCompleting(upload)
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if !exists {
+		fileKey = storage.FileKey(upload.Sha256 + ext)
+		reader := &partsReader{uploadId: upload.Uuid, count: upload.ChunkCount}
+		defer reader.Close()
+		if err := storage.Storage.Put(fileKey, reader, upload.FileSize, contentType); err != nil {
+			zlog.Error(err.Error())
+			u.resetCompleting(upload)
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		if err := registerBlob(u.fileBlobDao, upload.Sha256, fileKey, upload.FileSize, contentType); err != nil {
+			zlog.Error(err.Error())
+			u.resetCompleting(upload)
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+	}
+	if _, err := u.uploadDao.UpdateUploadStatus(upload.Uuid, upload_status_enum.COMPLETING, map[string]interface{}{
+		"status":     upload_status_enum.COMPLETED,
+		"file_key":   fileKey,
+		"updated_at": time.Now(),
+	}); err != nil {
+		zlog.Error(err.Error())
+		u.resetCompleting(upload)
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	StorageQuotaService.RecordUsage(upload.OwnerId, upload.GroupId, fileKey, upload.FileSize, message_type_enum.File)
+	u.removeUploadParts(upload)
+	newFileName := path.Base(fileKey)
+	zlog.Info("完成分片上传: " + newFileName)
+	return "上传完成", &respond.UploadFileRespond{
+		FileName: newFileName,
+		Url:      storage.Storage.URL(fileKey),
+	}, 0
+}
+
+// failUpload 文件校验或检查未通过，任务结束并删除分片
+func (u *uploadService) failUpload(upload *model.Upload) {
+	if _, err := u.uploadDao.UpdateUploadStatus(upload.Uuid, upload_status_enum.COMPLETING, map[string]interface{}{
+		"status":     upload_status_enum.FAILED,
+		"updated_at": time.Now(),
+	}); err != nil {
+		zlog.Error(err.Error())
+	}
+	u.removeUploadParts(upload)
+}
+
+// resetCompleting 合并失败时回到上传中，客户端可以重试
+func (u *uploadService) resetCompleting(upload *model.Upload) {
+	if _, err := u.uploadDao.UpdateUploadStatus(upload.Uuid, upload_status_enum.COMPLETING, map[string]interface{}{
+		"status":     upload_status_enum.UPLOADING,
+		"updated_at": time.Now(),
+	}); err != nil {
+		zlog.Error(err.Error())
+	}
+}
+
+// quickUploadChallenge 秒传前下发的校验，只知道sha256和大小而没有文件内容的用户无法算出proof
+type quickUploadChallenge struct {
+	Size   int64  `json:"size"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Nonce  string `json:"nonce"`
+}
+
+// newQuickUploadChallenge 在文件范围内随机选择一段，无论文件是否存在都下发，不暴露文件是否存在
+func newQuickUploadChallenge(size int64) (*quickUploadChallenge, error) {
+	challenge := &quickUploadChallenge{Size: size, Length: size}
+	if challenge.Length > constants.QUICK_UPLOAD_PROOF_SIZE {
+		challenge.Length = constants.QUICK_UPLOAD_PROOF_SIZE
+	}
+	if size > challenge.Length {
+		offset, err := rand.Int(rand.Reader, big.NewInt(size-challenge.Length+1))
+		if err != nil {
+			return nil, err
+		}
+		challenge.Offset = offset.Int64()
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	challenge.Nonce = hex.EncodeToString(nonce)
+	return challenge, nil
+}
+
+// verifyQuickUploadProof 读取已存储文件的对应片段，检查proof是否正确
+func verifyQuickUploadProof(fileKey string, challenge *quickUploadChallenge, proof string) (bool, error) {
+	file, _, err := storage.Storage.Open(fileKey)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(challenge.Offset, io.SeekStart); err != nil {
+		return false, err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(challenge.Nonce))
+	if n, err := io.CopyN(hash, file, challenge.Length); err != nil {
+		return false, fmt.Errorf("读取文件%s片段失败，已读取%d字节: %w", fileKey, n, err)
+	}
+	expected := hex.EncodeToString(hash.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(proof))) == 1, nil
+}
+
+// QuickUpload 秒传，服务端已有相同sha256和大小的文件时直接返回文件地址，不需要再上传
+// 分两次请求：第一次不带proof，下发随机的文件片段和nonce；第二次带上用该片段计算的proof，通过后才返回文件地址
+// 每次下发的校验只能使用一次，校验失败与文件不存在的返回一致
+func (u *uploadService) QuickUpload(req request.QuickUploadRequest) (string, *respond.QuickUploadRespond, int) {
+	sum, ok := checkSha256(req.Sha256)
+	if !ok {
+		return "文件校验值不合法", nil, -2
+	}
+	if req.FileSize < 0 || req.FileSize > constants.UPLOAD_MAX_FILE_SIZE {
+		return "文件大小不合法", nil, -2
+	}
+	challengeKey := "quick_upload_challenge_" + req.OwnerId + "_" + sum
+	if req.Proof == "" {
+		challenge, err := newQuickUploadChallenge(req.FileSize)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		challengeBytes, err := json.Marshal(challenge)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		if err := myredis.SetKeyEx(challengeKey, string(challengeBytes), time.Minute*constants.QUICK_UPLOAD_CHALLENGE_TIMEOUT); err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		return "请计算文件片段的校验值", &respond.QuickUploadRespond{
+			Exists: false,
+			Offset: challenge.Offset,
+			Length: challenge.Length,
+			Nonce:  challenge.Nonce,
+		}, 0
+	}
+
+	challengeString, err := myredis.GetKeyNilIsErr(challengeKey)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "校验已过期，请重新秒传", nil, -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if err := myredis.DelKeyIfExists(challengeKey); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	var challenge quickUploadChallenge
+	if err := json.Unmarshal([]byte(challengeString), &challenge); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if challenge.Size != req.FileSize {
+		return "文件不存在，请上传", &respond.QuickUploadRespond{Exists: false}, 0
+	}
+
+	blob, err := u.fileBlobDao.GetBlobBySha256(sum)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "文件不存在，请上传", &respond.QuickUploadRespond{Exists: false}, 0
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if blob.Size != req.FileSize {
+		return "文件不存在，请上传", &respond.QuickUploadRespond{Exists: false}, 0
+	}
+	if ok, err := verifyQuickUploadProof(blob.FileKey, &challenge, req.Proof); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	} else if !ok {
+		return "文件不存在，请上传", &respond.QuickUploadRespond{Exists: false}, 0
+	}
+	// 秒传同样计入配额
+	groupId := quotaGroupId(req.ReceiveId)
+	if message, ret := StorageQuotaService.CheckQuota(req.OwnerId, groupId, blob.Size); ret != 0 {
+		return message, nil, ret
+	}
+	if ok, err := u.fileBlobDao.TouchBlob(blob.FileKey, time.Now()); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	} else if !ok {
+		return "文件不存在，请上传", &respond.QuickUploadRespond{Exists: false}, 0
+	}
+	StorageQuotaService.RecordUsage(req.OwnerId, groupId, blob.FileKey, blob.Size, message_type_enum.File)
+	return "秒传成功", &respond.QuickUploadRespond{
+		Exists:   true,
+		FileName: path.Base(blob.FileKey),
+		Url:      storage.Storage.URL(blob.FileKey),
+	}, 0
+}
+
+// AbortUpload 取消上传任务并删除已上传的分片
+func (u *uploadService) AbortUpload(req request.UploadIdRequest) (string, int) {
+	upload, message, ret := u.getOwnUpload(req.OwnerId, req.UploadId)
+	if ret != 0 {
+		return message, ret
+	}
+	ok, err := u.uploadDao.UpdateUploadStatus(upload.Uuid, upload_status_enum.UPLOADING, map[string]interface{}{
+		"status":     upload_status_enum.CANCELED,
+		"updated_at": time.Now(),
+	})
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if !ok {
+		return "上传任务已结束", -2
+	}
+	u.removeUploadParts(upload)
+	return "已取消上传", 0
+}
+
+// Start 定期清理过期未完成的上传任务和没有引用的文件，由主进程用协程起
+func (u *uploadService) Start() {
+	ticker := time.NewTicker(time.Minute * constants.UPLOAD_CLEAN_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-u.stop:
+			return
+		case <-ticker.C:
+			u.cleanExpiredUploads()
+			collectGarbageBlobs(u.fileBlobDao)
+		}
+	}
+}
+
+// Close 停止清理
+func (u *uploadService) Close() {
+	close(u.stop)
+}
+
+// cleanExpiredUploads 取消过期的上传任务并删除分片
+func (u *uploadService) cleanExpiredUploads() {
+	uploads, err := u.uploadDao.GetExpiredUploads(time.Now(), constants.UPLOAD_CLEAN_BATCH)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	for i := range uploads {
+		upload := &uploads[i]
+		ok, err := u.uploadDao.UpdateUploadStatus(upload.Uuid, upload.Status, map[string]interface{}{
+			"status":     upload_status_enum.CANCELED,
+			"updated_at": time.Now(),
+		})
+		if err != nil {
+			zlog.Error(err.Error())
+			continue
+		}
+		if ok {
+			u.removeUploadParts(upload)
+		}
+	}
+}
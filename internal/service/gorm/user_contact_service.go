@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -20,6 +21,8 @@ import (
 	"kama_chat_server/pkg/enum/contact/contact_type_enum"
 	"kama_chat_server/pkg/enum/contact_apply/contact_apply_status_enum"
 	"kama_chat_server/pkg/enum/group_info/group_status_enum"
+	"kama_chat_server/pkg/enum/notification/notification_type_enum"
+	"kama_chat_server/pkg/enum/user_info/add_me_policy_enum"
 	"kama_chat_server/pkg/enum/user_info/user_status_enum"
 	"kama_chat_server/pkg/util/random"
 	"kama_chat_server/pkg/zlog"
@@ -41,9 +44,28 @@ func InitUserContactService(userContactDao dao.UserContactDAO, userDao dao.UserD
 	}
 }
 
-// GetUserList 获取用户列表
+// GetUserList 获取用户列表，listId不为空时只返回该分组中的联系人
 // 关于用户被禁用的问题，这里查到的是所有联系人，如果被禁用或被拉黑会以弹窗的形式提醒，无法打开会话框；如果被删除，是搜索不到该联系人的。
-func (u *userContactService) GetUserList(ownerId string) (string, []respond.MyUserListRespond, int) {
+func (u *userContactService) GetUserList(ownerId string, listId string) (string, []respond.MyUserListRespond, int) {
+	memberSet, message, ret := ContactListService.GetMemberSet(ownerId, listId)
+	if ret != 0 {
+		return message, nil, ret
+	}
+	message, userList, ret := u.getUserList(ownerId)
+	if ret != 0 || memberSet == nil {
+		return message, userList, ret
+	}
+	var rsp []respond.MyUserListRespond
+	for _, user := range userList {
+		if memberSet[user.UserId] {
+			rsp = append(rsp, user)
+		}
+	}
+	return message, rsp, ret
+}
+
+// getUserList 获取全部联系人，有备注的联系人以备注名展示
+func (u *userContactService) getUserList(ownerId string) (string, []respond.MyUserListRespond, int) {
 	rspString, err := myredis.GetKeyNilIsErr("contact_user_list_" + ownerId)
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
@@ -65,10 +87,16 @@ func (u *userContactService) GetUserList(ownerId string) (string, []respond.MyUs
 						zlog.Error(err.Error())
 						return constants.SYSTEM_ERROR, nil, -1
 					}
+					userName := user.Nickname
+					if contact.Remark != "" {
+						userName = contact.Remark
+					}
+					applyUserPrivacy(user, false, contact.Status == contact_status_enum.NORMAL)
 					userListRsp = append(userListRsp, respond.MyUserListRespond{
 						UserId:   user.Uuid,
-						UserName: user.Nickname,
+						UserName: userName,
 						Avatar:   user.Avatar,
+						Remark:   contact.Remark,
 					})
 				}
 			}
@@ -147,8 +175,9 @@ func (u *userContactService) LoadMyJoinedGroup(ownerId string) (string, []respon
 
 // GetContactInfo 获取联系人信息
 // 调用这个接口的前提是该联系人没有处在删除或被删除，或者该用户还在群聊中
+// 用户信息会按照对方的隐私设置，隐藏ownerId无权看到的部分
 // redis todo
-func (u *userContactService) GetContactInfo(contactId string) (string, respond.GetContactInfoRespond, int) {
+func (u *userContactService) GetContactInfo(ownerId string, contactId string) (string, respond.GetContactInfoRespond, int) {
 	if contactId[0] == 'G' {
 		group, err := u.groupDao.GetGroupByUUID(contactId)
 		if err != nil {
@@ -165,6 +194,7 @@ func (u *userContactService) GetContactInfo(contactId string) (string, respond.G
 				ContactMembers:   group.Members,
 				ContactMemberCnt: group.MemberCnt,
 				ContactOwnerId:   group.OwnerId,
+				MessageTtl:       group.MessageTtl,
 			}, 0
 		}
 		zlog.Error("该群聊处于禁用状态")
@@ -178,7 +208,13 @@ func (u *userContactService) GetContactInfo(contactId string) (string, respond.G
 	}
 	log.Println(user)
 	if user.Status != user_status_enum.DISABLE {
-		return "获取联系人信息成功", respond.GetContactInfoRespond{
+		isSelf, isContact, err := getViewerRelation(u.userDao, ownerId, contactId)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, respond.GetContactInfoRespond{}, -1
+		}
+		applyUserPrivacy(user, isSelf, isContact)
+		rsp := respond.GetContactInfoRespond{
 			ContactId:        user.Uuid,
 			ContactName:      user.Nickname,
 			ContactAvatar:    user.Avatar,
@@ -187,7 +223,16 @@ func (u *userContactService) GetContactInfo(contactId string) (string, respond.G
 			ContactPhone:     user.Telephone,
 			ContactGender:    user.Gender,
 			ContactSignature: user.Signature,
-		}, 0
+		}
+		if user.LastOfflineAt.Valid {
+			rsp.ContactLastSeen = user.LastOfflineAt.Time.Format("2006-01-02 15:04:05")
+		}
+		if contact, err := u.userContactDao.GetUserContact(ownerId, contactId); err == nil {
+			rsp.MessageTtl = contact.MessageTtl
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			zlog.Error(err.Error())
+		}
+		return "获取联系人信息成功", rsp, 0
 	}
 	zlog.Info("该用户处于禁用状态")
 	return "该用户处于禁用状态", respond.GetContactInfoRespond{}, -2
@@ -203,6 +248,7 @@ func (u *userContactService) DeleteContact(ownerId, contactId string) (string, i
 	if err := myredis.DelKeysWithPattern("contact_user_list_" + ownerId); err != nil {
 		zlog.Error(err.Error())
 	}
+	delRecommendListCache(ownerId, contactId)
 	return "删除联系人成功", 0
 }
 
@@ -222,6 +268,9 @@ func (u *userContactService) ApplyContact(req request.ApplyContactRequest) (stri
 			zlog.Info("用户已被禁用")
 			return "用户已被禁用", -2
 		}
+		if message, ret := checkAddMeAllowed(u.userContactDao, req.OwnerId, user); ret != 0 {
+			return message, ret
+		}
 		contactApply, err := u.userContactDao.GetContactApply(req.OwnerId, req.ContactId)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -252,6 +301,7 @@ func (u *userContactService) ApplyContact(req request.ApplyContactRequest) (stri
 			zlog.Error(err.Error())
 			return constants.SYSTEM_ERROR, -1
 		}
+		NotificationService.Notify(req.ContactId, notification_type_enum.NEW_CONTACT_APPLY, req.OwnerId, "", req.Message)
 		return "申请成功", 0
 	}
 	if req.ContactId[0] == 'G' {
@@ -294,6 +344,7 @@ func (u *userContactService) ApplyContact(req request.ApplyContactRequest) (stri
 			zlog.Error(err.Error())
 			return constants.SYSTEM_ERROR, -1
 		}
+		NotificationService.Notify(group.OwnerId, notification_type_enum.NEW_GROUP_APPLY, req.OwnerId, group.Uuid, req.Message)
 		return "申请成功", 0
 	}
 	return "用户/群聊不存在", -2
@@ -329,6 +380,12 @@ func (u *userContactService) GetNewContactList(ownerId string) (string, []respon
 			zlog.Error(err.Error())
 			return constants.SYSTEM_ERROR, nil, -1
 		}
+		_, isContact, err := getViewerRelation(u.userDao, ownerId, user.Uuid)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		applyUserPrivacy(user, false, isContact)
 		newContact.ContactId = user.Uuid
 		newContact.ContactName = user.Nickname
 		newContact.ContactAvatar = user.Avatar
@@ -368,6 +425,8 @@ func (u *userContactService) GetAddGroupList(groupId string) (string, []respond.
 			zlog.Error(err.Error())
 			return constants.SYSTEM_ERROR, nil, -1
 		}
+		// 申请人与群聊之间没有联系人关系，按陌生人处理
+		applyUserPrivacy(user, false, false)
 		newContact.ContactId = user.Uuid
 		newContact.ContactName = user.Nickname
 		newContact.ContactAvatar = user.Avatar
@@ -424,6 +483,11 @@ func (u *userContactService) PassContactApply(ownerId string, contactId string)
 		if err := myredis.DelKeysWithPattern("contact_user_list_" + ownerId); err != nil {
 			zlog.Error(err.Error())
 		}
+		if err := myredis.DelKeysWithPattern("contact_user_list_" + contactId); err != nil {
+			zlog.Error(err.Error())
+		}
+		delRecommendListCache(ownerId, contactId)
+		NotificationService.Notify(contactId, notification_type_enum.CONTACT_APPLY_PASSED, ownerId, "", "")
 		return "已添加该联系人", 0
 	}
 
@@ -467,6 +531,17 @@ func (u *userContactService) PassContactApply(ownerId string, contactId string)
 	if err := myredis.DelKeysWithPattern("my_joined_group_list_" + ownerId); err != nil {
 		zlog.Error(err.Error())
 	}
+	if user, err := u.userDao.GetUserByUUID(contactId); err != nil {
+		zlog.Error(err.Error())
+	} else {
+		MessageService.SendGroupSystemMessage(group, user, fmt.Sprintf("%s 加入了群聊", user.Nickname), members)
+	}
+	if err := myredis.DelKeysWithPattern("my_joined_group_list_" + contactId); err != nil {
+		zlog.Error(err.Error())
+	}
+	delRecommendListCache(contactId)
+	go GroupInfoService.RefreshGroupAvatar(group.Uuid)
+	NotificationService.Notify(contactId, notification_type_enum.GROUP_APPLY_PASSED, group.OwnerId, group.Uuid, "")
 	return "已通过加群申请", 0
 }
 
@@ -482,6 +557,7 @@ func (u *userContactService) RefuseContactApply(ownerId string, contactId string
 		zlog.Error(err.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	u.notifyApplyRefused(ownerId, contactId)
 	if ownerId[0] == 'U' {
 		return "已拒绝该联系人申请", 0
 	}
@@ -495,6 +571,7 @@ func (u *userContactService) BlackContact(ownerId string, contactId string) (str
 		zlog.Error(err.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	delRecommendListCache(ownerId, contactId)
 	return "已拉黑该联系人", 0
 }
 
@@ -520,6 +597,7 @@ func (u *userContactService) CancelBlackContact(ownerId string, contactId string
 		zlog.Error(err.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	delRecommendListCache(ownerId, contactId)
 	return "已解除拉黑该联系人", 0
 }
 
@@ -535,5 +613,170 @@ func (u *userContactService) BlackApply(ownerId string, contactId string) (strin
 		zlog.Error(err.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	// 不暴露拉黑，对申请人而言与被拒绝一致
+	u.notifyApplyRefused(ownerId, contactId)
+	if ownerId[0] == 'U' {
+		delRecommendListCache(ownerId, contactId)
+	}
 	return "已拉黑该申请", 0
 }
+
+// notifyApplyRefused 通知申请人申请被拒绝
+// ownerId为被申请的用户或群聊，contactId为申请人
+func (u *userContactService) notifyApplyRefused(ownerId string, contactId string) {
+	if ownerId[0] == 'U' {
+		NotificationService.Notify(contactId, notification_type_enum.CONTACT_APPLY_REFUSED, ownerId, "", "")
+		return
+	}
+	group, err := u.groupDao.GetGroupByUUID(ownerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return
+	}
+	NotificationService.Notify(contactId, notification_type_enum.GROUP_APPLY_REFUSED, group.OwnerId, group.Uuid, "")
+}
+
+// SetContactRemark 设置联系人备注，备注为空表示清除
+// 备注会影响联系人列表和会话列表的展示，所以需要删除对应的redis
+func (u *userContactService) SetContactRemark(req request.SetContactRemarkRequest) (string, int) {
+	if len([]rune(req.Remark)) > 20 {
+		return "备注不能超过20个字符", -2
+	}
+	contact, err := u.userContactDao.GetUserContact(req.OwnerId, req.ContactId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "该联系人不存在", -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	contact.Remark = req.Remark
+	contact.UpdateAt = time.Now()
+	if err := u.userContactDao.UpdateUserContact(contact); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if err := myredis.DelKeysWithPattern("contact_user_list_" + req.OwnerId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.DelKeysWithPattern("session_list_" + req.OwnerId); err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.DelKeysWithPattern("group_session_list_" + req.OwnerId); err != nil {
+		zlog.Error(err.Error())
+	}
+	return "设置备注成功", 0
+}
+
+// delRecommendListCache 联系人关系或加入的群聊发生变化后，删除相关用户的好友推荐缓存
+// 只删除直接相关的用户，其他用户的推荐结果随缓存过期刷新
+func delRecommendListCache(uuids ...string) {
+	for _, uuid := range uuids {
+		if err := myredis.DelKeysWithPattern("recommend_user_list_" + uuid); err != nil {
+			zlog.Error(err.Error())
+		}
+	}
+}
+
+// GetRecommendList 获取可能认识的人
+// 按共同好友数、共同群聊数降序排列，排除已有联系人关系、存在拉黑关系以及隐私设置不允许被添加的用户
+func (u *userContactService) GetRecommendList(ownerId string) (string, []respond.RecommendUserRespond, int) {
+	rspString, err := myredis.GetKeyNilIsErr("recommend_user_list_" + ownerId)
+	if err == nil {
+		var rsp []respond.RecommendUserRespond
+		if err := json.Unmarshal([]byte(rspString), &rsp); err != nil {
+			zlog.Error(err.Error())
+		}
+		return "获取推荐列表成功", rsp, 0
+	}
+	if !errors.Is(err, redis.Nil) {
+		zlog.Error(err.Error())
+	}
+
+	mutualCandidates, err := u.userContactDao.GetMutualContactCandidates(ownerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	groupCandidates, err := u.userContactDao.GetSharedGroupCandidates(ownerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	excludedIds, err := u.userContactDao.GetRecommendExcludedIds(ownerId)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	excludedSet := make(map[string]bool, len(excludedIds))
+	for _, uuid := range excludedIds {
+		excludedSet[uuid] = true
+	}
+
+	candidateMap := make(map[string]*respond.RecommendUserRespond)
+	getCandidate := func(userId string) *respond.RecommendUserRespond {
+		candidate, ok := candidateMap[userId]
+		if !ok {
+			candidate = &respond.RecommendUserRespond{UserId: userId}
+			candidateMap[userId] = candidate
+		}
+		return candidate
+	}
+	for _, mutual := range mutualCandidates {
+		if !excludedSet[mutual.UserId] {
+			getCandidate(mutual.UserId).MutualContactCnt = mutual.Count
+		}
+	}
+	for _, group := range groupCandidates {
+		if !excludedSet[group.UserId] {
+			getCandidate(group.UserId).SharedGroupCnt = group.Count
+		}
+	}
+
+	var rsp []respond.RecommendUserRespond
+	if len(candidateMap) > 0 {
+		uuids := make([]string, 0, len(candidateMap))
+		for uuid := range candidateMap {
+			uuids = append(uuids, uuid)
+		}
+		users, err := u.userDao.AbleUsersByUUIDs(uuids)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		for _, user := range users {
+			candidate := candidateMap[user.Uuid]
+			if user.Status == user_status_enum.DISABLE || user.AddMePolicy == add_me_policy_enum.NOBODY {
+				continue
+			}
+			if user.AddMePolicy == add_me_policy_enum.FRIENDS_OF_FRIENDS && candidate.MutualContactCnt == 0 {
+				continue
+			}
+			applyUserPrivacy(user, false, false)
+			candidate.Nickname = user.Nickname
+			candidate.Avatar = user.Avatar
+			rsp = append(rsp, *candidate)
+		}
+		sort.Slice(rsp, func(i, j int) bool {
+			if rsp[i].MutualContactCnt != rsp[j].MutualContactCnt {
+				return rsp[i].MutualContactCnt > rsp[j].MutualContactCnt
+			}
+			if rsp[i].SharedGroupCnt != rsp[j].SharedGroupCnt {
+				return rsp[i].SharedGroupCnt > rsp[j].SharedGroupCnt
+			}
+			return rsp[i].UserId < rsp[j].UserId
+		})
+		if len(rsp) > constants.RECOMMEND_MAX_RESULT {
+			rsp = rsp[:constants.RECOMMEND_MAX_RESULT]
+		}
+	}
+
+	rspBytes, err := json.Marshal(rsp)
+	if err != nil {
+		zlog.Error(err.Error())
+	}
+	if err := myredis.SetKeyEx("recommend_user_list_"+ownerId, string(rspBytes), time.Minute*constants.RECOMMEND_TIMEOUT); err != nil {
+		zlog.Error(err.Error())
+	}
+	return "获取推荐列表成功", rsp, 0
+}
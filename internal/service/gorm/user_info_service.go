@@ -11,10 +11,14 @@ import (
 	myredis "kama_chat_server/internal/service/redis"
 	"kama_chat_server/internal/service/sms"
 	"kama_chat_server/pkg/constants"
+	"kama_chat_server/pkg/enum/contact/contact_status_enum"
+	"kama_chat_server/pkg/enum/user_info/add_me_policy_enum"
 	"kama_chat_server/pkg/enum/user_info/user_status_enum"
+	"kama_chat_server/pkg/enum/user_info/visibility_enum"
 	"kama_chat_server/pkg/util/random"
 	"kama_chat_server/pkg/zlog"
 	"regexp"
+	"strings"
 	"time"
 
 	redis "github.com/go-redis/redis/v8"
@@ -59,6 +63,75 @@ func (u *userInfoService) checkUserIsAdminOrNot(user model.UserInfo) int8 {
 	return user.IsAdmin
 }
 
+// checkVisible 判断查看者能否看到设置了可见范围的信息
+func checkVisible(visibility int8, isSelf bool, isContact bool) bool {
+	switch visibility {
+	case visibility_enum.EVERYONE:
+		return true
+	case visibility_enum.CONTACTS:
+		return isSelf || isContact
+	default:
+		return isSelf
+	}
+}
+
+// getViewerRelation 获取查看者与被查看用户的关系，只有正常状态的好友才算联系人
+func getViewerRelation(userDao dao.UserDAO, viewerId, targetId string) (bool, bool, error) {
+	if viewerId == "" {
+		return false, false, nil
+	}
+	if viewerId == targetId {
+		return true, false, nil
+	}
+	contact, err := userDao.GetUserContact(viewerId, targetId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	return false, contact.Status == contact_status_enum.NORMAL, nil
+}
+
+// applyUserPrivacy 按照user的隐私设置，隐藏查看者无权看到的个性签名、头像、最近在线时间
+// 电话、邮箱、生日只对自己和联系人可见
+// user会被修改，调用方不要再将其写回数据库
+func applyUserPrivacy(user *model.UserInfo, isSelf bool, isContact bool) {
+	if !isSelf && !isContact {
+		user.Telephone = ""
+		user.Email = ""
+		user.Birthday = ""
+	}
+	if !checkVisible(user.SignatureVisibility, isSelf, isContact) {
+		user.Signature = ""
+	}
+	if !checkVisible(user.AvatarVisibility, isSelf, isContact) {
+		user.Avatar = constants.DEFAULT_AVATAR
+	}
+	if !checkVisible(user.LastSeenVisibility, isSelf, isContact) {
+		user.LastOnlineAt.Valid = false
+		user.LastOfflineAt.Valid = false
+	}
+}
+
+// checkAddMeAllowed 检查applicant是否可以申请添加target为好友
+func checkAddMeAllowed(userContactDao dao.UserContactDAO, applicantId string, target *model.UserInfo) (string, int) {
+	switch target.AddMePolicy {
+	case add_me_policy_enum.NOBODY:
+		return "对方不允许任何人添加好友", -2
+	case add_me_policy_enum.FRIENDS_OF_FRIENDS:
+		count, err := userContactDao.GetMutualContactCount(applicantId, target.Uuid)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		if count == 0 {
+			return "对方只允许朋友的朋友添加好友", -2
+		}
+	}
+	return "", 0
+}
+
 // Login 登录
 func (u *userInfoService) Login(loginReq request.LoginRequest) (string, *respond.LoginRespond, int) {
 	password := loginReq.Password
@@ -258,6 +331,7 @@ func (u *userInfoService) UpdateUserInfo(updateReq request.UpdateUserInfoRequest
 	if updateReq.Signature != "" {
 		user.Signature = updateReq.Signature
 	}
+	avatarChanged := updateReq.Avatar != "" && updateReq.Avatar != user.Avatar
 	if updateReq.Avatar != "" {
 		user.Avatar = updateReq.Avatar
 	}
@@ -265,6 +339,9 @@ func (u *userInfoService) UpdateUserInfo(updateReq request.UpdateUserInfoRequest
 		zlog.Error(err.Error())
 		return constants.SYSTEM_ERROR, -1
 	}
+	if avatarChanged {
+		go GroupInfoService.RefreshMemberGroupAvatars(user.Uuid)
+	}
 	//if err := myredis.DelKeysWithPattern("user_info_" + updateReq.Uuid); err != nil {
 	//	zlog.Error(err.Error())
 	//}
@@ -353,7 +430,8 @@ func (u *userInfoService) DeleteUsers(uuidList []string) (string, int) {
 }
 
 // GetUserInfo 获取用户信息
-func (u *userInfoService) GetUserInfo(uuid string) (string, *respond.GetUserInfoRespond, int) {
+// viewerId与uuid相同表示查看自己，否则按照隐私设置隐藏对方不可见的信息，viewerId为空时按陌生人处理
+func (u *userInfoService) GetUserInfo(viewerId, uuid string) (string, *respond.GetUserInfoRespond, int) {
 	// redis
 	zlog.Info(uuid)
 	rspString, err := myredis.GetKeyNilIsErr("user_info_" + uuid)
@@ -365,6 +443,13 @@ func (u *userInfoService) GetUserInfo(uuid string) (string, *respond.GetUserInfo
 				zlog.Error(err.Error())
 				return constants.SYSTEM_ERROR, nil, -1
 			}
+			// viewerId为空时getViewerRelation返回陌生人
+			isSelf, isContact, err := getViewerRelation(u.userDao, viewerId, uuid)
+			if err != nil {
+				zlog.Error(err.Error())
+				return constants.SYSTEM_ERROR, nil, -1
+			}
+			applyUserPrivacy(user, isSelf, isContact)
 			rsp := respond.GetUserInfoRespond{
 				Uuid:      user.Uuid,
 				Telephone: user.Telephone,
@@ -409,3 +494,160 @@ func (u *userInfoService) SetAdmin(uuidList []string, isAdmin int8) (string, int
 
 	return "设置管理员成功", 0
 }
+
+// GetPrivacySettings 获取隐私设置
+func (u *userInfoService) GetPrivacySettings(uuid string) (string, *respond.PrivacySettingsRespond, int) {
+	user, err := u.userDao.GetUserByUUID(uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	return "获取隐私设置成功", &respond.PrivacySettingsRespond{
+		Uuid:                user.Uuid,
+		AddMePolicy:         user.AddMePolicy,
+		ForbidPhoneSearch:   user.ForbidPhoneSearch,
+		SignatureVisibility: user.SignatureVisibility,
+		AvatarVisibility:    user.AvatarVisibility,
+		LastSeenVisibility:  user.LastSeenVisibility,
+	}, 0
+}
+
+// UpdatePrivacySettings 修改隐私设置
+// 头像可见范围会影响所有人的contact_user_list，所以需要删除redis的contact_user_list，同时重新生成所在群聊的群头像
+func (u *userInfoService) UpdatePrivacySettings(req request.UpdatePrivacySettingsRequest) (string, int) {
+	if req.AddMePolicy < add_me_policy_enum.ANYONE || req.AddMePolicy > add_me_policy_enum.NOBODY {
+		return "加好友方式不合法", -2
+	}
+	if req.ForbidPhoneSearch != 0 && req.ForbidPhoneSearch != 1 {
+		return "电话搜索设置不合法", -2
+	}
+	for _, visibility := range []int8{req.SignatureVisibility, req.AvatarVisibility, req.LastSeenVisibility} {
+		if visibility < visibility_enum.EVERYONE || visibility > visibility_enum.NOBODY {
+			return "可见范围不合法", -2
+		}
+	}
+	user, err := u.userDao.GetUserByUUID(req.Uuid)
+	if err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	avatarVisibilityChanged := user.AvatarVisibility != req.AvatarVisibility
+	user.AddMePolicy = req.AddMePolicy
+	user.ForbidPhoneSearch = req.ForbidPhoneSearch
+	user.SignatureVisibility = req.SignatureVisibility
+	user.AvatarVisibility = req.AvatarVisibility
+	user.LastSeenVisibility = req.LastSeenVisibility
+	if err := u.userDao.UpdateUser(user); err != nil {
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, -1
+	}
+	if err := myredis.DelKeysWithPrefix("contact_user_list"); err != nil {
+		zlog.Error(err.Error())
+	}
+	if avatarVisibilityChanged {
+		go GroupInfoService.RefreshMemberGroupAvatars(user.Uuid)
+	}
+	return "修改隐私设置成功", 0
+}
+
+// searchLimit 一项搜索限流
+type searchLimit struct {
+	key     string
+	window  time.Duration
+	limit   int64
+	message string
+}
+
+// checkSearchRateLimit 检查搜索用户是否超过限流，按电话搜索额外限制每个ip每天的次数和全局每分钟的次数
+// owner_id没有经过认证，可以随意更换，也可以用来消耗别人的次数，所以只按客户端ip和全局计数
+// 计数使用PersistentKeyPrefix，重启服务不会重置
+func (u *userInfoService) checkSearchRateLimit(clientIp string, byPhone bool) (string, int) {
+	limits := []searchLimit{
+		{"search_user_limit_ip_" + clientIp, time.Minute, constants.SEARCH_USER_LIMIT_PER_MINUTE, "搜索过于频繁，请稍后再试"},
+	}
+	if byPhone {
+		limits = append(limits,
+			searchLimit{"search_phone_limit_ip_" + clientIp, 24 * time.Hour, constants.SEARCH_PHONE_LIMIT_PER_DAY, "今日按电话搜索次数已达上限"},
+			searchLimit{"search_phone_limit_global", time.Minute, constants.SEARCH_PHONE_GLOBAL_LIMIT_PER_MINUTE, "搜索过于频繁，请稍后再试"},
+		)
+	}
+	for _, limit := range limits {
+		count, err := myredis.IncrKeyEx(myredis.PersistentKeyPrefix+limit.key, limit.window)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, -1
+		}
+		if count > limit.limit {
+			return limit.message, -2
+		}
+	}
+	return "", 0
+}
+
+// SearchUser 按完整电话号码或昵称前缀搜索用户，只返回最少的资料
+// 关闭了电话搜索的用户不会被电话搜到，且与用户不存在时的返回一致，避免暴露号码是否注册
+// 只有存在且未被禁用的用户可以搜索，限流按clientIp和全局计数，clientIp只信任配置的代理转发的地址
+func (u *userInfoService) SearchUser(ownerId string, clientIp string, keyword string) (string, []respond.SearchUserRespond, int) {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return "搜索内容不能为空", nil, -2
+	}
+	owner, err := u.userDao.GetUserByUUID(ownerId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "用户不存在", nil, -2
+		}
+		zlog.Error(err.Error())
+		return constants.SYSTEM_ERROR, nil, -1
+	}
+	if owner.Status == user_status_enum.DISABLE {
+		return "用户已被禁用", nil, -2
+	}
+	byPhone := u.checkTelephoneValid(keyword)
+	if message, ret := u.checkSearchRateLimit(clientIp, byPhone); ret != 0 {
+		return message, nil, ret
+	}
+
+	var users []model.UserInfo
+	if byPhone {
+		user, err := u.userDao.GetUserByTelephone(keyword)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "未找到相关用户", nil, 0
+			}
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		if user.ForbidPhoneSearch == 1 || user.Status == user_status_enum.DISABLE || user.Uuid == ownerId {
+			return "未找到相关用户", nil, 0
+		}
+		users = append(users, *user)
+	} else {
+		var err error
+		users, err = u.userDao.SearchUsersByNicknamePrefix(keyword, ownerId, constants.SEARCH_USER_MAX_RESULT)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		if len(users) == 0 {
+			return "未找到相关用户", nil, 0
+		}
+	}
+
+	var rsp []respond.SearchUserRespond
+	for i := range users {
+		user := &users[i]
+		_, isContact, err := getViewerRelation(u.userDao, ownerId, user.Uuid)
+		if err != nil {
+			zlog.Error(err.Error())
+			return constants.SYSTEM_ERROR, nil, -1
+		}
+		applyUserPrivacy(user, false, isContact)
+		rsp = append(rsp, respond.SearchUserRespond{
+			UserId:   user.Uuid,
+			Nickname: user.Nickname,
+			Avatar:   user.Avatar,
+		})
+	}
+	return "搜索成功", rsp, 0
+}
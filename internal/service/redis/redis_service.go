@@ -9,6 +9,7 @@ import (
 	"kama_chat_server/pkg/zlog"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -38,6 +39,24 @@ func SetKeyEx(key string, value string, timeout time.Duration) error {
 	return nil
 }
 
+// incrExScript 自增并在key没有过期时间时设置过期时间，放在同一个脚本中执行
+// 避免INCR成功后EXPIRE失败或进程退出，留下永不过期的计数
+var incrExScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("TTL", KEYS[1]) == -1 then
+	redis.call("EXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// PersistentKeyPrefix 以该前缀开头的key在关闭服务时不删除，如限流计数，重启不能重置限制
+const PersistentKeyPrefix = "persist_"
+
+// IncrKeyEx 自增key，key第一次创建时设置过期时间，用于固定窗口限流
+func IncrKeyEx(key string, timeout time.Duration) (int64, error) {
+	return incrExScript.Run(ctx, redisClient, []string{key}, int64(timeout/time.Second)).Int64()
+}
+
 func GetKey(key string) (string, error) {
 	value, err := redisClient.Get(ctx, key).Result()
 	if err != nil {
@@ -127,6 +146,14 @@ func DelKeyIfExists(key string) error {
 	return nil
 }
 
+// DelKeys 一次删除多个确定的键，不存在的键直接忽略
+func DelKeys(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return redisClient.Del(ctx, keys...).Err()
+}
+
 func DelKeysWithPattern(pattern string) error {
 	var keys []string
 	var err error
@@ -219,15 +246,22 @@ func DelKeysWithSuffix(suffix string) error {
 	return nil
 }
 
+// DeleteAllRedisKeys 删除所有缓存，PersistentKeyPrefix开头的key保留
 func DeleteAllRedisKeys() error {
 	var cursor uint64 = 0
 	for {
-		keys, nextCursor, err := redisClient.Scan(ctx, cursor, "*", 0).Result()
+		scanned, nextCursor, err := redisClient.Scan(ctx, cursor, "*", 0).Result()
 		if err != nil {
 			return err
 		}
 		cursor = nextCursor
 
+		keys := scanned[:0]
+		for _, key := range scanned {
+			if !strings.HasPrefix(key, PersistentKeyPrefix) {
+				keys = append(keys, key)
+			}
+		}
 		if len(keys) > 0 {
 			_, err := redisClient.Del(ctx, keys...).Result()
 			if err != nil {
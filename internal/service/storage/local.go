@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// localBackend 本地磁盘存储，key的第一级目录映射到配置的本地目录
+type localBackend struct {
+	dirs map[string]string
+}
+
+func newLocalBackend(dirs map[string]string) *localBackend {
+	return &localBackend{dirs: dirs}
+}
+
+func (l *localBackend) path(key string) (string, error) {
+	dir, name := path.Split(key)
+	root, ok := l.dirs[path.Clean(dir)]
+	if !ok || name == "" {
+		return "", fmt.Errorf("文件路径不合法: %s", key)
+	}
+	return filepath.Join(root, filepath.Base(name)), nil
+}
+
+// Put 先写入同目录下的临时文件再重命名，按内容存储的文件可能被同时上传，
+// 正在下载的读者不会读到写了一半的文件，写入失败也不会删掉别人已经写好的文件
+func (l *localBackend) Put(key string, reader io.Reader, size int64, contentType string) error {
+	localPath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	out, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, reader); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	// CreateTemp创建的文件权限为0600，改为与之前os.Create默认创建的一致
+	if err := os.Chmod(out.Name(), 0644); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	if err := os.Rename(out.Name(), localPath); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return nil
+}
+
+func (l *localBackend) Open(key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	localPath, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, &ObjectInfo{
+		Size:        stat.Size(),
+		ModTime:     stat.ModTime(),
+		ContentType: mime.TypeByExtension(filepath.Ext(localPath)),
+	}, nil
+}
+
+func (l *localBackend) Stat(key string) (*ObjectInfo, error) {
+	localPath, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{
+		Size:        stat.Size(),
+		ModTime:     stat.ModTime(),
+		ContentType: mime.TypeByExtension(filepath.Ext(localPath)),
+	}, nil
+}
+
+func (l *localBackend) Delete(key string) error {
+	localPath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *localBackend) Copy(srcKey, dstKey string) error {
+	src, _, err := l.Open(srcKey)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	return l.Put(dstKey, src, -1, "")
+}
+
+// URL 本地文件由本服务的/static下载
+func (l *localBackend) URL(key string) string {
+	return "/static/" + key
+}
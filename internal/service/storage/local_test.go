@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingReader 读出一部分内容后返回错误，模拟上传中断
+type failingReader struct {
+	data string
+	read bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, errors.New("connection reset")
+	}
+	r.read = true
+	return copy(p, r.data), nil
+}
+
+func TestLocalPut(t *testing.T) {
+	dir := t.TempDir()
+	backend := newLocalBackend(map[string]string{"files": dir})
+	if err := backend.Put("files/a.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("ReadFile = %q, %v", data, err)
+	}
+	stat, err := os.Stat(filepath.Join(dir, "a.txt"))
+	if err != nil || stat.Mode().Perm() != 0644 {
+		t.Errorf("文件权限 = %v, %v, want 0644", stat.Mode().Perm(), err)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestLocalPutFailureKeepsExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	backend := newLocalBackend(map[string]string{"files": dir})
+	if err := backend.Put("files/a.txt", strings.NewReader("hello"), 5, ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := backend.Put("files/a.txt", &failingReader{data: "half"}, 10, ""); err == nil {
+		t.Fatal("Put with a failing reader returned nil error")
+	}
+	reader, _, err := backend.Open("files/a.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer reader.Close()
+	data, _ := io.ReadAll(reader)
+	if string(data) != "hello" {
+		t.Errorf("写入失败后文件内容 = %q, want hello", data)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			t.Errorf("残留临时文件 %s", entry.Name())
+		}
+	}
+}
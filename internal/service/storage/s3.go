@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"kama_chat_server/internal/config"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Backend S3兼容的对象存储，如MinIO、阿里云OSS
+type s3Backend struct {
+	client    *minio.Client
+	bucket    string
+	publicUrl string
+}
+
+func newS3Backend(conf config.StorageConfig) (*s3Backend, error) {
+	client, err := minio.New(conf.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(conf.AccessKey, conf.SecretKey, ""),
+		Secure: conf.UseSSL,
+		Region: conf.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, conf.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, conf.Bucket, minio.MakeBucketOptions{Region: conf.Region}); err != nil {
+			return nil, err
+		}
+	}
+	return &s3Backend{
+		client:    client,
+		bucket:    conf.Bucket,
+		publicUrl: strings.TrimRight(conf.PublicUrl, "/"),
+	}, nil
+}
+
+// convertError 把对象不存在的错误转换为ErrNotExist
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if code := minio.ToErrorResponse(err).Code; code == "NoSuchKey" || code == "NotFound" {
+		return ErrNotExist
+	}
+	return err
+}
+
+func (s *s3Backend) Put(key string, reader io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, key, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *s3Backend) Open(key string) (io.ReadSeekCloser, *ObjectInfo, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, convertError(err)
+	}
+	// GetObject不会立即请求，Stat时才能知道对象是否存在
+	stat, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, nil, convertError(err)
+	}
+	return object, &ObjectInfo{
+		Size:        stat.Size,
+		ModTime:     stat.LastModified,
+		ContentType: stat.ContentType,
+	}, nil
+}
+
+func (s *s3Backend) Stat(key string) (*ObjectInfo, error) {
+	stat, err := s.client.StatObject(context.Background(), s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return &ObjectInfo{
+		Size:        stat.Size,
+		ModTime:     stat.LastModified,
+		ContentType: stat.ContentType,
+	}, nil
+}
+
+func (s *s3Backend) Delete(key string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Backend) Copy(srcKey, dstKey string) error {
+	_, err := s.client.CopyObject(context.Background(),
+		minio.CopyDestOptions{Bucket: s.bucket, Object: dstKey},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: srcKey},
+	)
+	return convertError(err)
+}
+
+// URL 配置了publicUrl时头像直接访问对象存储，其他文件和未配置时由本服务代理
+// 聊天文件只能通过签名地址下载，不能直接暴露对象存储的地址
+func (s *s3Backend) URL(key string) string {
+	if s.publicUrl != "" && IsAvatarKey(key) {
+		return s.publicUrl + "/" + key
+	}
+	return "/static/" + key
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var signSecret []byte
+
+// initSignSecret 设置下载地址的签名密钥，未配置时随机生成，重启后之前签发的地址失效，多实例部署必须配置
+func initSignSecret(secret string) error {
+	if secret != "" {
+		signSecret = []byte(secret)
+		return nil
+	}
+	signSecret = make([]byte, 32)
+	_, err := rand.Read(signSecret)
+	return err
+}
+
+// SignDownload 生成带签名的下载地址，有效期内持有地址即可下载，支持Range
+// name为保存时的文件名，inline为true时浏览器直接展示（图片、语音），否则作为附件下载
+func SignDownload(key, name string, inline bool, expireAt time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expireAt.Unix(), 10))
+	query.Set("name", name)
+	if inline {
+		query.Set("inline", "1")
+	}
+	query.Set("sign", downloadSign(key, query))
+	return "/download/" + key + "?" + query.Encode()
+}
+
+// VerifyDownload 校验下载地址的签名和有效期
+func VerifyDownload(key string, query url.Values) bool {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(downloadSign(key, query)), []byte(query.Get("sign")))
+}
+
+func downloadSign(key string, query url.Values) string {
+	mac := hmac.New(sha256.New, signSecret)
+	mac.Write([]byte(key + "\n" + query.Get("expires") + "\n" + query.Get("name") + "\n" + query.Get("inline")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
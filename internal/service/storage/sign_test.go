@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseSigned 拆出签名地址中的key和参数
+func parseSigned(t *testing.T, signed string) (string, url.Values) {
+	t.Helper()
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", signed, err)
+	}
+	if !strings.HasPrefix(u.Path, "/download/") {
+		t.Fatalf("签名地址 %q 不以/download/开头", signed)
+	}
+	return strings.TrimPrefix(u.Path, "/download/"), u.Query()
+}
+
+func TestSignDownload(t *testing.T) {
+	signSecret = []byte("test secret")
+	expireAt := time.Now().Add(time.Hour)
+	key, query := parseSigned(t, SignDownload("files/a.png", "照片 1.png", true, expireAt))
+	if key != "files/a.png" {
+		t.Errorf("key = %q, want files/a.png", key)
+	}
+	if query.Get("name") != "照片 1.png" || query.Get("inline") != "1" {
+		t.Errorf("query = %v", query)
+	}
+	if query.Get("expires") != strconv.FormatInt(expireAt.Unix(), 10) {
+		t.Errorf("expires = %q", query.Get("expires"))
+	}
+	if !VerifyDownload(key, query) {
+		t.Error("VerifyDownload() = false for a freshly signed url")
+	}
+
+	_, attachment := parseSigned(t, SignDownload("files/a.png", "a.png", false, expireAt))
+	if attachment.Has("inline") {
+		t.Errorf("附件下载不应带inline: %v", attachment)
+	}
+}
+
+func TestVerifyDownload(t *testing.T) {
+	signSecret = []byte("test secret")
+	key, valid := parseSigned(t, SignDownload("files/a.pdf", "a.pdf", false, time.Now().Add(time.Hour)))
+	_, expired := parseSigned(t, SignDownload("files/a.pdf", "a.pdf", false, time.Now().Add(-time.Second)))
+
+	modify := func(name, value string) url.Values {
+		query := url.Values{}
+		for k, v := range valid {
+			query[k] = append([]string(nil), v...)
+		}
+		if value == "" {
+			query.Del(name)
+		} else {
+			query.Set(name, value)
+		}
+		return query
+	}
+	tests := []struct {
+		name  string
+		key   string
+		query url.Values
+		want  bool
+	}{
+		{"有效", key, valid, true},
+		{"已过期", key, expired, false},
+		{"换成其他文件", "files/b.pdf", valid, false},
+		{"改文件名", key, modify("name", "b.exe"), false},
+		{"改为直接展示", key, modify("inline", "1"), false},
+		{"延长有效期", key, modify("expires", strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10)), false},
+		{"有效期不是数字", key, modify("expires", "tomorrow"), false},
+		{"缺少签名", key, modify("sign", ""), false},
+		{"签名错误", key, modify("sign", strings.Repeat("0", 64)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyDownload(tt.key, tt.query); got != tt.want {
+				t.Errorf("VerifyDownload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 换了密钥后之前签发的地址失效
+	signSecret = []byte("another secret")
+	if VerifyDownload(key, valid) {
+		t.Error("VerifyDownload() = true after the secret changed")
+	}
+}
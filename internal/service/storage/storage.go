@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"kama_chat_server/internal/config"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotExist 文件不存在，各后端统一返回该错误，可以用errors.Is判断
+var ErrNotExist = fs.ErrNotExist
+
+// ObjectInfo 文件信息
+type ObjectInfo struct {
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// Backend 文件存储后端
+// key为存储内的相对路径，头像为avatars/文件名，聊天文件为files/文件名，上传中的分片为parts/文件名
+type Backend interface {
+	// Put 写入文件，size未知时传-1
+	Put(key string, reader io.Reader, size int64, contentType string) error
+	// Open 打开文件用于读取，返回的文件支持Seek，可以直接用于Range下载
+	Open(key string) (io.ReadSeekCloser, *ObjectInfo, error)
+	Stat(key string) (*ObjectInfo, error)
+	Delete(key string) error
+	Copy(srcKey, dstKey string) error
+	// URL 文件的访问地址
+	URL(key string) string
+}
+
+var Storage Backend
+
+// publicUrl 配置的公开访问地址，去掉结尾的/
+var publicUrl string
+
+// Init 按配置初始化存储后端和下载签名密钥，在主进程启动服务前调用，包内不读取全局配置
+func Init(conf *config.Config) error {
+	if err := initSignSecret(conf.DownloadConfig.SignSecret); err != nil {
+		return err
+	}
+	publicUrl = strings.TrimRight(conf.StorageConfig.PublicUrl, "/")
+	switch conf.StorageConfig.Type {
+	case "s3":
+		backend, err := newS3Backend(conf.StorageConfig)
+		if err != nil {
+			return err
+		}
+		Storage = backend
+	default:
+		Storage = newLocalBackend(map[string]string{
+			"avatars": conf.StaticAvatarPath,
+			"files":   conf.StaticFilePath,
+			"parts":   conf.StaticPartPath,
+		})
+	}
+	return nil
+}
+
+func FileKey(fileName string) string {
+	return "files/" + fileName
+}
+
+func AvatarKey(fileName string) string {
+	return "avatars/" + fileName
+}
+
+// AvatarSizeKey 头像缩小后的尺寸，avatars/<文件名>_<边长><扩展名>
+func AvatarSizeKey(fileName string, size int) string {
+	ext := path.Ext(fileName)
+	return fmt.Sprintf("avatars/%s_%d%s", strings.TrimSuffix(fileName, ext), size, ext)
+}
+
+// IsAvatarKey 头像公开访问，聊天文件需要通过签名地址下载
+func IsAvatarKey(key string) bool {
+	return strings.HasPrefix(key, "avatars/")
+}
+
+// PartKey 分片上传的分片，不能通过/static下载
+func PartKey(uploadId string, partIndex int) string {
+	return fmt.Sprintf("parts/%s_%d", uploadId, partIndex)
+}
+
+// KeyFromURL 从文件url中取出key，url可以带域名前缀
+// 支持本服务/static下的地址和配置的publicUrl，其他url（如默认头像）返回false
+func KeyFromURL(url string) (string, bool) {
+	var key string
+	if publicUrl != "" && strings.HasPrefix(url, publicUrl+"/") {
+		key = url[len(publicUrl)+1:]
+	} else if staticIndex := strings.Index(url, "/static/"); staticIndex >= 0 {
+		key = url[staticIndex+len("/static/"):]
+	} else {
+		return "", false
+	}
+	return CleanKey(key)
+}
+
+// CleanKey 规范化key，拒绝跳出存储目录的路径
+func CleanKey(key string) (string, bool) {
+	if i := strings.IndexAny(key, "?#"); i >= 0 {
+		key = key[:i]
+	}
+	key = path.Clean("/" + key)[1:]
+	dir, name := path.Split(key)
+	if name == "" || (dir != "avatars/" && dir != "files/") {
+		return "", false
+	}
+	return key, true
+}
@@ -5,4 +5,80 @@ const (
 	SYSTEM_ERROR  = "系统错误，请联系工作人员" // 系统错误
 	FILE_MAX_SIZE = 50000          // 文件最大大小
 	REDIS_TIMEOUT = 1              // redis timeout
+	// 默认头像，头像对查看者不可见时也返回默认头像
+	DEFAULT_AVATAR = "https://cube.elemecdn.com/0/88/03b0d39583f48206768a7534e55bcpng.png"
+	// 搜索用户限流，防止遍历电话号码
+	SEARCH_USER_LIMIT_PER_MINUTE         = 20  // 每个ip每分钟最多搜索次数
+	SEARCH_PHONE_LIMIT_PER_DAY           = 50  // 每个ip每天最多按电话搜索次数
+	SEARCH_PHONE_GLOBAL_LIMIT_PER_MINUTE = 300 // 所有人每分钟最多按电话搜索次数
+	SEARCH_USER_MAX_RESULT               = 20  // 按昵称搜索最多返回条数
+	// 好友推荐
+	RECOMMEND_MAX_RESULT = 20 // 最多推荐人数
+	RECOMMEND_TIMEOUT    = 10 // 推荐列表redis timeout，单位分钟
+	// 会话最新消息批量更新
+	SESSION_FLUSH_INTERVAL = 500 // 最长攒批时间，单位毫秒
+	SESSION_FLUSH_BATCH    = 200 // 攒够多少条消息立即更新
+	SESSION_PREVIEW_LEN    = 50  // 会话列表最新消息预览的最大字符数
+	// 会话草稿
+	DRAFT_MAX_LEN = 2000 // 草稿最大字符数
+	// 收藏
+	FAVORITE_PAGE_SIZE     = 20 // 默认每页条数
+	FAVORITE_MAX_PAGE_SIZE = 100
+	FAVORITE_MAX_TAGS      = 10 // 每条收藏最多标签数
+	FAVORITE_TAG_MAX_LEN   = 10 // 标签最大字符数
+	// 定时消息
+	SCHEDULED_MESSAGE_POLL_INTERVAL = 1   // 扫描到期消息的间隔，单位秒
+	SCHEDULED_MESSAGE_BATCH         = 100 // 每次最多发送条数
+	SCHEDULED_MESSAGE_MAX_PENDING   = 100 // 每个用户最多等待发送的定时消息数
+	SCHEDULED_MESSAGE_MAX_DAYS      = 30  // 最远可以定时到多少天后
+	// 消息过期
+	MESSAGE_TTL_MIN               = 5         // 最短过期时长，单位秒
+	MESSAGE_TTL_MAX               = 7 * 86400 // 最长过期时长，单位秒
+	MESSAGE_EXPIRE_SWEEP_INTERVAL = 1         // 清理过期消息的间隔，单位秒
+	MESSAGE_EXPIRE_BATCH          = 200       // 每次最多清理条数
+	// 语音消息
+	VOICE_MAX_DURATION  = 60               // 最长语音时长，单位秒
+	VOICE_MAX_SIZE      = 10 * 1024 * 1024 // 语音文件最大字节数
+	VOICE_MIN_BYTE_RATE = 500              // 语音的最低码率，单位字节/秒（约4kbps，低于AMR-NB的最低码率），用于估算时长上限
+	VOICE_MAX_BYTE_RATE = 192000           // 语音的最高码率，单位字节/秒（48kHz 16位双声道PCM），用于估算时长下限
+	// 图片消息
+	IMAGE_MAX_SIZE       = 20 * 1024 * 1024 // 图片文件最大字节数
+	IMAGE_MAX_PIXELS     = 40000000         // 最大像素数，防止解码超大图片占满内存
+	IMAGE_THUMBNAIL_SIDE = 320              // 缩略图最长边像素
+	// 位置消息
+	LOCATION_NAME_MAX_LEN    = 100 // 地点名称最大字符数
+	LOCATION_ADDRESS_MAX_LEN = 255 // 地址最大字符数
+	// 分片上传
+	UPLOAD_CHUNK_SIZE     = 5 * 1024 * 1024        // 默认分片大小
+	UPLOAD_MIN_CHUNK_SIZE = 256 * 1024             // 最小分片大小，最后一片除外
+	UPLOAD_MAX_CHUNK_SIZE = 20 * 1024 * 1024       // 最大分片大小
+	UPLOAD_MAX_FILE_SIZE  = 4 * 1024 * 1024 * 1024 // 分片上传的最大文件大小
+	UPLOAD_EXPIRE_HOURS   = 24                     // 未完成的上传任务保留时长，单位小时
+	UPLOAD_CLEAN_INTERVAL = 10                     // 清理过期上传任务的间隔，单位分钟
+	UPLOAD_CLEAN_BATCH    = 100                    // 每次最多清理的任务数
+	// 秒传校验
+	QUICK_UPLOAD_PROOF_SIZE        = 64 * 1024 // 秒传时客户端需要计算校验的文件片段长度
+	QUICK_UPLOAD_CHALLENGE_TIMEOUT = 5         // 秒传校验的有效期，单位分钟
+	// 文件去重
+	FILE_BLOB_GC_GRACE_DAYS = 31  // 没有引用的文件保留天数，不短于定时消息最远的定时天数
+	FILE_BLOB_GC_BATCH      = 100 // 每次最多回收的文件数
+	// 文件下载
+	DOWNLOAD_URL_EXPIRE = 600 // 签名下载地址的有效期，单位秒
+	// 存储配额
+	STORAGE_TOP_LIMIT     = 20  // 默认查看的占用最多的用户和群聊数
+	STORAGE_TOP_MAX_LIMIT = 100 // 最多查看的用户和群聊数
+	// 头像
+	AVATAR_MAX_SIZE          = 5 * 1024 * 1024 // 头像文件最大大小
+	AVATAR_SIZE              = 640             // 头像裁剪为正方形后的最大边长，原图更小时不放大
+	AVATAR_MEDIUM_SIZE       = 160             // 列表中使用的中等尺寸
+	AVATAR_SMALL_SIZE        = 64              // 会话列表等使用的小尺寸
+	GROUP_AVATAR_SIZE        = 320             // 自动生成的群头像边长
+	GROUP_AVATAR_MAX_MEMBERS = 9               // 自动生成群头像最多使用的成员数
+	// 音视频通话
+	CALL_RING_TIMEOUT            = 60   // 呼叫无人接听的超时时间，单位秒
+	CALL_HISTORY_PAGE_SIZE       = 20   // 通话记录默认每页条数
+	CALL_HISTORY_MAX_PAGE_SIZE   = 100  // 通话记录每页最大条数
+	ICE_CREDENTIAL_TTL           = 3600 // TURN凭证默认有效期，单位秒，客户端每次通话前重新获取
+	ICE_SERVERS_LIMIT_PER_MINUTE = 10   // 每个ip每分钟最多获取TURN凭证次数
+	GROUP_CALL_MAX_PARTICIPANTS  = 9    // 群通话最多参与人数，参与者两两直连，人数多时带宽和性能无法承受
 )
@@ -0,0 +1,18 @@
+package call_status_enum
+
+const (
+	// 呼叫中，等待被叫方接听
+	RINGING = iota
+	// 已接通，通话中
+	ACCEPTED
+	// 被叫方拒绝
+	REJECTED
+	// 主叫方在接通前取消
+	CANCELLED
+	// 被叫方不在线或正在通话中，呼叫无法送达
+	MISSED
+	// 接通后任意一方挂断
+	ENDED
+	// 超时无人接听
+	TIMEOUT
+)
@@ -0,0 +1,8 @@
+package favorite_type_enum
+
+const (
+	// 收藏的聊天消息
+	MESSAGE = iota
+	// 用户直接记录的笔记
+	NOTE
+)
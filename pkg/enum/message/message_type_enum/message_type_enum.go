@@ -8,4 +8,18 @@ const (
 	File
 	// 通话
 	AudioOrVideo
+	// 系统消息，如入群、退群、踢人、修改群公告等
+	System
+	// 通知，如好友申请、申请结果等，只推送不进入聊天记录
+	Notification
+	// 消息过期，通知前端删除已过期的消息，只推送不进入聊天记录
+	Expire
+	// 图片，带宽高和缩略图
+	Image
+	// 位置
+	Location
+	// 名片，用户或群聊
+	ContactCard
+	// 发送失败，通知发送者消息未通过检查，只推送不进入聊天记录
+	SendFailed
 )
@@ -0,0 +1,8 @@
+package notification_status_enum
+
+const (
+	// 未送达
+	UNSENT = iota
+	// 已送达
+	SENT
+)
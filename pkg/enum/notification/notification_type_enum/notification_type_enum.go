@@ -0,0 +1,18 @@
+package notification_type_enum
+
+const (
+	// 收到好友申请
+	NEW_CONTACT_APPLY = iota
+	// 好友申请被通过
+	CONTACT_APPLY_PASSED
+	// 好友申请被拒绝
+	CONTACT_APPLY_REFUSED
+	// 收到加群申请（群主）
+	NEW_GROUP_APPLY
+	// 加群申请被通过，已加入群聊
+	GROUP_APPLY_PASSED
+	// 加群申请被拒绝
+	GROUP_APPLY_REFUSED
+	// 定时消息发送失败，如已退群、被拉黑
+	SCHEDULED_MESSAGE_FAILED
+)
@@ -0,0 +1,12 @@
+package scheduled_message_status_enum
+
+const (
+	// 等待发送
+	PENDING = iota
+	// 已发送
+	SENT
+	// 已取消
+	CANCELED
+	// 发送时校验不通过，如已退群、被拉黑
+	FAILED
+)
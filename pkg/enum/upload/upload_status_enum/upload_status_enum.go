@@ -0,0 +1,12 @@
+package upload_status_enum
+
+const (
+	// 上传中，可以继续上传分片
+	UPLOADING = iota
+	// 正在合并分片，防止重复合并
+	COMPLETING
+	COMPLETED
+	// 整体校验失败
+	FAILED
+	CANCELED
+)
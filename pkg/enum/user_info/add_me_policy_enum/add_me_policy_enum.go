@@ -0,0 +1,8 @@
+package add_me_policy_enum
+
+// 谁可以申请添加我为好友
+const (
+	ANYONE = iota
+	FRIENDS_OF_FRIENDS
+	NOBODY
+)
@@ -0,0 +1,8 @@
+package visibility_enum
+
+// 个性签名、头像、最近在线时间的可见范围
+const (
+	EVERYONE = iota
+	CONTACTS
+	NOBODY
+)
@@ -0,0 +1,48 @@
+package filename
+
+import (
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLen 文件名最大字节数，与常见文件系统的限制一致
+const MaxLen = 255
+
+// Sanitize 清理客户端给出的文件名，只保留最后一段，不能用于跳出目录
+// 去掉路径分隔符、控制字符和Windows保留字符，开头的点会被去掉以免生成隐藏文件，清理后为空时返回file
+func Sanitize(name string) string {
+	// 同时按/和\取最后一段，Windows客户端会带上完整路径
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+	name = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) || strings.ContainsRune(`/<>:"|?*`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "file"
+	}
+	if len(name) > MaxLen {
+		name = truncate(name, MaxLen)
+	}
+	return name
+}
+
+// truncate 截断到不超过max字节，保留扩展名，不截断多字节字符
+func truncate(name string, max int) string {
+	ext := path.Ext(name)
+	if len(ext) > max/2 {
+		ext = ""
+	}
+	base := name[:len(name)-len(ext)]
+	limit := max - len(ext)
+	for limit > 0 && !utf8.RuneStart(base[limit]) {
+		limit--
+	}
+	return base[:limit] + ext
+}
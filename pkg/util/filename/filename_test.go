@@ -0,0 +1,65 @@
+package filename
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"中文 文件.docx", "中文 文件.docx"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\Desktop\a.txt`, "a.txt"},
+		{`..\..\windows\win.ini`, "win.ini"},
+		{"dir/", "dir"},
+		{"/", "file"},
+		{"", "file"},
+		{"..", "file"},
+		{"...", "file"},
+		{".bashrc", "bashrc"},
+		{"  ..hidden.txt", "hidden.txt"},
+		{"name. . ", "name"},
+		{"evil.bat.", "evil.bat"},
+		{"evil.ps1 ", "evil.ps1"},
+		{"a<b>c:d\"e|f?g*.txt", "abcdefg.txt"},
+		{"line\nbreak\t.txt", "linebreak.txt"},
+		{"nul\x00.txt", "nul.txt"},
+		{"bad\xff\xfe.txt", "bad.txt"},
+		{"\x7f", "file"},
+	}
+	for _, tt := range tests {
+		if got := Sanitize(tt.name); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"刚好不超过", strings.Repeat("a", MaxLen-4) + ".txt", strings.Repeat("a", MaxLen-4) + ".txt"},
+		{"保留扩展名", strings.Repeat("a", 300) + ".txt", strings.Repeat("a", MaxLen-4) + ".txt"},
+		// 251字节处落在第84个汉字中间，退回到249字节
+		{"不截断多字节字符", strings.Repeat("中", 100) + ".txt", strings.Repeat("中", 83) + ".txt"},
+		{"扩展名过长时不保留", "a." + strings.Repeat("b", 300), "a." + strings.Repeat("b", MaxLen-2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sanitize(tt.in)
+			if got != tt.want {
+				t.Errorf("Sanitize() = %q (%d bytes), want %q (%d bytes)", got, len(got), tt.want, len(tt.want))
+			}
+			if len(got) > MaxLen || !utf8.ValidString(got) {
+				t.Errorf("Sanitize() = %q, %d bytes, valid utf8 %v", got, len(got), utf8.ValidString(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// Orientation 读取jpeg中EXIF的方向标记，没有EXIF或读取失败时返回1（不旋转）
+func Orientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return 1
+		}
+		marker := data[offset+1]
+		// SOS之后是图像数据，EXIF只会出现在前面
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if length < 2 || offset+2+length > len(data) {
+			return 1
+		}
+		segment := data[offset+4 : offset+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		offset += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation 在TIFF结构的IFD0中查找0x0112方向标记
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// ToNRGBA 转换为从(0,0)开始的NRGBA图片，方便后续逐像素处理
+func ToNRGBA(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+	return dst
+}
+
+// ApplyOrientation 按EXIF方向标记把图片转正，去掉EXIF后图片方向仍然正确
+func ApplyOrientation(img image.Image, orientation int) *image.NRGBA {
+	src := ToNRGBA(img)
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	// 5-8需要交换宽高
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-x, y
+			case 3: // 旋转180度
+				sx, sy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转90度
+				sx, sy = y, h-1-x
+			case 7: // 沿右上-左下对角线翻转
+				sx, sy = w-1-y, h-1-x
+			case 8: // 逆时针旋转90度
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// Resize 缩放到指定宽高，每个目标像素取源图对应区域的平均值
+// 按alpha加权平均，透明区域的颜色不会渗到边缘
+func Resize(img image.Image, width, height int) *image.NRGBA {
+	src := ToNRGBA(img)
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if sw == 0 || sh == 0 {
+		return dst
+	}
+	for y := 0; y < height; y++ {
+		sy0 := y * sh / height
+		sy1 := (y + 1) * sh / height
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < width; x++ {
+			sx0 := x * sw / width
+			sx1 := (x + 1) * sw / width
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				offset := src.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					pa := uint64(src.Pix[offset+3])
+					r += uint64(src.Pix[offset]) * pa
+					g += uint64(src.Pix[offset+1]) * pa
+					b += uint64(src.Pix[offset+2]) * pa
+					a += pa
+					n++
+					offset += 4
+				}
+			}
+			i := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[i] = uint8(r / a)
+				dst.Pix[i+1] = uint8(g / a)
+				dst.Pix[i+2] = uint8(b / a)
+				dst.Pix[i+3] = uint8(a / n)
+			}
+		}
+	}
+	return dst
+}
+
+// Fit 等比缩小到最长边不超过maxSide，本身更小时不放大
+func Fit(img image.Image, maxSide int) *image.NRGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxSide && h <= maxSide {
+		return ToNRGBA(img)
+	}
+	if w >= h {
+		return Resize(img, maxSide, h*maxSide/w)
+	}
+	return Resize(img, w*maxSide/h, maxSide)
+}
+
+// Opaque 图片是否完全不透明
+func Opaque(img *image.NRGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0xFF {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode 按格式重新编码，只写入像素数据，原图中的EXIF等元数据都会被丢弃
+// format取值与image.DecodeConfig返回的格式名一致：jpeg、png、gif
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "jpeg":
+		// jpeg不支持透明，先铺白底
+		if nrgba, ok := img.(*image.NRGBA); ok && !Opaque(nrgba) {
+			bg := image.NewNRGBA(nrgba.Rect)
+			draw.Draw(bg, bg.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+			draw.Draw(bg, bg.Rect, nrgba, nrgba.Rect.Min, draw.Over)
+			img = bg
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	case "gif":
+		return gif.Encode(w, img, nil)
+	}
+	return png.Encode(w, img)
+}
+
+// CropSquare 居中裁剪为正方形，边长为宽高中较小的一个
+func CropSquare(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+	dst := image.NewNRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Rect, img, image.Point{X: x, Y: y}, draw.Src)
+	return dst
+}
+
+// Solid 纯色正方形，用于没有图片时占位
+func Solid(size int, c color.Color) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Rect, image.NewUniform(c), image.Point{}, draw.Src)
+	return dst
+}
+
+// Composite 把多张图片拼成边长为size的宫格图，1张时铺满，2到4张为两列，5张以上为三列
+// 每张图片先居中裁剪为正方形，不满一行时该行居中，整体在画布上垂直居中
+func Composite(tiles []image.Image, size int, background color.Color) *image.NRGBA {
+	dst := Solid(size, background)
+	n := len(tiles)
+	if n == 0 {
+		return dst
+	}
+	cols := 3
+	switch {
+	case n == 1:
+		cols = 1
+	case n <= 4:
+		cols = 2
+	}
+	rows := (n + cols - 1) / cols
+	gap := size / 40
+	if n == 1 {
+		gap = 0
+	}
+	cell := (size - gap*(cols+1)) / cols
+	top := (size - rows*cell - (rows-1)*gap) / 2
+	for i, tile := range tiles {
+		row, col := i/cols, i%cols
+		// 最后一行不满时居中
+		inRow := cols
+		if row == rows-1 && n%cols != 0 {
+			inRow = n % cols
+		}
+		left := (size - inRow*cell - (inRow-1)*gap) / 2
+		x := left + col*(cell+gap)
+		y := top + row*(cell+gap)
+		scaled := Resize(CropSquare(tile), cell, cell)
+		draw.Draw(dst, image.Rect(x, y, x+cell, y+cell), scaled, image.Point{}, draw.Over)
+	}
+	return dst
+}
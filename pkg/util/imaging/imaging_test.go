@@ -0,0 +1,286 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// tiffWithOrientation 只包含IFD0的TIFF结构，entries个条目中最后一个为方向标记
+func tiffWithOrientation(order binary.ByteOrder, orientation uint16, entries int) []byte {
+	tiff := make([]byte, 8+2+entries*12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], uint16(entries))
+	for i := 0; i < entries; i++ {
+		entry := tiff[10+i*12:]
+		tag := uint16(0x010F) // 相机厂商
+		if i == entries-1 {
+			tag = 0x0112
+		}
+		order.PutUint16(entry, tag)
+		order.PutUint16(entry[2:], 3) // SHORT
+		order.PutUint32(entry[4:], 1)
+		order.PutUint16(entry[8:], orientation)
+	}
+	return tiff
+}
+
+// jpegSegment jpeg中的一个标记段
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// jpegWith SOI之后依次拼接各个标记段
+func jpegWith(segments ...[]byte) []byte {
+	data := []byte{0xFF, 0xD8}
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	return data
+}
+
+func exifPayload(tiff []byte) []byte {
+	return append([]byte("Exif\x00\x00"), tiff...)
+}
+
+func TestOrientation(t *testing.T) {
+	jfif := jpegSegment(0xE0, []byte("JFIF\x00\x01\x01"))
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"空数据", nil, 1},
+		{"不是jpeg", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"只有SOI", []byte{0xFF, 0xD8}, 1},
+		{"没有EXIF", jpegWith(jfif, jpegSegment(0xDA, []byte{0})), 1},
+		{"小端", jpegWith(jpegSegment(0xE1, exifPayload(tiffWithOrientation(binary.LittleEndian, 6, 1)))), 6},
+		{"大端", jpegWith(jpegSegment(0xE1, exifPayload(tiffWithOrientation(binary.BigEndian, 8, 1)))), 8},
+		{"EXIF在JFIF之后", jpegWith(jfif, jpegSegment(0xE1, exifPayload(tiffWithOrientation(binary.BigEndian, 3, 2)))), 3},
+		{"SOS之后的EXIF不读取", jpegWith(jpegSegment(0xDA, []byte{0}), jpegSegment(0xE1, exifPayload(tiffWithOrientation(binary.BigEndian, 6, 1)))), 1},
+		{"APP1不是EXIF", jpegWith(jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00"))), 1},
+		{"段长度超出数据", jpegWith(jpegSegment(0xE1, exifPayload(tiffWithOrientation(binary.BigEndian, 6, 1))))[:20], 1},
+		{"段长度小于2", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 0x00, 0x00}, 1},
+		{"标记前不是0xFF", []byte{0xFF, 0xD8, 0x00, 0xE1, 0x00, 0x04, 0x00, 0x00}, 1},
+		{"方向值为0", jpegWith(jpegSegment(0xE1, exifPayload(tiffWithOrientation(binary.LittleEndian, 0, 1)))), 1},
+		{"方向值为9", jpegWith(jpegSegment(0xE1, exifPayload(tiffWithOrientation(binary.LittleEndian, 9, 1)))), 1},
+	}
+	for orientation := 1; orientation <= 8; orientation++ {
+		tests = append(tests, struct {
+			name string
+			data []byte
+			want int
+		}{"方向" + string(rune('0'+orientation)), jpegWith(jpegSegment(0xE1, exifPayload(tiffWithOrientation(binary.BigEndian, uint16(orientation), 1)))), orientation})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Orientation(tt.data); got != tt.want {
+				t.Errorf("Orientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExifOrientation(t *testing.T) {
+	valid := tiffWithOrientation(binary.LittleEndian, 6, 1)
+	badOffset := tiffWithOrientation(binary.LittleEndian, 6, 1)
+	binary.LittleEndian.PutUint32(badOffset[4:], 4)
+	farOffset := tiffWithOrientation(binary.LittleEndian, 6, 1)
+	binary.LittleEndian.PutUint32(farOffset[4:], 1<<20)
+	noTag := tiffWithOrientation(binary.BigEndian, 6, 1)
+	binary.BigEndian.PutUint16(noTag[10:], 0x010F)
+	tooManyEntries := tiffWithOrientation(binary.BigEndian, 6, 1)
+	binary.BigEndian.PutUint16(tooManyEntries[8:], 100)
+	binary.BigEndian.PutUint16(tooManyEntries[10:], 0x010F)
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"有效", valid, 6},
+		{"第二个条目", tiffWithOrientation(binary.BigEndian, 5, 2), 5},
+		{"长度不足8", valid[:7], 1},
+		{"字节序不合法", append([]byte("XX"), valid[2:]...), 1},
+		{"IFD偏移小于8", badOffset, 1},
+		{"IFD偏移超出数据", farOffset, 1},
+		{"截断在条目中间", valid[:15], 1},
+		{"没有方向标记", noTag, 1},
+		{"条目数超出数据", tooManyEntries, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.tiff); got != tt.want {
+				t.Errorf("exifOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// labeledImage 每个像素的R为行列编号，用于检查像素移动到的位置
+func labeledImage(rows [][]uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, label := range row {
+			img.SetNRGBA(x, y, color.NRGBA{R: label, A: 0xFF})
+		}
+	}
+	return img
+}
+
+func labels(img *image.NRGBA) [][]uint8 {
+	rows := make([][]uint8, img.Rect.Dy())
+	for y := range rows {
+		rows[y] = make([]uint8, img.Rect.Dx())
+		for x := range rows[y] {
+			rows[y][x] = img.NRGBAAt(x, y).R
+		}
+	}
+	return rows
+}
+
+func equalLabels(a, b [][]uint8) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for y := range a {
+		if len(a[y]) != len(b[y]) {
+			return false
+		}
+		for x := range a[y] {
+			if a[y][x] != b[y][x] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestApplyOrientation(t *testing.T) {
+	const a, b, c, d, e, f = 1, 2, 3, 4, 5, 6
+	src := [][]uint8{
+		{a, b, c},
+		{d, e, f},
+	}
+	tests := []struct {
+		orientation int
+		want        [][]uint8
+	}{
+		{0, [][]uint8{{a, b, c}, {d, e, f}}},
+		{1, [][]uint8{{a, b, c}, {d, e, f}}},
+		{2, [][]uint8{{c, b, a}, {f, e, d}}},
+		{3, [][]uint8{{f, e, d}, {c, b, a}}},
+		{4, [][]uint8{{d, e, f}, {a, b, c}}},
+		{5, [][]uint8{{a, d}, {b, e}, {c, f}}},
+		{6, [][]uint8{{d, a}, {e, b}, {f, c}}},
+		{7, [][]uint8{{f, c}, {e, b}, {d, a}}},
+		{8, [][]uint8{{c, f}, {b, e}, {a, d}}},
+		{9, [][]uint8{{a, b, c}, {d, e, f}}},
+	}
+	for _, tt := range tests {
+		got := labels(ApplyOrientation(labeledImage(src), tt.orientation))
+		if !equalLabels(got, tt.want) {
+			t.Errorf("ApplyOrientation(%d) = %v, want %v", tt.orientation, got, tt.want)
+		}
+	}
+
+	// 子图的起点不在(0,0)
+	sub := labeledImage([][]uint8{{9, 9, 9}, {9, a, b}}).SubImage(image.Rect(1, 1, 3, 2))
+	if got := labels(ApplyOrientation(sub, 3)); !equalLabels(got, [][]uint8{{b, a}}) {
+		t.Errorf("ApplyOrientation(subimage, 3) = %v", got)
+	}
+}
+
+func solidImage(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestResize(t *testing.T) {
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	halves := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			if x < 2 {
+				halves.SetNRGBA(x, y, color.NRGBA{R: 0xFF, A: 0xFF})
+			} else {
+				halves.SetNRGBA(x, y, color.NRGBA{B: 0xFF, A: 0xFF})
+			}
+		}
+	}
+	mixed := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	mixed.SetNRGBA(0, 0, red)
+	mixed.SetNRGBA(1, 0, color.NRGBA{B: 0xFF})
+
+	tests := []struct {
+		name          string
+		src           image.Image
+		width, height int
+		at            image.Point
+		want          color.NRGBA
+	}{
+		{"纯色缩小", solidImage(10, 6, red), 5, 3, image.Pt(4, 2), red},
+		{"纯色放大", solidImage(1, 1, red), 3, 3, image.Pt(2, 2), red},
+		{"左半边", halves, 2, 1, image.Pt(0, 0), color.NRGBA{R: 0xFF, A: 0xFF}},
+		{"右半边", halves, 2, 1, image.Pt(1, 0), color.NRGBA{B: 0xFF, A: 0xFF}},
+		{"跨两种颜色取平均", halves, 1, 1, image.Pt(0, 0), color.NRGBA{R: 0x7F, B: 0x7F, A: 0xFF}},
+		{"透明像素的颜色不参与平均", mixed, 1, 1, image.Pt(0, 0), color.NRGBA{R: 0xFF, A: 0x7F}},
+		{"宽高小于1按1处理", solidImage(4, 4, red), 0, -1, image.Pt(0, 0), red},
+		{"空图片", image.NewNRGBA(image.Rect(0, 0, 0, 0)), 2, 2, image.Pt(1, 1), color.NRGBA{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := Resize(tt.src, tt.width, tt.height)
+			wantW, wantH := tt.width, tt.height
+			if wantW < 1 {
+				wantW = 1
+			}
+			if wantH < 1 {
+				wantH = 1
+			}
+			if dst.Rect.Dx() != wantW || dst.Rect.Dy() != wantH {
+				t.Fatalf("Resize() size = %dx%d, want %dx%d", dst.Rect.Dx(), dst.Rect.Dy(), wantW, wantH)
+			}
+			if got := dst.NRGBAAt(tt.at.X, tt.at.Y); got != tt.want {
+				t.Errorf("Resize() at %v = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name         string
+		w, h         int
+		maxSide      int
+		wantW, wantH int
+	}{
+		{"横图", 100, 50, 40, 40, 20},
+		{"竖图", 50, 100, 40, 20, 40},
+		{"正方形", 100, 100, 40, 40, 40},
+		{"更小时不放大", 30, 20, 40, 30, 20},
+		{"恰好等于上限", 40, 40, 40, 40, 40},
+		{"极窄的图短边至少1像素", 1000, 1, 100, 100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := Fit(solidImage(tt.w, tt.h, color.NRGBA{G: 0xFF, A: 0xFF}), tt.maxSide)
+			if dst.Rect.Dx() != tt.wantW || dst.Rect.Dy() != tt.wantH {
+				t.Errorf("Fit(%dx%d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.maxSide, dst.Rect.Dx(), dst.Rect.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
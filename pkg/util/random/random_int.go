@@ -3,14 +3,35 @@ package random
 import (
 	"math"
 	"math/rand"
-	"strconv"
+	"strings"
 	"time"
 )
 
+// maxIntLen int能完整表示的最大十进制位数
+const maxIntLen = 18
+
+// GetRandomInt 返回len位的随机数，超过18位时int会溢出，按18位生成
 func GetRandomInt(len int) int {
+	if len > maxIntLen {
+		len = maxIntLen
+	}
 	return rand.Intn(9*int(math.Pow(10, float64(len-1)))) + int(math.Pow(10, float64(len-1)))
 }
 
+// GetRandomDigits 返回len位的随机数字串，首位不为0，位数不受int范围限制
+func GetRandomDigits(len int) string {
+	var b strings.Builder
+	b.Grow(len)
+	for i := 0; i < len; i++ {
+		if i == 0 {
+			b.WriteByte(byte('1' + rand.Intn(9)))
+		} else {
+			b.WriteByte(byte('0' + rand.Intn(10)))
+		}
+	}
+	return b.String()
+}
+
 func GetNowAndLenRandomString(len int) string {
-	return time.Now().Format("20060102") + strconv.Itoa(GetRandomInt(len))
+	return time.Now().Format("20060102") + GetRandomDigits(len)
 }
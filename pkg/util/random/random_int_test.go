@@ -0,0 +1,36 @@
+package random
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestGetRandomInt(t *testing.T) {
+	for _, n := range []int{1, 6, 18, 20} {
+		want := n
+		if want > maxIntLen {
+			want = maxIntLen
+		}
+		if got := strconv.Itoa(GetRandomInt(n)); len(got) != want {
+			t.Errorf("GetRandomInt(%d) = %s, want %d digits", n, got, want)
+		}
+	}
+}
+
+func TestGetNowAndLenRandomString(t *testing.T) {
+	for _, n := range []int{11, 20, 32} {
+		got := GetNowAndLenRandomString(n)
+		if len(got) != 8+n {
+			t.Errorf("GetNowAndLenRandomString(%d) = %q, want %d chars", n, got, 8+n)
+		}
+		if got[8] == '0' {
+			t.Errorf("GetNowAndLenRandomString(%d) = %q, random part starts with 0", n, got)
+		}
+		for _, c := range got[8:] {
+			if c < '0' || c > '9' {
+				t.Errorf("GetNowAndLenRandomString(%d) = %q, not all digits", n, got)
+				break
+			}
+		}
+	}
+}
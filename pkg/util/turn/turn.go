@@ -0,0 +1,18 @@
+package turn
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// Credential 按coturn REST API的共享密钥方式生成TURN临时凭证
+// 用户名为"过期时间戳:用户uuid"，密码为base64(HMAC-SHA1(secret, 用户名))，coturn用同一密钥校验并检查是否过期
+func Credential(secret, userId string, expireAt time.Time) (string, string) {
+	username := fmt.Sprintf("%d:%s", expireAt.Unix(), userId)
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return username, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,38 @@
+package turn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCredential(t *testing.T) {
+	// 期望值由 base64(hmac.new(secret, username, sha1)) 独立计算，与coturn use-auth-secret的校验方式一致
+	tests := []struct {
+		secret       string
+		userId       string
+		expireAt     int64
+		wantUsername string
+		wantPassword string
+	}{
+		{"north", "U20240101123456789", 1700000000, "1700000000:U20240101123456789", "KP4x5sgIVMk/nmF4o5AbwhnERFU="},
+		{"密钥", "U1", 1700003600, "1700003600:U1", "TXKQMlEGcpoxzKc6flphJ56KPCE="},
+		{"", "u", 1, "1:u", "VBENyGDzRBuZgJOLcK/mka3IzK4="},
+	}
+	for _, tt := range tests {
+		username, password := Credential(tt.secret, tt.userId, time.Unix(tt.expireAt, 0))
+		if username != tt.wantUsername || password != tt.wantPassword {
+			t.Errorf("Credential(%q, %q, %d) = %q, %q, want %q, %q",
+				tt.secret, tt.userId, tt.expireAt, username, password, tt.wantUsername, tt.wantPassword)
+		}
+	}
+}
+
+func TestCredentialTimezone(t *testing.T) {
+	// 用户名使用unix时间戳，与时区无关
+	expireAt := time.Unix(1700000000, 0)
+	u1, p1 := Credential("north", "U1", expireAt.UTC())
+	u2, p2 := Credential("north", "U1", expireAt.In(time.FixedZone("CST", 8*3600)))
+	if u1 != u2 || p1 != p2 {
+		t.Errorf("Credential differs by timezone: %q %q, %q %q", u1, p1, u2, p2)
+	}
+}
@@ -0,0 +1,12 @@
+import axios from "axios";
+
+// 上传头像，返回服务端保存后的头像地址，文件名由服务端生成
+export async function uploadAvatar(backendUrl, file) {
+    const formData = new FormData();
+    formData.append("file", file);
+    const rsp = await axios.post(backendUrl + "/message/uploadAvatar", formData);
+    if (rsp.data.code != 200) {
+        throw new Error(rsp.data.message);
+    }
+    return rsp.data.data.url;
+};